@@ -0,0 +1,299 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/blevesearch/bleve/v2/registry"
+	store "github.com/blevesearch/upsidedown_store_api"
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Bleve upsidedown KV store on an encrypted badger - keeps the search index off the disk in plaintext.
+// Key comes from indexEncryptionKey, not from the kv config, because bleve saves the config in index_meta.json.
+const encryptedKVStoreName = "badger-encrypted"
+
+const (
+	indexModeMemory    = "memory"
+	indexModeEncrypted = "encrypted"
+)
+
+// Marks a batch split over several badger commits until the last one is done.
+// Rows of upsidedown never start with '~'.
+var partialBatchKey = []byte("~partial-batch")
+
+var errPartialIndexBatch = errors.New("search index has a partially written batch")
+
+type encryptedKVStore struct {
+	db *badger.DB
+	mo store.MergeOperator
+}
+
+func newEncryptedKVStore(mo store.MergeOperator, config map[string]interface{}) (store.KVStore, error) {
+	path, ok := config["path"].(string)
+	if !ok || path == "" {
+		return nil, fmt.Errorf("must specify path")
+	}
+	if len(indexEncryptionKey) == 0 {
+		return nil, fmt.Errorf("index encryption key is not set")
+	}
+
+	opts := badgerOptions(path, indexEncryptionKey)
+	opts.IndexCacheSize = 20 << 20 // 20 MB
+	opts.SyncWrites = false
+
+	indexDB, err := badger.Open(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// An interrupted batch left the index partly written - not opened, openEncryptedBleveIndex builds it again
+	err = indexDB.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(partialBatchKey)
+		return err
+	})
+	if err == nil {
+		err = errPartialIndexBatch
+	}
+	if !errors.Is(err, badger.ErrKeyNotFound) {
+		indexDB.Close()
+		return nil, err
+	}
+
+	return &encryptedKVStore{db: indexDB, mo: mo}, nil
+}
+
+func (s *encryptedKVStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *encryptedKVStore) Reader() (store.KVReader, error) {
+	return &encryptedKVReader{txn: s.db.NewTransaction(false)}, nil
+}
+
+func (s *encryptedKVStore) Writer() (store.KVWriter, error) {
+	return &encryptedKVWriter{store: s}, nil
+}
+
+type encryptedKVReader struct {
+	txn *badger.Txn
+}
+
+func (r *encryptedKVReader) Get(key []byte) ([]byte, error) {
+	item, err := r.txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item.ValueCopy(nil)
+}
+
+func (r *encryptedKVReader) MultiGet(keys [][]byte) ([][]byte, error) {
+	return store.MultiGet(r, keys)
+}
+
+func (r *encryptedKVReader) PrefixIterator(prefix []byte) store.KVIterator {
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+
+	it := &encryptedKVIterator{it: r.txn.NewIterator(opts), prefix: prefix}
+	it.Seek(prefix)
+	return it
+}
+
+func (r *encryptedKVReader) RangeIterator(start, end []byte) store.KVIterator {
+	opts := badger.DefaultIteratorOptions
+
+	it := &encryptedKVIterator{it: r.txn.NewIterator(opts), start: start, end: end}
+	it.Seek(start)
+	return it
+}
+
+func (r *encryptedKVReader) Close() error {
+	r.txn.Discard()
+	return nil
+}
+
+type encryptedKVIterator struct {
+	it     *badger.Iterator
+	prefix []byte
+	start  []byte
+	end    []byte
+	key    []byte
+	val    []byte
+	valid  bool
+}
+
+func (i *encryptedKVIterator) load() {
+	i.valid = i.it.Valid()
+	if !i.valid {
+		return
+	}
+
+	item := i.it.Item()
+	i.key = item.KeyCopy(nil)
+	if i.prefix != nil && !bytes.HasPrefix(i.key, i.prefix) {
+		i.valid = false
+		return
+	}
+	if i.end != nil && bytes.Compare(i.key, i.end) >= 0 {
+		i.valid = false
+		return
+	}
+
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		i.valid = false
+		return
+	}
+	i.val = val
+}
+
+func (i *encryptedKVIterator) Seek(key []byte) {
+	if i.start != nil && bytes.Compare(key, i.start) < 0 {
+		key = i.start
+	}
+	if i.prefix != nil && !bytes.HasPrefix(key, i.prefix) {
+		if bytes.Compare(key, i.prefix) > 0 {
+			i.valid = false
+			return
+		}
+		key = i.prefix
+	}
+
+	i.it.Seek(key)
+	i.load()
+}
+
+func (i *encryptedKVIterator) Next() {
+	i.it.Next()
+	i.load()
+}
+
+func (i *encryptedKVIterator) Key() []byte {
+	return i.key
+}
+
+func (i *encryptedKVIterator) Value() []byte {
+	return i.val
+}
+
+func (i *encryptedKVIterator) Valid() bool {
+	return i.valid
+}
+
+func (i *encryptedKVIterator) Current() ([]byte, []byte, bool) {
+	return i.key, i.val, i.valid
+}
+
+func (i *encryptedKVIterator) Close() error {
+	i.it.Close()
+	return nil
+}
+
+type encryptedKVWriter struct {
+	store *encryptedKVStore
+}
+
+func (w *encryptedKVWriter) NewBatch() store.KVBatch {
+	return store.NewEmulatedBatch(w.store.mo)
+}
+
+func (w *encryptedKVWriter) NewBatchEx(options store.KVBatchOptions) ([]byte, store.KVBatch, error) {
+	return make([]byte, options.TotalBytes), w.NewBatch(), nil
+}
+
+// ExecuteBatch writes a batch in one badger transaction. A batch too big for it(full rebuild) is written
+// with a WriteBatch between the set and the delete of partialBatchKey, so a crash in between is found on open.
+func (w *encryptedKVWriter) ExecuteBatch(batch store.KVBatch) error {
+	emulatedBatch, ok := batch.(*store.EmulatedBatch)
+	if !ok {
+		return fmt.Errorf("wrong type of batch")
+	}
+
+	// Over the count limit of a transaction, not tried in one
+	if int64(len(emulatedBatch.Ops)+len(emulatedBatch.Merger.Merges)) < w.store.db.MaxBatchCount() {
+		txn := w.store.db.NewTransaction(true)
+		err := w.writeBatch(emulatedBatch, txn, txn.Set, txn.Delete)
+		if err == nil {
+			err = txn.Commit()
+		}
+		txn.Discard()
+		if !errors.Is(err, badger.ErrTxnTooBig) {
+			return err
+		}
+	}
+
+	err := w.store.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(partialBatchKey, []byte{1})
+	})
+	if err != nil {
+		return err
+	}
+
+	readTxn := w.store.db.NewTransaction(false)
+	defer readTxn.Discard()
+	writeBatch := w.store.db.NewWriteBatch()
+	defer writeBatch.Cancel()
+
+	if err := w.writeBatch(emulatedBatch, readTxn, writeBatch.Set, writeBatch.Delete); err != nil {
+		return err
+	}
+	if err := writeBatch.Flush(); err != nil {
+		return err
+	}
+
+	return w.store.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(partialBatchKey)
+	})
+}
+
+// writeBatch merges the values of the batch with the ones read by readTxn, and writes all operations
+func (w *encryptedKVWriter) writeBatch(batch *store.EmulatedBatch, readTxn *badger.Txn, set func(key, val []byte) error, delete func(key []byte) error) error {
+	write := func(key, val []byte) error {
+		if val != nil {
+			return set(key, val)
+		}
+		return delete(key)
+	}
+
+	for k, mergeOps := range batch.Merger.Merges {
+		key := []byte(k)
+
+		var existingVal []byte
+		item, err := readTxn.Get(key)
+		if err == nil {
+			existingVal, err = item.ValueCopy(nil)
+		}
+		if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		mergedVal, fullMergeOk := w.store.mo.FullMerge(key, existingVal, mergeOps)
+		if !fullMergeOk {
+			return fmt.Errorf("merge operator returned failure")
+		}
+		if err := write(key, mergedVal); err != nil {
+			return err
+		}
+	}
+
+	for _, op := range batch.Ops {
+		if err := write(op.K, op.V); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *encryptedKVWriter) Close() error {
+	return nil
+}
+
+func init() {
+	registry.RegisterKVStore(encryptedKVStoreName, newEncryptedKVStore)
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/index/upsidedown"
+	"github.com/dgraph-io/badger/v3"
+)
+
+// useTestIndexKey sets the key of the encrypted index, and the index path in a temporary directory
+func useTestIndexKey(t *testing.T) string {
+	t.Helper()
+
+	oldKey, oldPath := indexEncryptionKey, bleveIndexPath
+	indexEncryptionKey = make([]byte, 32)
+	bleveIndexPath = filepath.Join(t.TempDir(), "record_index.bleve")
+	t.Cleanup(func() {
+		indexEncryptionKey, bleveIndexPath = oldKey, oldPath
+	})
+
+	return bleveIndexPath
+}
+
+// A batch over the badger transaction limit is written whole, without the partial batch mark
+func TestEncryptedKVStoreBigBatch(t *testing.T) {
+	path := filepath.Join(useTestIndexKey(t), "store")
+
+	kvStore, err := newEncryptedKVStore(nil, map[string]interface{}{"path": path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer kvStore.Close()
+
+	writer, _ := kvStore.Writer()
+	batch := writer.NewBatch()
+	count := 200_000
+	for i := 0; i < count; i++ {
+		batch.Set([]byte(fmt.Sprintf("t%08d", i)), []byte("value"))
+	}
+	if err := writer.ExecuteBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, _ := kvStore.Reader()
+	defer reader.Close()
+	found := 0
+	it := reader.PrefixIterator([]byte("t"))
+	for ; it.Valid(); it.Next() {
+		found++
+	}
+	it.Close()
+	if found != count {
+		t.Fatalf("got %d keys, want %d", found, count)
+	}
+	if value, err := reader.Get(partialBatchKey); err != nil || value != nil {
+		t.Fatalf("partial batch mark: got %v, %v", value, err)
+	}
+}
+
+// An index left with the partial batch mark is not opened, but built again
+func TestEncryptedIndexPartialBatch(t *testing.T) {
+	path := useTestIndexKey(t)
+
+	index, err := bleve.NewUsing(path, bleve.NewIndexMapping(), upsidedown.Name, encryptedKVStoreName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := index.Index("record:1", Record{ID: "record:1", Description: "lunch"}); err != nil {
+		t.Fatal(err)
+	}
+	index.Close()
+
+	// Crash in the middle of a big batch
+	db, err := badger.Open(badgerOptions(filepath.Join(path, "store"), indexEncryptionKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(txn *badger.Txn) error {
+		return txn.Set(partialBatchKey, []byte{1})
+	})
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := newEncryptedKVStore(nil, map[string]interface{}{"path": filepath.Join(path, "store")}); err != errPartialIndexBatch {
+		t.Fatalf("got %v, want errPartialIndexBatch", err)
+	}
+
+	index, err = openEncryptedBleveIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer index.Close()
+	if count, err := index.DocCount(); err != nil || count != 0 {
+		t.Fatalf("got %d documents, %v - want a new index", count, err)
+	}
+}
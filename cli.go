@@ -0,0 +1,557 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const cliUsage = `Usage: server [options] <command> [command options]
+
+Commands:
+  serve     Start the web server (default)
+  add       Add a record
+  list      List or search records of a period
+  report    Sums and category stats of a period
+  import    Add accounts, categories and records of an export file
+  export    Write all accounts, categories and records as JSON
+  backup    Copy the ledger to a backup
+  reindex   Rebuild the search index
+  passwd    Change the password
+  config    Print the effective config ("config show")
+
+The password is read from LEDGER_PASSWORD, or prompted.
+Run "server -h" for the options, "server <command> -h" for the options of a command.
+`
+
+// Export file of the ledger
+type LedgerExport struct {
+	Accounts   []Account  `json:"accounts"`
+	Categories []Category `json:"categories"`
+	Records    []Record   `json:"records"`
+}
+
+// RunCLI runs a subcommand on the ledger without the web server
+func RunCLI(args []string) error {
+	c, file, args, err := loadConfig(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := applyConfig(c, file); err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		StartServer()
+		return nil
+	}
+
+	command, args := args[0], args[1:]
+	switch command {
+	case "serve":
+		StartServer()
+		return nil
+	case "add":
+		return cliAdd(args)
+	case "list":
+		return cliList(args)
+	case "report":
+		return cliReport(args)
+	case "import":
+		return cliImport(args)
+	case "export":
+		return cliExport(args)
+	case "backup":
+		return cliBackup(args)
+	case "reindex":
+		return cliReindex(args)
+	case "passwd":
+		return cliPasswd(args)
+	case "config":
+		return cliConfig(args)
+	case "help", "-h", "--help":
+		fmt.Print(cliUsage)
+		return nil
+	}
+
+	fmt.Fprint(os.Stderr, cliUsage)
+	return fmt.Errorf("unknown command: %s", command)
+}
+
+// Shared, a buffered reader per prompt would lose the next lines
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// readPassword prompts on the terminal. A line of stdin without a terminal.
+func readPassword(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		password, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		return string(password), err
+	}
+
+	line, err := stdinReader.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// openLedgerCLI unlocks the ledger and the search index. Close with closeLedgerCLI.
+func openLedgerCLI() error {
+	password := os.Getenv("LEDGER_PASSWORD")
+	if password == "" {
+		var err error
+		if password, err = readPassword("Password: "); err != nil {
+			return err
+		}
+	}
+
+	recoveryKey, err := initBadgerDB(password)
+	if err != nil {
+		return err
+	}
+	if recoveryKey != "" {
+		fmt.Fprintln(os.Stderr, "Recovery key - write it down. It is shown only once:", recoveryKey)
+	}
+
+	if err := initBleveIndex(); err != nil {
+		closeLedgerStore()
+		return err
+	}
+
+	return nil
+}
+
+func closeLedgerCLI() {
+	closeBleveIndex()
+	closeLedgerStore()
+}
+
+func cliAdd(args []string) error {
+	flags := flag.NewFlagSet("add", flag.ExitOnError)
+	income := flags.Bool("income", false, "Income instead of payment")
+	accountID := flags.String("account", "", "Account ID")
+	payType := flags.String("pay-type", "direct", "direct, credit")
+	currency := flags.String("currency", "", "ISO 4217 currency code, the base currency of the settings by default")
+	amount := flags.String("amount", "", "Amount as a decimal of the currency")
+	category := flags.String("category", "", "Category")
+	description := flags.String("description", "", "Description")
+	date := flags.String("date", time.Now().Format("2006-01-02"), "YYYY-MM-DD")
+	clock := flags.String("time", time.Now().Format("15:04"), "HH:MM")
+	flags.Parse(args)
+
+	record := Record{
+		TransactionType: "record_type_pay",
+		AccountID:       *accountID,
+		PayType:         *payType,
+		Currency:        *currency,
+		Category:        *category,
+		Description:     *description,
+		Date:            *date,
+		Time:            *clock,
+	}
+	if *income {
+		record.TransactionType = "record_type_income"
+	}
+	record.amountText = *amount
+	record.Amount, record.amountErr = parseMoney(*amount, *currency)
+
+	// Validated by addRecord, after the defaults of the settings and the rules
+	if err := openLedgerCLI(); err != nil {
+		return err
+	}
+	defer closeLedgerCLI()
+
+	id, err := addRecord(record)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(id)
+	return nil
+}
+
+// periodFlags adds -from and -to, this month by default
+func periodFlags(flags *flag.FlagSet) (*string, *string) {
+	now := time.Now()
+	from := flags.String("from", now.Format("2006-01")+"-01", "Start date, YYYY-MM-DD")
+	to := flags.String("to", now.Format("2006-01-02"), "End date, YYYY-MM-DD")
+	return from, to
+}
+
+func parsePeriod(from, to string) (time.Time, time.Time, error) {
+	startDate, err := time.Parse("2006-01-02 15:04:05", from+" 00:00:00")
+	if err != nil {
+		return time.Time{}, time.Time{}, newValidationError("from", "invalid date format: use YYYY-MM-DD")
+	}
+	endDate, err := time.Parse("2006-01-02 15:04:05", to+" 23:59:59")
+	if err != nil {
+		return time.Time{}, time.Time{}, newValidationError("to", "invalid date format: use YYYY-MM-DD")
+	}
+	return startDate, endDate, nil
+}
+
+func cliList(args []string) error {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	from, to := periodFlags(flags)
+	query := flags.String("q", "", "Search words, separated by spaces")
+	queryType := flags.String("query-type", "OR", "AND, OR")
+	accountID := flags.String("account", "", "Only records of the account ID")
+	asJSON := flags.Bool("json", false, "JSON output")
+	flags.Parse(args)
+
+	startDate, endDate, err := parsePeriod(*from, *to)
+	if err != nil {
+		return err
+	}
+
+	if err := openLedgerCLI(); err != nil {
+		return err
+	}
+	defer closeLedgerCLI()
+
+	records, _, _, _, _, _, _, err := getRecords(strings.Fields(*query), *queryType, *accountID, startDate, endDate)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	}
+
+	accounts, _ := getAccountListMAP()
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "ID\tDATE\tTYPE\tACCOUNT\tCATEGORY\tAMOUNT\tDESCRIPTION")
+	for _, record := range records {
+		transactionType := "pay"
+		if record.TransactionType == "record_type_income" {
+			transactionType = "income"
+		}
+		fmt.Fprintf(writer, "%s\t%s %s\t%s\t%s\t%s\t%s %s\t%s\n",
+			record.ID, record.Date, record.Time, transactionType, accounts[record.AccountID].AccountName,
+			record.Category, record.Currency, formatMoney(record.Amount, record.Currency), record.Description)
+	}
+
+	return writer.Flush()
+}
+
+func cliReport(args []string) error {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	from, to := periodFlags(flags)
+	accountID := flags.String("account", "", "Only records of the account ID")
+	flags.Parse(args)
+
+	startDate, endDate, err := parsePeriod(*from, *to)
+	if err != nil {
+		return err
+	}
+
+	if err := openLedgerCLI(); err != nil {
+		return err
+	}
+	defer closeLedgerCLI()
+
+	_, stats, statsCredit, statsIncome, sumPay, sumCreditPay, sumIncome, err := getRecords(nil, "", *accountID, startDate, endDate)
+	if err != nil {
+		return err
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(writer, "Period\t%s ~ %s\n", *from, *to)
+	fmt.Fprintf(writer, "Income\t%s\n", sumIncome)
+	fmt.Fprintf(writer, "Pay\t%s\n", sumPay)
+	fmt.Fprintf(writer, "Credit not repaid\t%s\n", sumCreditPay)
+	fmt.Fprintln(writer)
+
+	fmt.Fprintln(writer, "CATEGORY\tPAY\tCREDIT")
+	categories := []string{}
+	for category := range stats {
+		categories = append(categories, category)
+	}
+	for category := range statsCredit {
+		if _, exist := stats[category]; !exist {
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", category, stats[category].Amount, statsCredit[category].Amount)
+	}
+
+	if len(statsIncome) > 0 {
+		fmt.Fprintln(writer)
+		fmt.Fprintln(writer, "INCOME CATEGORY\tINCOME")
+		categories = []string{}
+		for category := range statsIncome {
+			categories = append(categories, category)
+		}
+		sort.Strings(categories)
+		for _, category := range categories {
+			fmt.Fprintf(writer, "%s\t%s\n", category, statsIncome[category].Amount)
+		}
+	}
+
+	return writer.Flush()
+}
+
+func cliExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	output := flags.String("o", "", "Output file, stdout by default")
+	flags.Parse(args)
+
+	if err := openLedgerCLI(); err != nil {
+		return err
+	}
+	defer closeLedgerCLI()
+
+	var export LedgerExport
+	var err error
+	if export.Accounts, err = ledger.ListAccounts(); err != nil {
+		return err
+	}
+	if export.Categories, err = ledger.ListCategories(); err != nil {
+		return err
+	}
+	if export.Records, err = ledger.ListRecords("", "", ""); err != nil {
+		return err
+	}
+
+	writer := os.Stdout
+	if *output != "" {
+		file, err := os.OpenFile(*output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		writer = file
+	}
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(export)
+}
+
+// cliImport adds the entities of an export file with their IDs. Existing IDs are skipped.
+func cliImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: server import <file.json>")
+	}
+
+	data, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	var export LedgerExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return err
+	}
+
+	if err := openLedgerCLI(); err != nil {
+		return err
+	}
+	defer closeLedgerCLI()
+
+	// Entities without ID get a new one
+	regDTTM := time.Now().Format("20060102150405")
+
+	// Blank fields of the records are filled like addRecord - by the settings and the rules
+	settings, err := getSettings()
+	if err != nil {
+		return err
+	}
+	rules, err := loadRecordRules()
+	if err != nil {
+		return err
+	}
+
+	// The whole file is checked before the first write - an invalid entity imports nothing
+	ids := map[string]bool{}
+	checkID := func(id string) error {
+		if ids[id] {
+			return newConflictError("Duplicate ID in the file: %s", id)
+		}
+		ids[id] = true
+		return nil
+	}
+
+	for i := range export.Accounts {
+		account := &export.Accounts[i]
+		if err := validateAccount(*account); err != nil {
+			return fmt.Errorf("account %s: %w", account.ID, err)
+		}
+		if account.ID == "" {
+			account.ID, account.RegDTTM = newEntityID("account"), regDTTM
+		}
+		if err := checkID(account.ID); err != nil {
+			return err
+		}
+	}
+	for i := range export.Categories {
+		category := &export.Categories[i]
+		if err := validateCategory(*category); err != nil {
+			return fmt.Errorf("category %s: %w", category.ID, err)
+		}
+		if category.ID == "" {
+			category.ID, category.RegDTTM = newEntityID("category"), regDTTM
+		}
+		if err := checkID(category.ID); err != nil {
+			return err
+		}
+	}
+	for i := range export.Records {
+		record := &export.Records[i]
+		applyRecordDefaults(record, settings, rules)
+		if err := validateRecord(*record); err != nil {
+			return fmt.Errorf("record %s: %w", record.ID, err)
+		}
+		if record.ID == "" {
+			record.ID, record.RegDTTM = newEntityID("record"), regDTTM
+		}
+		if err := checkID(record.ID); err != nil {
+			return err
+		}
+	}
+
+	// Entities already in the ledger are skipped, so an import is repeated safely
+	added, skipped := 0, 0
+	count := func(err error) error {
+		var appError *AppError
+		if errors.As(err, &appError) && appError.Code == errorCodeConflict {
+			skipped++
+			return nil
+		}
+		if err == nil {
+			added++
+		}
+		return err
+	}
+
+	for _, account := range export.Accounts {
+		if err := count(ledger.AddAccount(account)); err != nil {
+			return err
+		}
+	}
+	invalidateAccountCache()
+
+	for _, category := range export.Categories {
+		if err := count(ledger.AddCategory(category)); err != nil {
+			return err
+		}
+	}
+
+	for _, record := range export.Records {
+		if err := count(ledger.AddRecord(record)); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Imported %d, skipped %d existing\n", added, skipped)
+
+	return rebuildBleveIndex()
+}
+
+func cliBackup(args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	output := flags.String("o", "", "Backup path, under the backup directory by default")
+	flags.Parse(args)
+
+	if err := openLedgerCLI(); err != nil {
+		return err
+	}
+	defer closeLedgerCLI()
+
+	if *output == "" {
+		*output = filepath.Join(backupPath, fmt.Sprintf("%s-%s", StorageBackend, time.Now().Format("20060102150405")))
+	}
+	if err := ledger.Backup(*output); err != nil {
+		return err
+	}
+
+	fmt.Println(*output)
+	return nil
+}
+
+func cliReindex(args []string) error {
+	flags := flag.NewFlagSet("reindex", flag.ExitOnError)
+	flags.Parse(args)
+
+	if err := openLedgerCLI(); err != nil {
+		return err
+	}
+	defer closeLedgerCLI()
+
+	return rebuildBleveIndex()
+}
+
+func cliPasswd(args []string) error {
+	flags := flag.NewFlagSet("passwd", flag.ExitOnError)
+	flags.Parse(args)
+
+	oldPassword, err := readPassword("Current password or recovery key: ")
+	if err != nil {
+		return err
+	}
+	newPassword, err := readPassword("New password: ")
+	if err != nil {
+		return err
+	}
+	confirmPassword, err := readPassword("New password again: ")
+	if err != nil {
+		return err
+	}
+	if newPassword != confirmPassword {
+		return fmt.Errorf("new passwords do not match")
+	}
+
+	return changePassword(oldPassword, newPassword)
+}
+
+func cliConfig(args []string) error {
+	if len(args) != 1 || args[0] != "show" {
+		return fmt.Errorf("usage: server config show")
+	}
+
+	file := currentConfigFile
+	if file == "" {
+		file = "(none)"
+	}
+
+	show := struct {
+		ConfigFile string `json:"config-file"`
+		Config
+		Paths map[string]string `json:"paths"`
+	}{
+		ConfigFile: file,
+		Config:     currentConfig,
+		Paths: map[string]string{
+			"badger":       badgerPath,
+			"sqlite":       sqlitePath,
+			"search-index": bleveIndexPath,
+			"key-slots":    keySlotsFile,
+		},
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(show)
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// useTestCLI points the CLI at a sqlite ledger in a temporary directory, so records outlive a command
+func useTestCLI(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	backend, indexMode := StorageBackend, IndexMode
+	paths := []*string{&keySlotsFile, &kdfHeaderFile, &legacySaltFile, &bleveIndexPath, &badgerPath, &sqlitePath}
+	oldPaths := []string{}
+	for _, path := range paths {
+		oldPaths = append(oldPaths, *path)
+		*path = filepath.Join(dir, filepath.Base(*path))
+	}
+	argon2Time, argon2Memory := Argon2Time, Argon2Memory
+
+	StorageBackend, IndexMode = storageBackendSQLite, indexModeMemory
+	Argon2Time, Argon2Memory = 1, 1024
+	t.Setenv("LEDGER_PASSWORD", "test")
+
+	t.Cleanup(func() {
+		StorageBackend, IndexMode = backend, indexMode
+		for i, path := range paths {
+			*path = oldPaths[i]
+		}
+		Argon2Time, Argon2Memory = argon2Time, argon2Memory
+	})
+
+	return dir
+}
+
+// listTestCLIRecords reopens the ledger of useTestCLI and lists every record
+func listTestCLIRecords(t *testing.T) []Record {
+	t.Helper()
+
+	if err := openLedgerCLI(); err != nil {
+		t.Fatal(err)
+	}
+	defer closeLedgerCLI()
+
+	records, err := ledger.ListRecords("", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+// The currency of add and import defaults to the base currency of the settings
+func TestCLIRecordsWithoutCurrency(t *testing.T) {
+	dir := useTestCLI(t)
+
+	for i := 0; i < 2; i++ {
+		if err := cliAdd([]string{"-amount", "12000", "-category", "food", "-date", "2026-03-02"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	export := LedgerExport{Records: []Record{{TransactionType: "record_type_pay", PayType: "direct", Amount: 5000, Category: "food", Date: "2026-03-03"}}}
+	data, err := json.Marshal(export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "export.json")
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := cliImport([]string{file}); err != nil {
+		t.Fatal(err)
+	}
+
+	records := listTestCLIRecords(t)
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want 3", len(records))
+	}
+	for _, record := range records {
+		if record.Currency != defaultSettings().BaseCurrency {
+			t.Fatalf("got currency %q of %s", record.Currency, record.ID)
+		}
+	}
+}
+
+// An invalid entity anywhere in the file imports nothing
+func TestCLIImportInvalidFile(t *testing.T) {
+	dir := useTestCLI(t)
+
+	export := LedgerExport{
+		Accounts: []Account{{ID: "account-import", AccountName: "Wallet", PayType: "direct"}},
+		Records: []Record{
+			{TransactionType: "record_type_pay", PayType: "direct", Amount: 5000, Category: "food", Date: "2026-03-03"},
+			{TransactionType: "record_type_pay", PayType: "direct", Amount: 7000, Category: "food", Date: "2026-13-40"},
+		},
+	}
+	data, err := json.Marshal(export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "export.json")
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	assertErrorCode(t, cliImport([]string{file}), errorCodeValidation)
+
+	if records := listTestCLIRecords(t); len(records) != 0 {
+		t.Fatalf("got %d records after a failed import", len(records))
+	}
+	if err := openLedgerCLI(); err != nil {
+		t.Fatal(err)
+	}
+	defer closeLedgerCLI()
+	accounts, err := ledger.ListAccounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 0 {
+		t.Fatalf("got %d accounts after a failed import", len(accounts))
+	}
+}
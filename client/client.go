@@ -0,0 +1,443 @@
+// Package client calls the ledger API of the server over HTTP.
+//
+//	c := client.New("http://localhost:12480")
+//	if _, err := c.Unlock(password); err != nil { ... }
+//	records, err := c.ListRecords(client.RecordQuery{From: "2024-07-01", To: "2024-07-31"})
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"server"
+)
+
+const apiPrefix = "/api/v1"
+
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+
+	// With KeepPassword, the password of Unlock unlocks again when the server restarted(423 Locked)
+	keepPassword bool
+	password     string
+}
+
+type Option func(*Client)
+
+// WithHTTPClient replaces the default client with a 30s timeout
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.HTTPClient = httpClient
+	}
+}
+
+// KeepPassword keeps the password of Unlock in memory and unlocks again on 423 Locked
+func KeepPassword() Option {
+	return func(c *Client) {
+		c.keepPassword = true
+	}
+}
+
+func New(baseURL string, options ...Option) *Client {
+	c := &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+	for _, option := range options {
+		option(c)
+	}
+
+	return c
+}
+
+// Error envelope of the server with the HTTP status
+type Error struct {
+	StatusCode int    `json:"-"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	Field      string `json:"field,omitempty"`
+
+	Migrations []server.MigrationReport `json:"migrations,omitempty"` // Reports of the code migration_dry_run
+}
+
+func (e *Error) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("%d %s: %s (%s)", e.StatusCode, e.Code, e.Message, e.Field)
+	}
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+func (e *Error) IsNotFound() bool { return e.StatusCode == http.StatusNotFound }
+func (e *Error) IsConflict() bool { return e.StatusCode == http.StatusConflict }
+func (e *Error) IsLocked() bool   { return e.StatusCode == http.StatusLocked }
+
+// Query of ListRecords. From and To are YYYY-MM-DD.
+type RecordQuery struct {
+	From      string
+	To        string
+	Query     []string // Search words. Empty for all records of the period
+	QueryType string   // AND, OR(default)
+	AccountID string
+}
+
+// Records of the period with the stats of the server
+type RecordList struct {
+	Records      []server.Record        `json:"records"`
+	Stats        map[string]server.Stat `json:"stats"`
+	StatsCredit  map[string]server.Stat `json:"stats-credit"`
+	StatsIncome  map[string]server.Stat `json:"stats-income"`
+	SumPay       server.Total           `json:"sum-pay"`
+	SumCreditPay server.Total           `json:"sum-credit-pay"`
+	SumIncome    server.Total           `json:"sum-income"`
+}
+
+// Query of TrendReport. Empty fields are the defaults of the server.
+type TrendQuery struct {
+	From        string
+	To          string
+	Granularity string // day, week, month, year
+	GroupBy     string // category, account, type
+}
+
+type KeySlot struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	RegDTTM string
+}
+
+// Unlock opens(or creates) the ledger. recoveryKey is returned only when the server made a new one.
+func (c *Client) Unlock(password string) (recoveryKey string, err error) {
+	var response map[string]string
+	err = c.do("GET", "/setup/db", url.Values{"password": {password}}, nil, &response)
+	if err != nil {
+		return "", err
+	}
+
+	if c.keepPassword {
+		c.password = password
+	}
+
+	return response["recovery-key"], nil
+}
+
+// ChangePassword wraps the key of the ledger with newPassword. The ledger stays unlocked.
+func (c *Client) ChangePassword(oldPassword, newPassword string) error {
+	err := c.do("GET", "/setup/db/password", url.Values{"old-password": {oldPassword}, "new-password": {newPassword}}, nil, nil)
+	if err == nil && c.keepPassword {
+		c.password = newPassword
+	}
+	return err
+}
+
+func (c *Client) ListKeySlots() ([]KeySlot, error) {
+	results := []KeySlot{}
+	err := c.do("GET", "/setup/keyslots", nil, nil, &results)
+	return results, err
+}
+
+// RemoveKeySlot removes the key slot id, authorized by a password or the recovery key
+func (c *Client) RemoveKeySlot(password, id string) error {
+	return c.do("DELETE", "/setup/keyslots/"+url.PathEscape(id), nil, map[string]string{"password": password}, nil)
+}
+
+func (c *Client) AddAccount(account server.Account) (string, error) {
+	return c.create("/accounts", account)
+}
+
+func (c *Client) GetAccount(id string) (server.Account, error) {
+	var account server.Account
+	err := c.do("GET", "/accounts/"+url.PathEscape(id), nil, nil, &account)
+	return account, err
+}
+
+func (c *Client) ListAccounts() ([]server.Account, error) {
+	results := []server.Account{}
+	err := c.do("GET", "/accounts", nil, nil, &results)
+	return results, err
+}
+
+func (c *Client) UpdateAccount(id string, account server.Account) error {
+	return c.do("PUT", "/accounts/"+url.PathEscape(id), nil, account, nil)
+}
+
+func (c *Client) DeleteAccount(id string) error {
+	return c.do("DELETE", "/accounts/"+url.PathEscape(id), nil, nil, nil)
+}
+
+func (c *Client) AddCategory(category server.Category) (string, error) {
+	return c.create("/categories", category)
+}
+
+func (c *Client) GetCategory(id string) (server.Category, error) {
+	var category server.Category
+	err := c.do("GET", "/categories/"+url.PathEscape(id), nil, nil, &category)
+	return category, err
+}
+
+func (c *Client) ListCategories() ([]server.Category, error) {
+	results := []server.Category{}
+	err := c.do("GET", "/categories", nil, nil, &results)
+	return results, err
+}
+
+func (c *Client) UpdateCategory(id string, category server.Category) error {
+	return c.do("PUT", "/categories/"+url.PathEscape(id), nil, category, nil)
+}
+
+func (c *Client) DeleteCategory(id string) error {
+	return c.do("DELETE", "/categories/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// AddRecord - Amount of record is in the minor unit of its Currency
+func (c *Client) AddRecord(record server.Record) (string, error) {
+	return c.create("/records", record)
+}
+
+func (c *Client) GetRecord(id string) (server.Record, error) {
+	var record server.Record
+	err := c.do("GET", "/records/"+url.PathEscape(id), nil, nil, &record)
+	return record, err
+}
+
+func (c *Client) UpdateRecord(id string, record server.Record) error {
+	return c.do("PUT", "/records/"+url.PathEscape(id), nil, record, nil)
+}
+
+func (c *Client) DeleteRecord(id string) error {
+	return c.do("DELETE", "/records/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// ListRecords lists the records of the period, or searches them with query.Query
+func (c *Client) ListRecords(query RecordQuery) (RecordList, error) {
+	params := url.Values{"from": {query.From}, "to": {query.To}}
+	if len(query.Query) > 0 {
+		params.Set("q", strings.Join(query.Query, " "))
+	}
+	if query.QueryType != "" {
+		params.Set("queryType", query.QueryType)
+	}
+	if query.AccountID != "" {
+		params.Set("account-id", query.AccountID)
+	}
+
+	var results RecordList
+	err := c.do("GET", "/records", params, nil, &results)
+	return results, err
+}
+
+// TrendReport returns the totals of the period by buckets, with a series per group
+func (c *Client) TrendReport(query TrendQuery) (server.TrendReport, error) {
+	params := url.Values{}
+	for name, value := range map[string]string{"from": query.From, "to": query.To, "granularity": query.Granularity, "group-by": query.GroupBy} {
+		if value != "" {
+			params.Set(name, value)
+		}
+	}
+
+	var report server.TrendReport
+	err := c.do("GET", "/reports/trend", params, nil, &report)
+	return report, err
+}
+
+// CompareReport compares period(YYYY-MM or YYYY) against "previous" or "last-year". Empty values are the defaults of the server.
+func (c *Client) CompareReport(period, against string) (server.CompareReport, error) {
+	params := url.Values{}
+	if period != "" {
+		params.Set("period", period)
+	}
+	if against != "" {
+		params.Set("against", against)
+	}
+
+	var report server.CompareReport
+	err := c.do("GET", "/reports/compare", params, nil, &report)
+	return report, err
+}
+
+// CalendarReport returns the daily totals of year, this year when 0
+func (c *Client) CalendarReport(year int) (server.CalendarReport, error) {
+	params := url.Values{}
+	if year != 0 {
+		params.Set("year", strconv.Itoa(year))
+	}
+
+	var report server.CalendarReport
+	err := c.do("GET", "/reports/calendar", params, nil, &report)
+	return report, err
+}
+
+// SavingsReport returns the savings by month of from ~ to(YYYY-MM-DD), this year when both are empty
+func (c *Client) SavingsReport(from, to string) (server.SavingsReport, error) {
+	params := url.Values{}
+	if from != "" || to != "" {
+		params = url.Values{"from": {from}, "to": {to}}
+	}
+
+	var report server.SavingsReport
+	err := c.do("GET", "/reports/savings-rate", params, nil, &report)
+	return report, err
+}
+
+// Forecast projects the direct accounts for months after today, the server default when 0
+func (c *Client) Forecast(months int) (server.Forecast, error) {
+	params := url.Values{}
+	if months != 0 {
+		params.Set("months", strconv.Itoa(months))
+	}
+
+	var forecast server.Forecast
+	err := c.do("GET", "/reports/forecast", params, nil, &forecast)
+	return forecast, err
+}
+
+func (c *Client) ListRecurringItems() ([]server.RecurringItem, error) {
+	results := []server.RecurringItem{}
+	err := c.do("GET", "/recurring", nil, nil, &results)
+	return results, err
+}
+
+// UpdateRecurringItems replaces all recurring items
+func (c *Client) UpdateRecurringItems(items []server.RecurringItem) error {
+	return c.do("PUT", "/recurring", nil, items, nil)
+}
+
+// TaxDeductionReport estimates the card deduction of year(this year when 0) with the gross salary in KRW
+func (c *Client) TaxDeductionReport(year int, salary string) (server.TaxDeductionReport, error) {
+	params := url.Values{"salary": {salary}}
+	if year != 0 {
+		params.Set("year", strconv.Itoa(year))
+	}
+
+	var report server.TaxDeductionReport
+	err := c.do("GET", "/reports/tax-deduction", params, nil, &report)
+	return report, err
+}
+
+// CardBenefits reports the benefits of a credit account for the bill of month(YYYY-MM), the current bill when empty
+func (c *Client) CardBenefits(id, month string) (server.CardBenefitReport, error) {
+	params := url.Values{}
+	if month != "" {
+		params.Set("month", month)
+	}
+
+	var report server.CardBenefitReport
+	err := c.do("GET", "/accounts/"+url.PathEscape(id)+"/benefits", params, nil, &report)
+	return report, err
+}
+
+func (c *Client) ListRules() ([]server.RecordRule, error) {
+	results := []server.RecordRule{}
+	err := c.do("GET", "/rules", nil, nil, &results)
+	return results, err
+}
+
+// UpdateRules replaces all categorization rules
+func (c *Client) UpdateRules(rules []server.RecordRule) error {
+	return c.do("PUT", "/rules", nil, rules, nil)
+}
+
+// ApplyRules recategorizes the records from to(YYYY-MM-DD) by the rules, only reporting the changes with dryRun.
+// Categories and accounts set before are replaced only with overwrite.
+func (c *Client) ApplyRules(from, to string, dryRun, overwrite bool) (server.RuleApplyResult, error) {
+	params := url.Values{"dry-run": {strconv.FormatBool(dryRun)}, "overwrite": {strconv.FormatBool(overwrite)}}
+	if from != "" || to != "" {
+		params.Set("from", from)
+		params.Set("to", to)
+	}
+
+	var result server.RuleApplyResult
+	err := c.do("POST", "/rules/apply", params, nil, &result)
+	return result, err
+}
+
+func (c *Client) Dashboard() (server.Dashboard, error) {
+	var dashboard server.Dashboard
+	err := c.do("GET", "/reports/dashboard", nil, nil, &dashboard)
+	return dashboard, err
+}
+
+func (c *Client) GetSettings() (server.Settings, error) {
+	var settings server.Settings
+	err := c.do("GET", "/settings", nil, nil, &settings)
+	return settings, err
+}
+
+// UpdateSettings replaces the settings. Missing fields are the defaults of the server.
+func (c *Client) UpdateSettings(settings server.Settings) error {
+	return c.do("PUT", "/settings", nil, settings, nil)
+}
+
+// create posts body and returns the ID of the new entity
+func (c *Client) create(path string, body interface{}) (string, error) {
+	var response map[string]string
+	err := c.do("POST", path, nil, body, &response)
+	return response["id"], err
+}
+
+// do sends a request to the API and decodes the response to result, if not nil.
+// Error responses are returned as *Error.
+func (c *Client) do(method, path string, params url.Values, body interface{}, result interface{}) error {
+	err := c.send(method, path, params, body, result)
+
+	// Server restarted - unlock once and retry
+	if apiError, ok := err.(*Error); ok && apiError.IsLocked() && c.password != "" && path != "/setup/db" {
+		if _, err := c.Unlock(c.password); err != nil {
+			return err
+		}
+		return c.send(method, path, params, body, result)
+	}
+
+	return err
+}
+
+func (c *Client) send(method, path string, params url.Values, body interface{}, result interface{}) error {
+	uri := c.BaseURL + apiPrefix + path
+	if len(params) > 0 {
+		uri += "?" + params.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	request, err := http.NewRequest(method, uri, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := c.HTTPClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		apiError := &Error{StatusCode: response.StatusCode}
+		if err := json.NewDecoder(response.Body).Decode(apiError); err != nil || apiError.Code == "" {
+			apiError.Code = "http"
+			apiError.Message = response.Status
+		}
+		return apiError
+	}
+
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(result)
+}
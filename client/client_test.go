@@ -0,0 +1,142 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"server"
+)
+
+// newTestClient unlocks a server of the memory backend. Key slots are written in a temporary working directory.
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+
+	workDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	backend, indexMode := server.StorageBackend, server.IndexMode
+	argon2Time, argon2Memory := server.Argon2Time, server.Argon2Memory
+	server.StorageBackend, server.IndexMode = "memory", "memory"
+	server.Argon2Time, server.Argon2Memory = 1, 1024
+
+	httpServer := httptest.NewServer(server.NewServeMux())
+	t.Cleanup(func() {
+		httpServer.Close()
+		server.StorageBackend, server.IndexMode = backend, indexMode
+		server.Argon2Time, server.Argon2Memory = argon2Time, argon2Memory
+		os.Chdir(workDir)
+	})
+
+	c := New(httpServer.URL, WithHTTPClient(httpServer.Client()))
+	recoveryKey, err := c.Unlock("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recoveryKey == "" {
+		t.Fatal("no recovery key for a new ledger")
+	}
+
+	return c
+}
+
+func TestClientCRUD(t *testing.T) {
+	c := newTestClient(t)
+
+	accountID, err := c.AddAccount(server.Account{AccountName: "wallet", PayType: "direct"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := c.GetAccount(accountID)
+	if err != nil || account.AccountName != "wallet" {
+		t.Fatalf("got %+v, %v", account, err)
+	}
+	account.AccountName = "bank"
+	if err := c.UpdateAccount(accountID, account); err != nil {
+		t.Fatal(err)
+	}
+	accounts, err := c.ListAccounts()
+	if err != nil || len(accounts) != 1 || accounts[0].AccountName != "bank" {
+		t.Fatalf("got %+v, %v", accounts, err)
+	}
+
+	categoryID, err := c.AddCategory(server.Category{CategoryName: "food"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	categories, err := c.ListCategories()
+	if err != nil || len(categories) != 1 {
+		t.Fatalf("got %+v, %v", categories, err)
+	}
+
+	record := server.Record{TransactionType: "record_type_pay", AccountID: accountID, PayType: "direct", Currency: "USD", Amount: 1250, Category: "food", Description: "lunch", Date: "2026-03-02", Time: "12:10"}
+	recordID, err := c.AddRecord(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.GetRecord(recordID)
+	if err != nil || got.Amount != 1250 || got.Currency != "USD" {
+		t.Fatalf("got %+v, %v", got, err)
+	}
+
+	got.Description = "dinner"
+	if err := c.UpdateRecord(recordID, got); err != nil {
+		t.Fatal(err)
+	}
+	list, err := c.ListRecords(RecordQuery{From: "2026-03-01", To: "2026-03-31", Query: []string{"dinner"}})
+	if err != nil || len(list.Records) != 1 || list.Records[0].ID != recordID {
+		t.Fatalf("got %+v, %v", list, err)
+	}
+
+	for _, err := range []error{c.DeleteRecord(recordID), c.DeleteCategory(categoryID), c.DeleteAccount(accountID)} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	list, err = c.ListRecords(RecordQuery{From: "2026-03-01", To: "2026-03-31"})
+	if err != nil || len(list.Records) != 0 {
+		t.Fatalf("got %+v, %v", list, err)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	c := newTestClient(t)
+
+	_, err := c.GetAccount("account:missing")
+	var apiError *Error
+	if !errors.As(err, &apiError) || !apiError.IsNotFound() || apiError.Code != "not_found" || apiError.Message == "" {
+		t.Fatalf("got %#v, want a not_found error", err)
+	}
+
+	_, err = c.AddAccount(server.Account{PayType: "direct"})
+	if !errors.As(err, &apiError) || apiError.StatusCode != http.StatusBadRequest || apiError.Code != "validation" || apiError.Field != "account-name" {
+		t.Fatalf("got %#v, want a validation error of account-name", err)
+	}
+
+	for name, err := range map[string]error{"account": c.DeleteAccount("account:missing"), "category": c.DeleteCategory("category:missing")} {
+		if !errors.As(err, &apiError) || apiError.StatusCode != http.StatusNotFound || apiError.Code != "not_found" {
+			t.Fatalf("delete a missing %s: got %#v, want a not_found error", name, err)
+		}
+	}
+
+	// Accounts with records are not deleted
+	accountID, err := c.AddAccount(server.Account{AccountName: "wallet", PayType: "direct"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.AddRecord(server.Record{TransactionType: "record_type_pay", AccountID: accountID, PayType: "direct", Currency: "KRW", Amount: 9000, Category: "food", Date: "2026-03-02"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.DeleteAccount(accountID)
+	if !errors.As(err, &apiError) || apiError.StatusCode != http.StatusConflict || apiError.Code != "conflict" {
+		t.Fatalf("delete an account with records: got %#v, want a conflict error", err)
+	}
+}
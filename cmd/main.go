@@ -1,7 +1,15 @@
 package main // import "app-server"
 
-import "server"
+import (
+	"fmt"
+	"os"
+
+	"server"
+)
 
 func main() {
-	server.StartServer()
+	if err := server.RunCLI(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,273 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Settings of the server and the CLI. Later sources win: defaults, config file, LEDGER_* environment variables, flags.
+type Config struct {
+	DataDir            string  `json:"data-dir"`
+	BackupDir          string  `json:"backup-dir"`
+	ListenIP           string  `json:"listen-ip"`
+	ListenPort         string  `json:"listen-port"`
+	StorageBackend     string  `json:"storage-backend"`
+	IndexMode          string  `json:"index-mode"`
+	BadgerBlockCacheMB int64   `json:"badger-block-cache-mb"`
+	BadgerIndexCacheMB int64   `json:"badger-index-cache-mb"`
+	Argon2Time         uint32  `json:"argon2-time"`
+	Argon2MemoryKiB    uint32  `json:"argon2-memory-kib"`
+	Argon2Threads      uint8   `json:"argon2-threads"`
+	ExchangeRate       float64 `json:"exchange-rate"`
+	MigrationDryRun    bool    `json:"migration-dry-run"`
+}
+
+// Effective config and its file, for "config show"
+var currentConfig Config
+var currentConfigFile string
+
+// Files directly in the working directory from before the data directory
+var legacyDataFiles = []string{"badger_data", "keyslots.json", "kdf.json", "salt", "ledger.sqlite"}
+
+func defaultConfig() Config {
+	return Config{
+		ListenIP:           listenIP,
+		ListenPort:         listenPORT,
+		StorageBackend:     StorageBackend,
+		IndexMode:          IndexMode,
+		BadgerBlockCacheMB: 256,
+		BadgerIndexCacheMB: 100,
+		Argon2Time:         Argon2Time,
+		Argon2MemoryKiB:    Argon2Memory,
+		Argon2Threads:      Argon2Threads,
+		ExchangeRate:       ExchangeRate,
+	}
+}
+
+// defaultDataDir is $XDG_DATA_HOME/ledger, or the working directory when it has a ledger from before
+func defaultDataDir() string {
+	for _, name := range legacyDataFiles {
+		if _, err := os.Stat(name); err == nil {
+			return "."
+		}
+	}
+
+	if dataHome := os.Getenv("XDG_DATA_HOME"); dataHome != "" {
+		return filepath.Join(dataHome, "ledger")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".local", "share", "ledger")
+	}
+	return "."
+}
+
+func defaultConfigFile() string {
+	if configDir, err := os.UserConfigDir(); err == nil {
+		return filepath.Join(configDir, "ledger", "config.json")
+	}
+	return ""
+}
+
+// Setting of the config reachable by a flag and an environment variable
+type configField struct {
+	Name  string
+	Usage string
+	Set   func(c *Config, value string) error
+}
+
+func (f configField) env() string {
+	return "LEDGER_" + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+}
+
+func stringSetter(field func(c *Config) *string) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func uintSetter[T uint8 | uint32](bits int, field func(c *Config) *T) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		n, err := strconv.ParseUint(value, 10, bits)
+		*field(c) = T(n)
+		return err
+	}
+}
+
+var configFields = []configField{
+	{"data-dir", "Directory of the ledger, key slots and search index", stringSetter(func(c *Config) *string { return &c.DataDir })},
+	{"backup-dir", "Directory of backups, <data-dir>/backup by default", stringSetter(func(c *Config) *string { return &c.BackupDir })},
+	{"listen-ip", "IP of the web server", stringSetter(func(c *Config) *string { return &c.ListenIP })},
+	{"listen-port", "Port of the web server", stringSetter(func(c *Config) *string { return &c.ListenPort })},
+	{"storage-backend", "badger, sqlite or memory", stringSetter(func(c *Config) *string { return &c.StorageBackend })},
+	{"index-mode", "Search index - encrypted or memory", stringSetter(func(c *Config) *string { return &c.IndexMode })},
+	{"badger-block-cache-mb", "Badger block cache in MB", func(c *Config, value string) (err error) {
+		c.BadgerBlockCacheMB, err = strconv.ParseInt(value, 10, 64)
+		return err
+	}},
+	{"badger-index-cache-mb", "Badger index cache in MB", func(c *Config, value string) (err error) {
+		c.BadgerIndexCacheMB, err = strconv.ParseInt(value, 10, 64)
+		return err
+	}},
+	{"argon2-time", "Argon2id iterations of new keys", uintSetter(32, func(c *Config) *uint32 { return &c.Argon2Time })},
+	{"argon2-memory-kib", "Argon2id memory of new keys in KiB", uintSetter(32, func(c *Config) *uint32 { return &c.Argon2MemoryKiB })},
+	{"argon2-threads", "Argon2id threads of new keys", uintSetter(8, func(c *Config) *uint8 { return &c.Argon2Threads })},
+	{"exchange-rate", "KRW per USD", func(c *Config, value string) (err error) {
+		c.ExchangeRate, err = strconv.ParseFloat(value, 64)
+		return err
+	}},
+	{"migration-dry-run", "Only report pending schema migrations on unlock", func(c *Config, value string) (err error) {
+		c.MigrationDryRun, err = strconv.ParseBool(value)
+		return err
+	}},
+}
+
+// loadConfig reads the config file, the environment and the flags before the command in args.
+// Returns the arguments after the flags.
+func loadConfig(args []string) (Config, string, []string, error) {
+	c := defaultConfig()
+
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	file := flags.String("config", "", "Config file(JSON). LEDGER_CONFIG or "+defaultConfigFile()+" by default")
+	values := map[string]*string{}
+	for _, field := range configFields {
+		values[field.Name] = flags.String(field.Name, "", field.Usage+". "+field.env())
+	}
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), cliUsage+"\nOptions before the command:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return c, "", nil, err
+	}
+
+	// Config file
+	path, required := *file, true
+	if path == "" {
+		path = os.Getenv("LEDGER_CONFIG")
+	}
+	if path == "" {
+		path, required = defaultConfigFile(), false
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case err == nil:
+			decoder := json.NewDecoder(bytes.NewReader(data))
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(&c); err != nil {
+				return c, path, nil, newValidationError("config", "config file %s: %s", path, err)
+			}
+		case errors.Is(err, fs.ErrNotExist) && !required:
+			path = ""
+		default:
+			return c, path, nil, err
+		}
+	}
+
+	// Environment variables, then flags
+	for _, field := range configFields {
+		if value, exist := os.LookupEnv(field.env()); exist {
+			if err := field.Set(&c, value); err != nil {
+				return c, path, nil, newValidationError(field.Name, "%s: %s", field.env(), err)
+			}
+		}
+	}
+	var err error
+	flags.Visit(func(f *flag.Flag) {
+		for _, field := range configFields {
+			if field.Name == f.Name && err == nil {
+				if setErr := field.Set(&c, *values[f.Name]); setErr != nil {
+					err = newValidationError(field.Name, "-%s: %s", field.Name, setErr)
+				}
+			}
+		}
+	})
+	if err != nil {
+		return c, path, nil, err
+	}
+
+	if c.DataDir == "" {
+		c.DataDir = defaultDataDir()
+	}
+	if c.BackupDir == "" {
+		c.BackupDir = filepath.Join(c.DataDir, "backup")
+	}
+
+	return c, path, flags.Args(), validateConfig(c)
+}
+
+func validateConfig(c Config) error {
+	if c.ListenIP != "localhost" && net.ParseIP(c.ListenIP) == nil {
+		return newValidationError("listen-ip", "invalid listen-ip: %s", c.ListenIP)
+	}
+	if port, err := strconv.Atoi(c.ListenPort); err != nil || port < 1 || port > 65535 {
+		return newValidationError("listen-port", "invalid listen-port: %s", c.ListenPort)
+	}
+	switch c.StorageBackend {
+	case storageBackendBadger, storageBackendSQLite, storageBackendMemory:
+	default:
+		return newValidationError("storage-backend", "unknown storage-backend: %s", c.StorageBackend)
+	}
+	if c.IndexMode != indexModeEncrypted && c.IndexMode != indexModeMemory {
+		return newValidationError("index-mode", "unknown index-mode: %s", c.IndexMode)
+	}
+	if c.BadgerBlockCacheMB <= 0 {
+		// Badger needs a block cache with encryption
+		return newValidationError("badger-block-cache-mb", "badger-block-cache-mb must be positive")
+	}
+	if c.BadgerIndexCacheMB < 0 {
+		return newValidationError("badger-index-cache-mb", "badger-index-cache-mb must not be negative")
+	}
+	if c.Argon2Time < 1 || c.Argon2Threads < 1 || c.Argon2MemoryKiB < 8*uint32(c.Argon2Threads) {
+		return newValidationError("argon2-time", "argon2 parameters are too low: time >= 1, threads >= 1, memory >= 8 KiB per thread")
+	}
+	if c.ExchangeRate <= 0 {
+		return newValidationError("exchange-rate", "exchange-rate must be positive")
+	}
+
+	return nil
+}
+
+// applyConfig sets the globals from c and creates the data directory
+func applyConfig(c Config, file string) error {
+	if err := os.MkdirAll(c.DataDir, 0700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	listenIP = c.ListenIP
+	listenPORT = c.ListenPort
+	listenADDR = net.JoinHostPort(listenIP, listenPORT)
+
+	StorageBackend = c.StorageBackend
+	IndexMode = c.IndexMode
+	badgerBlockCacheSize = c.BadgerBlockCacheMB << 20
+	badgerIndexCacheSize = c.BadgerIndexCacheMB << 20
+	Argon2Time = c.Argon2Time
+	Argon2Memory = c.Argon2MemoryKiB
+	Argon2Threads = c.Argon2Threads
+	ExchangeRate = c.ExchangeRate
+	MigrationDryRun = c.MigrationDryRun
+
+	badgerPath = filepath.Join(c.DataDir, "badger_data")
+	sqlitePath = filepath.Join(c.DataDir, "ledger.sqlite")
+	bleveIndexPath = filepath.Join(c.DataDir, "record_index.bleve")
+	keySlotsFile = filepath.Join(c.DataDir, "keyslots.json")
+	kdfHeaderFile = filepath.Join(c.DataDir, "kdf.json")
+	legacySaltFile = filepath.Join(c.DataDir, "salt")
+	backupPath = c.BackupDir
+
+	currentConfig = c
+	currentConfigFile = file
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const (
+	errorCodeBadRequest      = "bad_request"
+	errorCodeValidation      = "validation"
+	errorCodeWrongPassword   = "wrong_password"
+	errorCodeNotFound        = "not_found"
+	errorCodeConflict        = "conflict"
+	errorCodeLocked          = "locked"
+	errorCodeMigrationDryRun = "migration_dry_run"
+	errorCodeInternal        = "internal"
+)
+
+var errorCodeStatus = map[string]int{
+	errorCodeBadRequest:      http.StatusBadRequest,
+	errorCodeValidation:      http.StatusBadRequest,
+	errorCodeWrongPassword:   http.StatusBadRequest,
+	errorCodeNotFound:        http.StatusNotFound,
+	errorCodeConflict:        http.StatusConflict,
+	errorCodeLocked:          http.StatusLocked,
+	errorCodeMigrationDryRun: http.StatusConflict,
+	errorCodeInternal:        http.StatusInternalServerError,
+}
+
+// Typed error of the store layer. Code decides the HTTP status, Field is the JSON field at fault.
+type AppError struct {
+	Code       string            `json:"code"`
+	Message    string            `json:"message"`
+	Field      string            `json:"field,omitempty"`
+	Migrations []MigrationReport `json:"migrations,omitempty"` // Reports of a migration dry run
+}
+
+func (e *AppError) Error() string {
+	return e.Message
+}
+
+// Ledger is not unlocked yet
+var errLedgerLocked = &AppError{Code: errorCodeLocked, Message: "Enter password first"}
+
+func newValidationError(field, format string, args ...interface{}) error {
+	return &AppError{Code: errorCodeValidation, Message: fmt.Sprintf(format, args...), Field: field}
+}
+
+func newBadRequestError(format string, args ...interface{}) error {
+	return &AppError{Code: errorCodeBadRequest, Message: fmt.Sprintf(format, args...)}
+}
+
+func newConflictError(format string, args ...interface{}) error {
+	return &AppError{Code: errorCodeConflict, Message: fmt.Sprintf(format, args...)}
+}
+
+func errKeyNotFound(id string) error {
+	return &AppError{Code: errorCodeNotFound, Message: fmt.Sprintf("Key not found: %s", id), Field: "id"}
+}
+
+func errKeyExists(id string) error {
+	return newConflictError("Key already exists: %s", id)
+}
+
+// writeError writes the error envelope {code, message, field}.
+// Untyped errors are internal, message replaces their text and the error is printed instead.
+func writeError(w http.ResponseWriter, err error, message string) {
+	appError := &AppError{Code: errorCodeInternal, Message: message}
+	if !errors.As(err, &appError) {
+		fmt.Println(message+":", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(errorCodeStatus[appError.Code])
+	json.NewEncoder(w).Encode(appError)
+}
@@ -2,7 +2,6 @@ package server
 
 import (
 	"github.com/blevesearch/bleve/v2"
-	"github.com/dgraph-io/badger/v3"
 )
 
 // var listenIP = "0.0.0.0"
@@ -10,7 +9,37 @@ var listenIP = "127.0.0.1"
 var listenPORT = "12480"
 var listenADDR = listenIP + ":" + listenPORT
 
-var db *badger.DB
+var ledger LedgerStore
 var bleveIndex bleve.Index
 
+// badger(encrypted), sqlite(plaintext, for SQL tools) or memory
+var StorageBackend = storageBackendBadger
+
+// Paths and settings below are defaults, set by applyConfig from the config
+var badgerPath = "./badger_data"
+var sqlitePath = "ledger.sqlite"
+var backupPath = "./backup"
+
+// Only report pending schema migrations, the ledger is not opened
+var MigrationDryRun = false
+var bleveIndexPath = "record_index.bleve"
+var keySlotsFile = "keyslots.json"
+var kdfHeaderFile = "kdf.json" // legacy, before key slots
+var legacySaltFile = "salt"    // legacy, before kdf header
+
+var badgerBlockCacheSize int64 = 256 << 20
+var badgerIndexCacheSize int64 = 100 << 20
+
+// Argon2id parameters for newly derived keys. Raising them rewraps key slots on next unlock.
+var Argon2Time uint32 = 3
+var Argon2Memory uint32 = 64 * 1024 // KiB
+var Argon2Threads uint8 = 4
+
+// Search index storage - memory(rebuilt on every unlock) or encrypted(badger, key derived from the master key)
+var IndexMode = indexModeEncrypted
+var indexEncryptionKey []byte
+var indexedVersionKey = []byte("badger-version")
+var indexBatchSize = 1000
+var searchPageSize = 5000 // Hits of a bleve search request
+
 var ExchangeRate float64 = 1350
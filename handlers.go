@@ -3,10 +3,20 @@ package server
 import (
 	"encoding/json"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// getIDParam reads {id} of the path, or "id" of the query for the old routes
+func getIDParam(r *http.Request) string {
+	if id := r.PathValue("id"); id != "" {
+		return id
+	}
+	return r.URL.Query().Get("id")
+}
+
 func healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("It works!"))
@@ -15,68 +25,116 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 func databaseSetupHandler(w http.ResponseWriter, r *http.Request) {
 	password := r.URL.Query().Get("password")
 	if password == "" {
-		http.Error(w, "Password is required", http.StatusBadRequest)
+		writeError(w, newValidationError("password", "password is required"), "")
 		return
 	}
 
-	if bleveIndex != nil {
-		bleveIndex.Close()
-		bleveIndex = nil
-	}
-	if db != nil {
-		db.Close()
-		db = nil
-	}
+	closeBleveIndex()
+	closeLedgerStore()
 
-	err := initBadgerDB(password)
+	recoveryKey, err := initBadgerDB(password)
 	if err != nil {
-		httpStatus := http.StatusInternalServerError
+		// Reported by badger for a key of another registry
 		if strings.Contains(err.Error(), "Encryption key mismatch") {
-			httpStatus = http.StatusBadRequest
+			err = errWrongPassword
 		}
 
-		http.Error(w, "Failed to initialize database", httpStatus)
+		writeError(w, err, "Failed to initialize database")
 		return
 	}
 
 	err = initBleveIndex()
 	if err != nil {
-		http.Error(w, "Failed to initialize search index", http.StatusInternalServerError)
+		writeError(w, err, "Failed to initialize search index")
 		return
 	}
 
+	response := map[string]string{"status": "success"}
+	if recoveryKey != "" {
+		response["recovery-key"] = recoveryKey
+	}
+
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+	json.NewEncoder(w).Encode(response)
 }
 
 func databasePasswordChangeHandler(w http.ResponseWriter, r *http.Request) {
 	var err error
 
-	if bleveIndex != nil {
-		bleveIndex.Close()
-		bleveIndex = nil
-	}
-	if db != nil {
-		db.Close()
-		db = nil
-	}
-
 	oldPassword := r.URL.Query().Get("old-password")
 	newPassword := r.URL.Query().Get("new-password")
 
+	// Only the key slot is wrapped again - the master key and the open ledger stay
 	err = changePassword(oldPassword, newPassword)
 	if err != nil {
-		http.Error(w, "Failed to change password", http.StatusInternalServerError)
+		writeError(w, err, "Failed to change password")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+func getKeySlotListHandler(w http.ResponseWriter, r *http.Request) {
+	keySlots, err := getKeySlotList()
+	if err != nil {
+		writeError(w, err, "Failed to get key slots")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(keySlots)
+}
+
+func addKeySlotHandler(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Password    string `json:"password"`
+		Type        string `json:"type"` // password, recovery
+		NewPassword string `json:"new-password,omitempty"`
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		writeError(w, newBadRequestError("Invalid request body"), "")
+		return
+	}
+
+	slot, recoveryKey, err := addKeySlot(request.Password, request.Type, request.NewPassword)
+	if err != nil {
+		writeError(w, err, "Failed to add key slot")
+		return
+	}
+
+	response := map[string]string{"status": "success", "id": slot.ID}
+	if recoveryKey != "" {
+		response["recovery-key"] = recoveryKey
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+func deleteKeySlotHandler(w http.ResponseWriter, r *http.Request) {
+	slotID := getIDParam(r)
+	if slotID == "" {
+		writeError(w, newValidationError("id", "'id' is required"), "")
 		return
 	}
 
-	if bleveIndex != nil {
-		bleveIndex.Close()
-		bleveIndex = nil
+	// The password is in the body, not to be left in access logs
+	var request struct {
+		Password string `json:"password"`
 	}
-	if db != nil {
-		db.Close()
-		db = nil
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		writeError(w, newBadRequestError("Invalid request body"), "")
+		return
+	}
+
+	err = removeKeySlot(request.Password, slotID)
+	if err != nil {
+		writeError(w, err, "Failed to delete key slot")
+		return
 	}
 
 	w.WriteHeader(http.StatusOK)
@@ -86,47 +144,42 @@ func databasePasswordChangeHandler(w http.ResponseWriter, r *http.Request) {
 func addAccountHandler(w http.ResponseWriter, r *http.Request) {
 	var account Account
 
-	if db == nil {
-		http.Error(w, "Enter password first", http.StatusBadRequest)
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
 		return
 	}
 
 	err := json.NewDecoder(r.Body).Decode(&account)
 	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, newBadRequestError("Invalid request body"), "")
 		return
 	}
 
-	err = addAccount(account)
+	id, err := addAccount(account)
 	if err != nil {
-		httpStatus := http.StatusInternalServerError
-		if strings.Contains(err.Error(), "required") {
-			httpStatus = http.StatusBadRequest
-		}
-
-		http.Error(w, "Failed to add account", httpStatus)
+		writeError(w, err, "Failed to add account")
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "id": id})
 }
 
 func deleteAccountHandler(w http.ResponseWriter, r *http.Request) {
-	if db == nil {
-		http.Error(w, "Enter password first", http.StatusBadRequest)
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
 		return
 	}
 
-	accountID := r.URL.Query().Get("id")
+	accountID := getIDParam(r)
 	if accountID == "" {
-		http.Error(w, "'id' is required", http.StatusBadRequest)
+		writeError(w, newValidationError("id", "'id' is required"), "")
 		return
 	}
 
 	err := deleteAccount(accountID)
 	if err != nil {
-		http.Error(w, "Failed to delete account", http.StatusInternalServerError)
+		writeError(w, err, "Failed to delete account")
 		return
 	}
 
@@ -135,37 +188,33 @@ func deleteAccountHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func updateAccountHandler(w http.ResponseWriter, r *http.Request) {
-	if db == nil {
-		http.Error(w, "Enter password first", http.StatusBadRequest)
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
 		return
 	}
 
-	accountID := r.URL.Query().Get("id")
+	accountID := getIDParam(r)
 	if accountID == "" {
-		http.Error(w, "'id' is required", http.StatusBadRequest)
+		writeError(w, newValidationError("id", "'id' is required"), "")
 		return
 	}
 
 	var updatedAccount Account
 	err := json.NewDecoder(r.Body).Decode(&updatedAccount)
 	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, newBadRequestError("Invalid request body"), "")
 		return
 	}
 
 	err = validateAccount(updatedAccount)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err, "Invalid request")
 		return
 	}
 
 	err = updateAccount(accountID, updatedAccount)
 	if err != nil {
-		httpStatus := http.StatusInternalServerError
-		if strings.Contains(err.Error(), "Key not found") {
-			httpStatus = http.StatusBadRequest
-		}
-		http.Error(w, "Failed to update account", httpStatus)
+		writeError(w, err, "Failed to update account")
 		return
 	}
 
@@ -173,15 +222,37 @@ func updateAccountHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 }
 
+func getAccountHandler(w http.ResponseWriter, r *http.Request) {
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
+		return
+	}
+
+	accountID := getIDParam(r)
+	if accountID == "" {
+		writeError(w, newValidationError("id", "'id' is required"), "")
+		return
+	}
+
+	account, err := getAccount(accountID)
+	if err != nil {
+		writeError(w, err, "Failed to get account")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(account)
+}
+
 func getAccountListHandler(w http.ResponseWriter, r *http.Request) {
-	if db == nil {
-		http.Error(w, "Enter password first", http.StatusBadRequest)
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
 		return
 	}
 
 	accounts, err := getAccountList()
 	if err != nil {
-		http.Error(w, "Failed to get accounts", http.StatusInternalServerError)
+		writeError(w, err, "Failed to get accounts")
 		return
 	}
 
@@ -192,47 +263,42 @@ func getAccountListHandler(w http.ResponseWriter, r *http.Request) {
 func addCategoryHandler(w http.ResponseWriter, r *http.Request) {
 	var category Category
 
-	if db == nil {
-		http.Error(w, "Enter password first", http.StatusBadRequest)
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
 		return
 	}
 
 	err := json.NewDecoder(r.Body).Decode(&category)
 	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, newBadRequestError("Invalid request body"), "")
 		return
 	}
 
-	err = addCategory(category)
+	id, err := addCategory(category)
 	if err != nil {
-		httpStatus := http.StatusInternalServerError
-		if strings.Contains(err.Error(), "required") {
-			httpStatus = http.StatusBadRequest
-		}
-
-		http.Error(w, "Failed to add category", httpStatus)
+		writeError(w, err, "Failed to add category")
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "id": id})
 }
 
 func deleteCategoryHandler(w http.ResponseWriter, r *http.Request) {
-	if db == nil {
-		http.Error(w, "Enter password first", http.StatusBadRequest)
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
 		return
 	}
 
-	categoryID := r.URL.Query().Get("id")
+	categoryID := getIDParam(r)
 	if categoryID == "" {
-		http.Error(w, "'id' is required", http.StatusBadRequest)
+		writeError(w, newValidationError("id", "'id' is required"), "")
 		return
 	}
 
 	err := deleteCategory(categoryID)
 	if err != nil {
-		http.Error(w, "Failed to delete category", http.StatusInternalServerError)
+		writeError(w, err, "Failed to delete category")
 		return
 	}
 
@@ -241,37 +307,33 @@ func deleteCategoryHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func updateCategoryHandler(w http.ResponseWriter, r *http.Request) {
-	if db == nil {
-		http.Error(w, "Enter password first", http.StatusBadRequest)
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
 		return
 	}
 
-	categoryID := r.URL.Query().Get("id")
+	categoryID := getIDParam(r)
 	if categoryID == "" {
-		http.Error(w, "'id' is required", http.StatusBadRequest)
+		writeError(w, newValidationError("id", "'id' is required"), "")
 		return
 	}
 
 	var updatedCategory Category
 	err := json.NewDecoder(r.Body).Decode(&updatedCategory)
 	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, newBadRequestError("Invalid request body"), "")
 		return
 	}
 
 	err = validateCategory(updatedCategory)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err, "Invalid request")
 		return
 	}
 
 	err = updateCategory(categoryID, updatedCategory)
 	if err != nil {
-		httpStatus := http.StatusInternalServerError
-		if strings.Contains(err.Error(), "Key not found") {
-			httpStatus = http.StatusBadRequest
-		}
-		http.Error(w, "Failed to update category", httpStatus)
+		writeError(w, err, "Failed to update category")
 		return
 	}
 
@@ -279,15 +341,37 @@ func updateCategoryHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 }
 
+func getCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
+		return
+	}
+
+	categoryID := getIDParam(r)
+	if categoryID == "" {
+		writeError(w, newValidationError("id", "'id' is required"), "")
+		return
+	}
+
+	category, err := getCategory(categoryID)
+	if err != nil {
+		writeError(w, err, "Failed to get category")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(category)
+}
+
 func getCategoryListHandler(w http.ResponseWriter, r *http.Request) {
-	if db == nil {
-		http.Error(w, "Enter password first", http.StatusBadRequest)
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
 		return
 	}
 
 	category, err := getCategoryList()
 	if err != nil {
-		http.Error(w, "Failed to get categories", http.StatusInternalServerError)
+		writeError(w, err, "Failed to get categories")
 		return
 	}
 
@@ -296,8 +380,8 @@ func getCategoryListHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func addRecordHandler(w http.ResponseWriter, r *http.Request) {
-	if db == nil {
-		http.Error(w, "Enter password first", http.StatusBadRequest)
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
 		return
 	}
 
@@ -305,40 +389,35 @@ func addRecordHandler(w http.ResponseWriter, r *http.Request) {
 
 	err := json.NewDecoder(r.Body).Decode(&record)
 	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, newBadRequestError("Invalid request body"), "")
 		return
 	}
 
-	err = addRecord(record)
+	id, err := addRecord(record)
 	if err != nil {
-		httpStatus := http.StatusInternalServerError
-		if strings.Contains(err.Error(), "required") {
-			httpStatus = http.StatusBadRequest
-		}
-
-		http.Error(w, "Failed to add record", httpStatus)
+		writeError(w, err, "Failed to add record")
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "id": id})
 }
 
 func deleteRecordHandler(w http.ResponseWriter, r *http.Request) {
-	if db == nil {
-		http.Error(w, "Enter password first", http.StatusBadRequest)
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
 		return
 	}
 
-	recordID := r.URL.Query().Get("id")
+	recordID := getIDParam(r)
 	if recordID == "" {
-		http.Error(w, "'id' is required", http.StatusBadRequest)
+		writeError(w, newValidationError("id", "'id' is required"), "")
 		return
 	}
 
 	err := deleteRecord(recordID)
 	if err != nil {
-		http.Error(w, "Failed to delete record", http.StatusInternalServerError)
+		writeError(w, err, "Failed to delete record")
 		return
 	}
 
@@ -347,37 +426,33 @@ func deleteRecordHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func updateRecordHandler(w http.ResponseWriter, r *http.Request) {
-	if db == nil {
-		http.Error(w, "Enter password first", http.StatusBadRequest)
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
 		return
 	}
 
-	recordID := r.URL.Query().Get("id")
+	recordID := getIDParam(r)
 	if recordID == "" {
-		http.Error(w, "'id' is required", http.StatusBadRequest)
+		writeError(w, newValidationError("id", "'id' is required"), "")
 		return
 	}
 
 	var updatedRecord Record
 	err := json.NewDecoder(r.Body).Decode(&updatedRecord)
 	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, newBadRequestError("Invalid request body"), "")
 		return
 	}
 
 	err = validateRecord(updatedRecord)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err, "Invalid request")
 		return
 	}
 
 	err = updateRecord(recordID, updatedRecord)
 	if err != nil {
-		httpStatus := http.StatusInternalServerError
-		if strings.Contains(err.Error(), "Key not found") {
-			httpStatus = http.StatusBadRequest
-		}
-		http.Error(w, "Failed to update record", httpStatus)
+		writeError(w, err, "Failed to update record")
 		return
 	}
 
@@ -386,18 +461,37 @@ func updateRecordHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func getRecordHandler(w http.ResponseWriter, r *http.Request) {
-	if db == nil {
-		http.Error(w, "Enter password first", http.StatusBadRequest)
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
 		return
 	}
 
-	query := r.URL.Query().Get("q")
-	if query == "" {
-		http.Error(w, "Query parameter 'q' is required", http.StatusBadRequest)
+	recordID := getIDParam(r)
+	if recordID == "" {
+		writeError(w, newValidationError("id", "'id' is required"), "")
+		return
+	}
+
+	record, err := getRecord(recordID)
+	if err != nil {
+		writeError(w, err, "Failed to get record")
 		return
 	}
 
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(record)
+}
+
+func getRecordListHandler(w http.ResponseWriter, r *http.Request) {
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
+		return
+	}
+
+	// Optional. Without 'q', records are listed by the date index.
+	query := r.URL.Query().Get("q")
 	queries := strings.Fields(query)
+	accountID := r.URL.Query().Get("account-id")
 
 	queryType := r.URL.Query().Get("queryType")
 	if queryType != "AND" && queryType != "OR" {
@@ -420,14 +514,23 @@ func getRecordHandler(w http.ResponseWriter, r *http.Request) {
 	startDate, _ := time.Parse("2006-01-02 15:04:05", from+" 00:00:00")
 	endDate, _ := time.Parse("2006-01-02 15:04:05", to+" 23:59:59")
 
-	if from == "" || to == "" {
-		http.Error(w, "Both from and to are required", http.StatusBadRequest)
+	// Without a period, the current month of the settings
+	if from == "" && to == "" {
+		settings, err := getSettings()
+		if err != nil {
+			writeError(w, err, "Failed to get settings")
+			return
+		}
+		now := time.Now()
+		startDate, endDate = getFiscalMonth(settings, time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC))
+	} else if from == "" || to == "" {
+		writeError(w, newValidationError("from", "Both from and to are required"), "")
 		return
 	}
 
-	records, stats, statsCredit, sumPay, sumCreditPay, sumIncome, err := getRecords(queries, queryType, startDate, endDate)
+	records, stats, statsCredit, statsIncome, sumPay, sumCreditPay, sumIncome, err := getRecords(queries, queryType, accountID, startDate, endDate)
 	if err != nil {
-		http.Error(w, "Failed to search records", http.StatusInternalServerError)
+		writeError(w, err, "Failed to search records")
 		return
 	}
 
@@ -435,13 +538,15 @@ func getRecordHandler(w http.ResponseWriter, r *http.Request) {
 		Records      []Record        `json:"records"`
 		Stats        map[string]Stat `json:"stats"`
 		StatsCredit  map[string]Stat `json:"stats-credit"`
-		SumPay       float64         `json:"sum-pay"`
-		SumCreditPay float64         `json:"sum-credit-pay"`
-		SumIncome    float64         `json:"sum-income"`
+		StatsIncome  map[string]Stat `json:"stats-income"`
+		SumPay       Total           `json:"sum-pay"`
+		SumCreditPay Total           `json:"sum-credit-pay"`
+		SumIncome    Total           `json:"sum-income"`
 	}{
 		Records:      records,
 		Stats:        stats,
 		StatsCredit:  statsCredit,
+		StatsIncome:  statsIncome,
 		SumPay:       sumPay,
 		SumCreditPay: sumCreditPay,
 		SumIncome:    sumIncome,
@@ -450,3 +555,430 @@ func getRecordHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
+
+func getSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
+		return
+	}
+
+	settings, err := getSettings()
+	if err != nil {
+		writeError(w, err, "Failed to get settings")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(settings)
+}
+
+func updateSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
+		return
+	}
+
+	// Fields missing in the body keep the defaults
+	settings := defaultSettings()
+	settings.ExchangeRates = nil
+	err := json.NewDecoder(r.Body).Decode(&settings)
+	if err != nil {
+		writeError(w, newBadRequestError("Invalid request body"), "")
+		return
+	}
+	if settings.ExchangeRates == nil && settings.BaseCurrency == defaultSettings().BaseCurrency {
+		settings.ExchangeRates = defaultSettings().ExchangeRates
+	}
+
+	err = updateSettings(settings)
+	if err != nil {
+		writeError(w, err, "Failed to update settings")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// getReportPeriod reads from and to of a report. Without them, the current year of the settings.
+func getReportPeriod(r *http.Request) (time.Time, time.Time, error) {
+	from := r.URL.Query().Get("from")
+	to := r.URL.Query().Get("to")
+	startDate, err1 := time.Parse("2006-01-02 15:04:05", from+" 00:00:00")
+	endDate, err2 := time.Parse("2006-01-02 15:04:05", to+" 23:59:59")
+
+	if from == "" && to == "" {
+		settings, err := getSettings()
+		if err != nil {
+			return startDate, endDate, err
+		}
+		now := time.Now()
+		startDate = getTrendBucketStart(settings, "year", time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC))
+		endDate = startDate.AddDate(1, 0, 0).Add(-time.Second)
+	} else if err1 != nil || err2 != nil || endDate.Before(startDate) {
+		return startDate, endDate, newValidationError("from", "from and to must be dates(YYYY-MM-DD), from before to")
+	}
+
+	return startDate, endDate, nil
+}
+
+// getBoolQuery reads the query parameter name as true or false, false when missing
+func getBoolQuery(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+	result, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, newValidationError(name, "%s must be true or false: %s", name, value)
+	}
+	return result, nil
+}
+
+func getTrendReportHandler(w http.ResponseWriter, r *http.Request) {
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
+		return
+	}
+
+	granularity := r.URL.Query().Get("granularity")
+	if granularity == "" {
+		granularity = "month"
+	}
+	if !slices.Contains(trendGranularities, granularity) {
+		writeError(w, newValidationError("granularity", "granularity must be one of %s", strings.Join(trendGranularities, ", ")), "")
+		return
+	}
+	groupBy := r.URL.Query().Get("group-by")
+	if groupBy == "" {
+		groupBy = "type"
+	}
+	if !slices.Contains(trendGroupBys, groupBy) {
+		writeError(w, newValidationError("group-by", "group-by must be one of %s", strings.Join(trendGroupBys, ", ")), "")
+		return
+	}
+
+	startDate, endDate, err := getReportPeriod(r)
+	if err != nil {
+		writeError(w, err, "Failed to get settings")
+		return
+	}
+
+	report, err := getTrendReport(granularity, groupBy, startDate, endDate)
+	if err != nil {
+		writeError(w, err, "Failed to get trend report")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+func getCompareReportHandler(w http.ResponseWriter, r *http.Request) {
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
+		return
+	}
+
+	against := r.URL.Query().Get("against")
+	if against == "" {
+		against = "previous"
+	}
+	if !slices.Contains(compareAgainsts, against) {
+		writeError(w, newValidationError("against", "against must be one of %s", strings.Join(compareAgainsts, ", ")), "")
+		return
+	}
+
+	// Without a period, the current month of the settings
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		settings, err := getSettings()
+		if err != nil {
+			writeError(w, err, "Failed to get settings")
+			return
+		}
+		now := time.Now()
+		start, _ := getFiscalMonth(settings, time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC))
+		period = start.Format("2006-01")
+	}
+
+	report, err := getCompareReport(period, against)
+	if err != nil {
+		writeError(w, err, "Failed to get compare report")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+func getDashboardHandler(w http.ResponseWriter, r *http.Request) {
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
+		return
+	}
+
+	now := time.Now()
+	dashboard, err := getDashboard(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		writeError(w, err, "Failed to get dashboard")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dashboard)
+}
+
+func getCalendarReportHandler(w http.ResponseWriter, r *http.Request) {
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
+		return
+	}
+
+	// This year by default
+	year := time.Now().Year()
+	if value := r.URL.Query().Get("year"); value != "" {
+		var err error
+		year, err = strconv.Atoi(value)
+		if err != nil || year < 1 || year > 9999 {
+			writeError(w, newValidationError("year", "year must be YYYY: %s", value), "")
+			return
+		}
+	}
+
+	report, err := getCalendarReport(year)
+	if err != nil {
+		writeError(w, err, "Failed to get calendar report")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+func getSavingsReportHandler(w http.ResponseWriter, r *http.Request) {
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
+		return
+	}
+
+	startDate, endDate, err := getReportPeriod(r)
+	if err != nil {
+		writeError(w, err, "Failed to get settings")
+		return
+	}
+
+	report, err := getSavingsReport(startDate, endDate)
+	if err != nil {
+		writeError(w, err, "Failed to get savings report")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+func getForecastHandler(w http.ResponseWriter, r *http.Request) {
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
+		return
+	}
+
+	months := 6
+	if value := r.URL.Query().Get("months"); value != "" {
+		var err error
+		months, err = strconv.Atoi(value)
+		if err != nil || months < 1 || months > maxForecastMonths {
+			writeError(w, newValidationError("months", "months must be between 1 and %d", maxForecastMonths), "")
+			return
+		}
+	}
+
+	now := time.Now()
+	forecast, err := getForecast(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC), months)
+	if err != nil {
+		writeError(w, err, "Failed to get forecast")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(forecast)
+}
+
+func getRecurringItemsHandler(w http.ResponseWriter, r *http.Request) {
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
+		return
+	}
+
+	items, err := getRecurringItems()
+	if err != nil {
+		writeError(w, err, "Failed to get recurring items")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(items)
+}
+
+func updateRecurringItemsHandler(w http.ResponseWriter, r *http.Request) {
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
+		return
+	}
+
+	var items []RecurringItem
+	err := json.NewDecoder(r.Body).Decode(&items)
+	if err != nil {
+		writeError(w, newBadRequestError("Invalid request body"), "")
+		return
+	}
+
+	err = updateRecurringItems(items)
+	if err != nil {
+		writeError(w, err, "Failed to update recurring items")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+func getTaxDeductionReportHandler(w http.ResponseWriter, r *http.Request) {
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
+		return
+	}
+
+	// This year by default
+	year := time.Now().Year()
+	if value := r.URL.Query().Get("year"); value != "" {
+		var err error
+		year, err = strconv.Atoi(value)
+		if err != nil || year < 1 || year > 9999 {
+			writeError(w, newValidationError("year", "year must be YYYY: %s", value), "")
+			return
+		}
+	}
+
+	salary, err := parseDecimal(r.URL.Query().Get("salary"), totalDecimals)
+	if err != nil || salary <= 0 {
+		writeError(w, newValidationError("salary", "salary is required, the gross salary of the year in KRW"), "")
+		return
+	}
+
+	report, err := getTaxDeductionReport(year, Total(salary))
+	if err != nil {
+		writeError(w, err, "Failed to get tax deduction report")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+func getCardBenefitReportHandler(w http.ResponseWriter, r *http.Request) {
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
+		return
+	}
+
+	accountID := getIDParam(r)
+	if accountID == "" {
+		writeError(w, newValidationError("id", "'id' is required"), "")
+		return
+	}
+
+	// The bill of the current use period by default
+	var month time.Time
+	if value := r.URL.Query().Get("month"); value != "" {
+		var err error
+		month, err = time.Parse("2006-01", value)
+		if err != nil {
+			writeError(w, newValidationError("month", "month must be YYYY-MM: %s", value), "")
+			return
+		}
+	}
+
+	now := time.Now()
+	report, err := getCardBenefitReport(accountID, month, time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		writeError(w, err, "Failed to get card benefits")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+func getRecordRulesHandler(w http.ResponseWriter, r *http.Request) {
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
+		return
+	}
+
+	rules, err := getRecordRules()
+	if err != nil {
+		writeError(w, err, "Failed to get rules")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(rules)
+}
+
+func updateRecordRulesHandler(w http.ResponseWriter, r *http.Request) {
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
+		return
+	}
+
+	var rules []RecordRule
+	err := json.NewDecoder(r.Body).Decode(&rules)
+	if err != nil {
+		writeError(w, newBadRequestError("Invalid request body"), "")
+		return
+	}
+
+	err = updateRecordRules(rules)
+	if err != nil {
+		writeError(w, err, "Failed to update rules")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+func applyRecordRulesHandler(w http.ResponseWriter, r *http.Request) {
+	if ledger == nil {
+		writeError(w, errLedgerLocked, "")
+		return
+	}
+
+	startDate, endDate, err := getReportPeriod(r)
+	if err != nil {
+		writeError(w, err, "")
+		return
+	}
+
+	dryRun, err := getBoolQuery(r, "dry-run")
+	if err != nil {
+		writeError(w, err, "")
+		return
+	}
+	overwrite, err := getBoolQuery(r, "overwrite")
+	if err != nil {
+		writeError(w, err, "")
+		return
+	}
+
+	result, err := applyRulesToRecords(startDate, endDate, dryRun, overwrite)
+	if err != nil {
+		writeError(w, err, "Failed to apply rules")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
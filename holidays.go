@@ -0,0 +1,167 @@
+package server
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Korean public holidays by month-day, the same every year
+var fixedHolidays = map[string]string{
+	"01-01": "신정",
+	"03-01": "삼일절",
+	"05-05": "어린이날",
+	"06-06": "현충일",
+	"08-15": "광복절",
+	"10-03": "개천절",
+	"10-09": "한글날",
+	"12-25": "기독탄신일",
+}
+
+// Fixed holidays with a substitute holiday when on a weekend, by the rules from 2023
+var substitutedFixedHolidays = []string{"03-01", "05-05", "08-15", "10-03", "10-09", "12-25"}
+
+// Lunar holidays(설날, 부처님오신날, 추석) with their substitute holidays, elections and temporary holidays.
+// Add later years or missing days to "holidays" of the settings.
+var lunarHolidays = map[string]string{
+	"2024-02-09": "설날", "2024-02-10": "설날", "2024-02-11": "설날", "2024-02-12": "대체공휴일",
+	"2024-04-10": "국회의원 선거", "2024-05-15": "부처님오신날",
+	"2024-09-16": "추석", "2024-09-17": "추석", "2024-09-18": "추석", "2024-10-01": "임시공휴일",
+
+	"2025-01-27": "임시공휴일", "2025-01-28": "설날", "2025-01-29": "설날", "2025-01-30": "설날",
+	"2025-05-05": "부처님오신날", "2025-05-06": "대체공휴일", "2025-06-03": "대통령 선거",
+	"2025-10-05": "추석", "2025-10-06": "추석", "2025-10-07": "추석", "2025-10-08": "대체공휴일",
+
+	"2026-02-16": "설날", "2026-02-17": "설날", "2026-02-18": "설날",
+	"2026-05-24": "부처님오신날", "2026-05-25": "대체공휴일", "2026-06-03": "지방선거",
+	"2026-09-24": "추석", "2026-09-25": "추석", "2026-09-26": "추석",
+
+	"2027-02-06": "설날", "2027-02-07": "설날", "2027-02-08": "설날", "2027-02-09": "대체공휴일",
+	"2027-05-13": "부처님오신날",
+	"2027-09-14": "추석", "2027-09-15": "추석", "2027-09-16": "추석",
+
+	"2028-01-25": "설날", "2028-01-26": "설날", "2028-01-27": "설날",
+	"2028-05-02": "부처님오신날",
+	"2028-10-02": "추석", "2028-10-03": "추석", "2028-10-04": "추석", "2028-10-05": "대체공휴일",
+
+	"2029-02-12": "설날", "2029-02-13": "설날", "2029-02-14": "설날",
+	"2029-05-20": "부처님오신날", "2029-05-21": "대체공휴일",
+	"2029-09-21": "추석", "2029-09-22": "추석", "2029-09-23": "추석", "2029-09-24": "대체공휴일",
+
+	"2030-02-02": "설날", "2030-02-03": "설날", "2030-02-04": "설날", "2030-02-05": "대체공휴일",
+	"2030-05-09": "부처님오신날",
+	"2030-09-11": "추석", "2030-09-12": "추석", "2030-09-13": "추석",
+}
+
+// Holidays by year, and the holidays of the settings
+var holidayCache = map[int]map[string]string{}
+var customHolidayCache map[string]bool
+var holidayCacheMutex sync.Mutex
+
+// invalidateHolidayCache reloads the holidays of the settings on the next use
+func invalidateHolidayCache() {
+	holidayCacheMutex.Lock()
+	customHolidayCache = nil
+	holidayCacheMutex.Unlock()
+}
+
+// getHolidaysOfYear returns the Korean public holidays of year by date, with the substitute holidays of the fixed ones
+func getHolidaysOfYear(year int) map[string]string {
+	holidays := map[string]string{}
+	for monthDay, name := range fixedHolidays {
+		holidays[strconv.Itoa(year)+"-"+monthDay] = name
+	}
+	for date, name := range lunarHolidays {
+		if date[:4] == strconv.Itoa(year) {
+			holidays[date] = name
+		}
+	}
+
+	// Substitute holiday: the first day after the weekend, which is not a holiday
+	dates := []string{}
+	for _, monthDay := range substitutedFixedHolidays {
+		dates = append(dates, strconv.Itoa(year)+"-"+monthDay)
+	}
+	sort.Strings(dates)
+	for _, date := range dates {
+		day, _ := time.Parse("2006-01-02", date)
+		if !isWeekend(day) {
+			continue
+		}
+		for isWeekend(day) || holidays[day.Format("2006-01-02")] != "" {
+			day = day.AddDate(0, 0, 1)
+		}
+		holidays[day.Format("2006-01-02")] = "대체공휴일"
+	}
+
+	return holidays
+}
+
+func isWeekend(date time.Time) bool {
+	return date.Weekday() == time.Saturday || date.Weekday() == time.Sunday
+}
+
+// isHoliday tells whether date is a Korean public holiday or a holiday of the settings
+func isHoliday(date time.Time) bool {
+	holidayCacheMutex.Lock()
+	defer holidayCacheMutex.Unlock()
+
+	holidays, exist := holidayCache[date.Year()]
+	if !exist {
+		holidays = getHolidaysOfYear(date.Year())
+		holidayCache[date.Year()] = holidays
+	}
+
+	if customHolidayCache == nil {
+		customHolidayCache = map[string]bool{}
+		if ledger != nil {
+			if settings, err := getSettings(); err == nil {
+				for _, holiday := range settings.Holidays {
+					customHolidayCache[holiday] = true
+				}
+			}
+		}
+	}
+
+	day := date.Format("2006-01-02")
+	return holidays[day] != "" || customHolidayCache[day]
+}
+
+// getBusinessDay returns date, or the next day which is neither a weekend nor a holiday
+func getBusinessDay(date time.Time) time.Time {
+	for isWeekend(date) || isHoliday(date) {
+		date = date.AddDate(0, 0, 1)
+	}
+	return date
+}
+
+// getDayOfMonth returns day of the month of date. A day after the end of the month is the last day(말일).
+func getDayOfMonth(date time.Time, day int) time.Time {
+	if daysOfMonth := time.Date(date.Year(), date.Month()+1, 0, 0, 0, 0, 0, date.Location()).Day(); day > daysOfMonth {
+		day = daysOfMonth
+	}
+	return time.Date(date.Year(), date.Month(), day, 0, 0, 0, 0, date.Location())
+}
+
+// parseCardDay parses a day of a card account. "말일" and "last" are the last day of the month.
+func parseCardDay(value string) (int, error) {
+	if value == "말일" || value == "last" {
+		return 31, nil
+	}
+
+	day, err := strconv.Atoi(value)
+	if err == nil && (day < 1 || day > 31) {
+		return 0, newValidationError("day", "day must be between 1 and 31: %s", value)
+	}
+	return day, err
+}
+
+// getCreditDays returns the repay day and the use days of a credit account
+func getCreditDays(account Account) (repayDay, useDayFrom, useDayTo int, ok bool) {
+	repayDay, err1 := parseCardDay(account.RepayDay)
+	useDayFrom, err2 := parseCardDay(account.UseDayFrom)
+	useDayTo, err3 := parseCardDay(account.UseDayTo)
+
+	return repayDay, useDayFrom, useDayTo, err1 == nil && err2 == nil && err3 == nil
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetHolidaysOfYear(t *testing.T) {
+	tests := []struct {
+		date string
+		want string
+	}{
+		{"2026-01-01", "신정"},
+		{"2026-03-01", "삼일절"},
+		{"2026-03-02", "대체공휴일"}, // 삼일절 on a sunday
+		{"2025-10-08", "대체공휴일"}, // 추석 on a sunday
+		{"2024-02-12", "대체공휴일"}, // 설날 on a weekend
+		{"2027-08-16", "대체공휴일"}, // 광복절 on a sunday
+		{"2027-10-04", "대체공휴일"}, // 개천절 on a sunday
+		{"2027-10-11", "대체공휴일"}, // 한글날 on a saturday, after the substitute of 개천절
+		{"2027-12-27", "대체공휴일"}, // 기독탄신일 on a saturday
+		{"2028-10-05", "대체공휴일"}, // 개천절 in 추석
+		{"2026-06-06", "현충일"},   // on a saturday, without a substitute
+		{"2026-06-08", ""},
+		{"2026-03-03", ""},
+	}
+	for _, test := range tests {
+		date, _ := time.Parse("2006-01-02", test.date)
+		if got := getHolidaysOfYear(date.Year())[test.date]; got != test.want {
+			t.Fatalf("%s: got %q, want %q", test.date, got, test.want)
+		}
+	}
+}
+
+func TestGetBusinessDay(t *testing.T) {
+	useTestLedger(t, newMemoryLedgerStore())
+	t.Cleanup(invalidateHolidayCache)
+
+	settings := defaultSettings()
+	settings.Holidays = []string{"2026-05-01"}
+	if err := updateSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		date string
+		want string
+	}{
+		{"business day", "2026-03-10", "2026-03-10"},
+		{"saturday", "2026-03-14", "2026-03-16"},
+		{"sunday", "2026-03-15", "2026-03-16"},
+		{"sunday holiday and its substitute", "2026-03-01", "2026-03-03"},
+		{"seollal", "2026-02-16", "2026-02-19"},
+		{"chuseok on a weekend", "2026-09-24", "2026-09-28"},
+		{"chuseok and its substitute holiday", "2025-10-04", "2025-10-10"},
+		{"new year after the weekend", "2028-12-30", "2029-01-02"},
+		{"holiday of the settings", "2026-05-01", "2026-05-04"},
+		{"children's day", "2026-05-05", "2026-05-06"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			date, _ := time.Parse("2006-01-02", test.date)
+			if got := getBusinessDay(date).Format("2006-01-02"); got != test.want {
+				t.Fatalf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/dgraph-io/badger/v3"
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	kdfAlgorithmPBKDF2   = "pbkdf2-sha256"
+	kdfAlgorithmArgon2id = "argon2id"
+
+	kdfHeaderVersion = 1
+	kdfKeyLength     = 32
+)
+
+// Key derivation header - parameters to derive a key from a passphrase. Each key slot carries one.
+type KDFHeader struct {
+	Version    int    `json:"version"`
+	Algorithm  string `json:"algorithm"` // pbkdf2-sha256(legacy), argon2id
+	Salt       []byte `json:"salt,omitempty"`
+	Iterations int    `json:"iterations,omitempty"` // pbkdf2
+	Time       uint32 `json:"time,omitempty"`       // argon2id
+	Memory     uint32 `json:"memory,omitempty"`     // argon2id, KiB
+	Threads    uint8  `json:"threads,omitempty"`    // argon2id
+	KeyLength  uint32 `json:"key-length,omitempty"`
+}
+
+// newKDFHeader creates a header for the current default KDF with a fresh salt
+func newKDFHeader() (KDFHeader, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return KDFHeader{}, err
+	}
+
+	header := KDFHeader{
+		Version:   kdfHeaderVersion,
+		Algorithm: kdfAlgorithmArgon2id,
+		Salt:      salt,
+		Time:      Argon2Time,
+		Memory:    Argon2Memory,
+		Threads:   Argon2Threads,
+		KeyLength: kdfKeyLength,
+	}
+
+	return header, nil
+}
+
+// legacyKDFHeader describes the old raw 16 bytes salt file + PBKDF2 10,000 rounds
+func legacyKDFHeader(salt []byte) KDFHeader {
+	return KDFHeader{
+		Version:    0,
+		Algorithm:  kdfAlgorithmPBKDF2,
+		Salt:       salt,
+		Iterations: 10000,
+		KeyLength:  kdfKeyLength,
+	}
+}
+
+// isLegacy reports whether the header should be upgraded on the next successful unlock
+func (h KDFHeader) isLegacy() bool {
+	if h.Version < kdfHeaderVersion || h.Algorithm != kdfAlgorithmArgon2id {
+		return true
+	}
+
+	return h.Time < Argon2Time || h.Memory < Argon2Memory
+}
+
+func (h KDFHeader) deriveKey(password string) ([]byte, error) {
+	switch h.Algorithm {
+	case kdfAlgorithmPBKDF2:
+		return generateKey(password, h.Salt), nil
+	case kdfAlgorithmArgon2id:
+		if h.Time == 0 || h.Memory == 0 || h.Threads == 0 {
+			return nil, fmt.Errorf("invalid argon2id parameters")
+		}
+		return argon2.IDKey([]byte(password), h.Salt, h.Time, h.Memory, h.Threads, h.KeyLength), nil
+	}
+
+	return nil, fmt.Errorf("unknown kdf algorithm: %s", h.Algorithm)
+}
+
+// loadKDFHeader reads the header file, or falls back to the legacy salt file.
+// ok is false when neither exists (new database).
+func loadKDFHeader(headerFile, legacySaltFile string) (header KDFHeader, ok bool, err error) {
+	data, err := os.ReadFile(headerFile)
+	if err == nil {
+		if err := json.Unmarshal(data, &header); err != nil {
+			return KDFHeader{}, false, fmt.Errorf("failed to parse kdf header: %w", err)
+		}
+		return header, true, nil
+	}
+	if !os.IsNotExist(err) {
+		return KDFHeader{}, false, err
+	}
+
+	salt, err := os.ReadFile(legacySaltFile)
+	if err == nil {
+		return legacyKDFHeader(salt), true, nil
+	}
+	if !os.IsNotExist(err) {
+		return KDFHeader{}, false, err
+	}
+
+	return KDFHeader{}, false, nil
+}
+
+// rotateBadgerKey re-encrypts the badger key registry with newKey. Data itself is not copied.
+// The database must be closed.
+func rotateBadgerKey(dir string, oldKey, newKey []byte) error {
+	opts := badger.KeyRegistryOptions{
+		Dir:                           dir,
+		ReadOnly:                      true,
+		EncryptionKey:                 oldKey,
+		EncryptionKeyRotationDuration: badger.DefaultOptions(dir).EncryptionKeyRotationDuration,
+	}
+
+	registry, err := badger.OpenKeyRegistry(opts)
+	if err != nil {
+		return fmt.Errorf("failed to open key registry: %w", err)
+	}
+	defer registry.Close()
+
+	opts.ReadOnly = false
+	opts.EncryptionKey = newKey
+
+	return badger.WriteKeyRegistry(registry, opts)
+}
@@ -0,0 +1,315 @@
+package server
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	keySlotTypePassword = "password"
+	keySlotTypeRecovery = "recovery"
+
+	keySlotsVersion = 2
+)
+
+var errWrongPassword = &AppError{Code: errorCodeWrongPassword, Message: "Encryption key mismatch: no key slot matches the password", Field: "password"}
+
+// Key slot - a copy of the master data key wrapped by one passphrase
+type KeySlot struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"` // password, recovery
+	KDF        KDFHeader `json:"kdf"`
+	Nonce      []byte    `json:"nonce,omitempty"`
+	WrappedKey []byte    `json:"wrapped-key,omitempty"`
+	RegDTTM    string
+}
+
+// Key slots file. The badger encryption key is the random master key, never derived from a password.
+type KeySlots struct {
+	Version int       `json:"version"`
+	Slots   []KeySlot `json:"slots"`
+}
+
+func newMasterKey() ([]byte, error) {
+	key := make([]byte, kdfKeyLength)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// deriveSubKey derives an independent key for other encrypted stores from the master key
+func deriveSubKey(masterKey []byte, purpose string) ([]byte, error) {
+	key := make([]byte, kdfKeyLength)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, masterKey, nil, []byte(purpose)), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// newRecoveryKey returns a printable 160 bits key like ABCD-EFGH-...
+func newRecoveryKey() (string, error) {
+	raw := make([]byte, 20)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+
+	encoded := base32.StdEncoding.EncodeToString(raw)
+	groups := []string{}
+	for i := 0; i < len(encoded); i += 4 {
+		groups = append(groups, encoded[i:i+4])
+	}
+
+	return strings.Join(groups, "-"), nil
+}
+
+func normalizeRecoveryKey(recoveryKey string) string {
+	recoveryKey = strings.ToUpper(recoveryKey)
+	recoveryKey = strings.ReplaceAll(recoveryKey, "-", "")
+	recoveryKey = strings.ReplaceAll(recoveryKey, " ", "")
+	return recoveryKey
+}
+
+func newKeySlot(slotType, passphrase string, masterKey []byte) (KeySlot, error) {
+	now := time.Now()
+	slot := KeySlot{
+		ID:      fmt.Sprintf("keyslot:%d", now.UnixNano()),
+		Type:    slotType,
+		RegDTTM: now.Format("20060102150405"),
+	}
+
+	if err := slot.rewrap(passphrase, masterKey); err != nil {
+		return KeySlot{}, err
+	}
+
+	return slot, nil
+}
+
+// rewrap wraps the master key again with a fresh salt and the current KDF parameters
+func (s *KeySlot) rewrap(passphrase string, masterKey []byte) error {
+	header, err := newKDFHeader()
+	if err != nil {
+		return err
+	}
+	s.KDF = header
+
+	return s.wrap(passphrase, masterKey)
+}
+
+func (s *KeySlot) passphraseKey(passphrase string) ([]byte, error) {
+	if s.Type == keySlotTypeRecovery {
+		passphrase = normalizeRecoveryKey(passphrase)
+	}
+	return s.KDF.deriveKey(passphrase)
+}
+
+func (s *KeySlot) wrap(passphrase string, masterKey []byte) error {
+	kek, err := s.passphraseKey(passphrase)
+	if err != nil {
+		return err
+	}
+
+	gcm, err := newGCM(kek)
+	if err != nil {
+		return err
+	}
+
+	s.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(s.Nonce); err != nil {
+		return err
+	}
+	s.WrappedKey = gcm.Seal(nil, s.Nonce, masterKey, []byte(s.ID))
+
+	return nil
+}
+
+func (s *KeySlot) unwrap(passphrase string) ([]byte, error) {
+	kek, err := s.passphraseKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	masterKey, err := gcm.Open(nil, s.Nonce, s.WrappedKey, []byte(s.ID))
+	if err != nil {
+		return nil, errWrongPassword
+	}
+
+	return masterKey, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// unlock returns the master key and the index of the slot opened by the passphrase
+func (ks *KeySlots) unlock(passphrase string) ([]byte, int, error) {
+	for i := range ks.Slots {
+		masterKey, err := ks.Slots[i].unwrap(passphrase)
+		if err == nil {
+			return masterKey, i, nil
+		}
+	}
+
+	return nil, -1, errWrongPassword
+}
+
+func (ks *KeySlots) countType(slotType string) int {
+	count := 0
+	for _, slot := range ks.Slots {
+		if slot.Type == slotType {
+			count++
+		}
+	}
+	return count
+}
+
+func loadKeySlots(filename string) (KeySlots, bool, error) {
+	var keySlots KeySlots
+
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return KeySlots{}, false, nil
+	}
+	if err != nil {
+		return KeySlots{}, false, err
+	}
+
+	if err := json.Unmarshal(data, &keySlots); err != nil {
+		return KeySlots{}, false, fmt.Errorf("failed to parse key slots: %w", err)
+	}
+
+	return keySlots, true, nil
+}
+
+func writeKeySlots(filename string, keySlots KeySlots) error {
+	keySlots.Version = keySlotsVersion
+
+	data, err := json.MarshalIndent(keySlots, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpFile := filename + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile, filename)
+}
+
+// addKeySlot wraps the master key, opened by an existing passphrase, with a new passphrase.
+// For recovery slots the new passphrase is generated and returned.
+func addKeySlot(passphrase, slotType, newPassphrase string) (KeySlot, string, error) {
+	keySlots, exist, err := loadKeySlots(keySlotsFile)
+	if err != nil {
+		return KeySlot{}, "", err
+	}
+	if !exist {
+		return KeySlot{}, "", errLedgerLocked
+	}
+
+	masterKey, _, err := keySlots.unlock(passphrase)
+	if err != nil {
+		return KeySlot{}, "", err
+	}
+
+	switch slotType {
+	case keySlotTypePassword:
+		if newPassphrase == "" {
+			return KeySlot{}, "", newValidationError("new-password", "new-password is required")
+		}
+	case keySlotTypeRecovery:
+		newPassphrase, err = newRecoveryKey()
+		if err != nil {
+			return KeySlot{}, "", err
+		}
+	default:
+		return KeySlot{}, "", newValidationError("type", "type is required: password or recovery")
+	}
+
+	slot, err := newKeySlot(slotType, newPassphrase, masterKey)
+	if err != nil {
+		return KeySlot{}, "", err
+	}
+
+	keySlots.Slots = append(keySlots.Slots, slot)
+	if err := writeKeySlots(keySlotsFile, keySlots); err != nil {
+		return KeySlot{}, "", err
+	}
+
+	if slotType == keySlotTypePassword {
+		newPassphrase = ""
+	}
+
+	return slot, newPassphrase, nil
+}
+
+// removeKeySlot removes a slot. At least one password slot always remains.
+func removeKeySlot(passphrase, id string) error {
+	keySlots, exist, err := loadKeySlots(keySlotsFile)
+	if err != nil {
+		return err
+	}
+	if !exist {
+		return errLedgerLocked
+	}
+
+	if _, _, err := keySlots.unlock(passphrase); err != nil {
+		return err
+	}
+
+	slots := []KeySlot{}
+	var removed *KeySlot
+	for i, slot := range keySlots.Slots {
+		if slot.ID == id {
+			removed = &keySlots.Slots[i]
+			continue
+		}
+		slots = append(slots, slot)
+	}
+	if removed == nil {
+		return errKeyNotFound(id)
+	}
+	if removed.Type == keySlotTypePassword && keySlots.countType(keySlotTypePassword) <= 1 {
+		return newConflictError("the last password slot is required")
+	}
+
+	keySlots.Slots = slots
+
+	return writeKeySlots(keySlotsFile, keySlots)
+}
+
+func getKeySlotList() ([]KeySlot, error) {
+	keySlots, _, err := loadKeySlots(keySlotsFile)
+	if err != nil {
+		return []KeySlot{}, err
+	}
+
+	// Only metadata leaves the server
+	results := []KeySlot{}
+	for _, slot := range keySlots.Slots {
+		results = append(results, KeySlot{ID: slot.ID, Type: slot.Type, KDF: KDFHeader{Version: slot.KDF.Version, Algorithm: slot.KDF.Algorithm}, RegDTTM: slot.RegDTTM})
+	}
+
+	return results, nil
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+// A password change only wraps the key slot again, the ledger stays unlocked
+func TestChangePasswordKeepsLedgerOpen(t *testing.T) {
+	server := newTestServer(t)
+	if status, body := doTestRequest(t, server, "GET", apiPrefix+"/setup/db?password=test", nil); status != http.StatusOK {
+		t.Fatalf("unlock: got %d %v", status, body)
+	}
+
+	if status, body := doTestRequest(t, server, "GET", apiPrefix+"/setup/db/password?old-password=test&new-password=changed", nil); status != http.StatusOK {
+		t.Fatalf("change password: got %d %v", status, body)
+	}
+	if status, body := doTestRequest(t, server, "GET", apiPrefix+"/accounts", nil); status != http.StatusOK {
+		t.Fatalf("accounts after the change: got %d %v", status, body)
+	}
+
+	if _, _, err := loadAndUnlockKeySlots(t, "changed"); err != nil {
+		t.Fatalf("new password: %v", err)
+	}
+	if _, _, err := loadAndUnlockKeySlots(t, "test"); err != errWrongPassword {
+		t.Fatalf("old password: got %v", err)
+	}
+}
+
+// The password of a key slot removal is read from the body
+func TestRemoveKeySlotPasswordInBody(t *testing.T) {
+	server := newTestServer(t)
+	doTestRequest(t, server, "GET", apiPrefix+"/setup/db?password=test", nil)
+
+	status, body := doTestRequest(t, server, "POST", apiPrefix+"/setup/keyslots", map[string]string{"password": "test", "type": "password", "new-password": "second"})
+	if status != http.StatusCreated {
+		t.Fatalf("add key slot: got %d %v", status, body)
+	}
+	path := apiPrefix + "/setup/keyslots/" + body.(map[string]interface{})["id"].(string)
+
+	if status, body := doTestRequest(t, server, "DELETE", path+"?password=test", map[string]string{}); status != http.StatusBadRequest {
+		t.Fatalf("password in the query: got %d %v", status, body)
+	}
+	if status, body := doTestRequest(t, server, "DELETE", path, map[string]string{"password": "test"}); status != http.StatusOK {
+		t.Fatalf("password in the body: got %d %v", status, body)
+	}
+	if _, _, err := loadAndUnlockKeySlots(t, "second"); err != errWrongPassword {
+		t.Fatalf("removed slot: got %v", err)
+	}
+}
+
+func loadAndUnlockKeySlots(t *testing.T, passphrase string) ([]byte, int, error) {
+	t.Helper()
+
+	keySlots, _, err := loadKeySlots(keySlotsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keySlots.unlock(passphrase)
+}
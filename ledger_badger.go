@@ -0,0 +1,544 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Encrypted badger ledger. Keys are "<kind>:<unix nano>-<random hex>" with the JSON of the entity as value.
+type badgerLedgerStore struct {
+	db  *badger.DB
+	key []byte
+}
+
+func openBadgerLedgerStore(dir string, key []byte) (*badgerLedgerStore, error) {
+	badgerDB, err := badger.Open(badgerOptions(dir, key))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &badgerLedgerStore{db: badgerDB, key: key}
+	if err := s.ensureRecordIndexes(); err != nil {
+		badgerDB.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *badgerLedgerStore) Close() error {
+	return s.db.Close()
+}
+
+// insert adds a new entity only
+func (s *badgerLedgerStore) insert(id string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(txn *badger.Txn) error {
+		if err := checkKeyNotExists(txn, id); err != nil {
+			return err
+		}
+		return txn.Set([]byte(id), data)
+	})
+}
+
+// update replaces an existing entity only
+func (s *badgerLedgerStore) update(id string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(txn *badger.Txn) error {
+		if _, err := txn.Get([]byte(id)); err != nil {
+			return badgerError(err, id)
+		}
+		return txn.Set([]byte(id), data)
+	})
+}
+
+func (s *badgerLedgerStore) delete(id string) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		if _, err := txn.Get([]byte(id)); err != nil {
+			return badgerError(err, id)
+		}
+		if err := txn.Delete([]byte(id)); err != nil {
+			return err
+		}
+		return setDeletionMarker(txn, id)
+	})
+}
+
+// Deletion markers outlive the tombstones dropped by compaction, until the index is synced
+const deletionMarkerPrefix = "deleted:"
+
+func setDeletionMarker(txn *badger.Txn, id string) error {
+	return txn.Set([]byte(deletionMarkerPrefix+id), nil)
+}
+
+func (s *badgerLedgerStore) get(id string, value interface{}) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(id))
+		if err != nil {
+			return badgerError(err, id)
+		}
+		return item.Value(func(v []byte) error {
+			return json.Unmarshal(v, value)
+		})
+	})
+}
+
+// list calls fn with the JSON of every entity of the prefix
+func (s *badgerLedgerStore) list(prefix string, fn func(v []byte) error) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchSize = 10
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek([]byte(prefix)); it.ValidForPrefix([]byte(prefix)); it.Next() {
+			if err := it.Item().Value(fn); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func checkKeyNotExists(txn *badger.Txn, id string) error {
+	_, err := txn.Get([]byte(id))
+	if err == nil {
+		return errKeyExists(id)
+	}
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil
+	}
+	return err
+}
+
+func badgerError(err error, id string) error {
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return errKeyNotFound(id)
+	}
+	return err
+}
+
+func (s *badgerLedgerStore) AddAccount(account Account) error {
+	return s.insert(account.ID, account)
+}
+
+func (s *badgerLedgerStore) UpdateAccount(account Account) error {
+	return s.update(account.ID, account)
+}
+
+func (s *badgerLedgerStore) DeleteAccount(id string) error {
+	return s.delete(id)
+}
+
+func (s *badgerLedgerStore) GetAccount(id string) (Account, error) {
+	var account Account
+	err := s.get(id, &account)
+	return account, err
+}
+
+func (s *badgerLedgerStore) ListAccounts() ([]Account, error) {
+	results := []Account{}
+	err := s.list("account:", func(v []byte) error {
+		var account Account
+		if err := json.Unmarshal(v, &account); err != nil {
+			return err
+		}
+		results = append(results, account)
+		return nil
+	})
+
+	return results, err
+}
+
+func (s *badgerLedgerStore) AddCategory(category Category) error {
+	return s.insert(category.ID, category)
+}
+
+func (s *badgerLedgerStore) UpdateCategory(category Category) error {
+	return s.update(category.ID, category)
+}
+
+func (s *badgerLedgerStore) DeleteCategory(id string) error {
+	return s.delete(id)
+}
+
+func (s *badgerLedgerStore) GetCategory(id string) (Category, error) {
+	var category Category
+	err := s.get(id, &category)
+	return category, err
+}
+
+func (s *badgerLedgerStore) ListCategories() ([]Category, error) {
+	results := []Category{}
+	err := s.list("category:", func(v []byte) error {
+		var category Category
+		if err := json.Unmarshal(v, &category); err != nil {
+			return err
+		}
+		results = append(results, category)
+		return nil
+	})
+
+	return results, err
+}
+
+func (s *badgerLedgerStore) AddRecord(record Record) error {
+	value, err := json.Marshal(storedRecord(record))
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(txn *badger.Txn) error {
+		if err := checkKeyNotExists(txn, record.ID); err != nil {
+			return err
+		}
+		if err := txn.Set([]byte(record.ID), value); err != nil {
+			return err
+		}
+		return setRecordIndexes(txn, record)
+	})
+}
+
+func (s *badgerLedgerStore) UpdateRecord(record Record) error {
+	value, err := json.Marshal(storedRecord(record))
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(txn *badger.Txn) error {
+		existingRecord, err := getRecordInTxn(txn, record.ID)
+		if err != nil {
+			return badgerError(err, record.ID)
+		}
+
+		if err := txn.Set([]byte(record.ID), value); err != nil {
+			return err
+		}
+		if err := deleteRecordIndexes(txn, existingRecord); err != nil {
+			return err
+		}
+		return setRecordIndexes(txn, record)
+	})
+}
+
+func (s *badgerLedgerStore) DeleteRecord(id string) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		record, err := getRecordInTxn(txn, id)
+		if err != nil {
+			return badgerError(err, id)
+		}
+		if err := deleteRecordIndexes(txn, record); err != nil {
+			return err
+		}
+		if err := txn.Delete([]byte(id)); err != nil {
+			return err
+		}
+		return setDeletionMarker(txn, id)
+	})
+}
+
+func (s *badgerLedgerStore) GetRecord(id string) (Record, error) {
+	var record Record
+	err := s.get(id, (*storedRecord)(&record))
+	return record, err
+}
+
+func (s *badgerLedgerStore) GetRecords(ids []string) ([]Record, error) {
+	results := make([]Record, 0, len(ids))
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		for _, id := range ids {
+			record, err := getRecordInTxn(txn, id)
+			if err != nil {
+				continue
+			}
+			results = append(results, record)
+		}
+		return nil
+	})
+
+	return results, err
+}
+
+func (s *badgerLedgerStore) ListRecords(accountID, from, to string) ([]Record, error) {
+	if to == "" {
+		to = "9999-12-31"
+	}
+
+	results := []Record{}
+	err := s.db.View(func(txn *badger.Txn) error {
+		for _, id := range listRecordIDsByDate(txn, accountID, from, to) {
+			record, err := getRecordInTxn(txn, id)
+			if err != nil {
+				continue
+			}
+			results = append(results, record)
+		}
+		return nil
+	})
+
+	return results, err
+}
+
+func (s *badgerLedgerStore) SumRecords(to string) ([]RecordSum, error) {
+	sums := map[RecordSum]Money{}
+	err := s.db.View(func(txn *badger.Txn) error {
+		for _, id := range listRecordIDsByDate(txn, "", "", to) {
+			record, err := getRecordInTxn(txn, id)
+			if err != nil {
+				continue
+			}
+			addRecordSum(sums, record)
+		}
+		return nil
+	})
+
+	return listRecordSums(sums), err
+}
+
+func (s *badgerLedgerStore) Version() uint64 {
+	return s.db.MaxVersion()
+}
+
+// Indexed entities of the search index
+var trackedEntityKinds = []string{"account", "category", "record"}
+
+func (s *badgerLedgerStore) EntityChangesSince(version uint64, fn func(id string, entity interface{}) error) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		for _, kind := range trackedEntityKinds {
+			opts := badger.DefaultIteratorOptions
+			opts.Prefix = []byte(kind + ":")
+			opts.SinceTs = version
+			opts.AllVersions = true
+			it := txn.NewIterator(opts)
+
+			var lastKey []byte
+			for it.Rewind(); it.Valid(); it.Next() {
+				item := it.Item()
+
+				// Newest version comes first
+				if bytes.Equal(lastKey, item.Key()) {
+					continue
+				}
+				lastKey = item.KeyCopy(lastKey)
+
+				var entity interface{}
+				if !item.IsDeletedOrExpired() {
+					var err error
+					entity, err = decodeEntity(kind, item)
+					if err != nil {
+						it.Close()
+						return err
+					}
+				}
+				if err := fn(string(lastKey), entity); err != nil {
+					it.Close()
+					return err
+				}
+			}
+			it.Close()
+		}
+
+		// Deletions whose tombstones may be compacted away
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(deletionMarkerPrefix)
+		opts.SinceTs = version
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			id := string(it.Item().Key()[len(deletionMarkerPrefix):])
+
+			// Added again after the deletion
+			if _, err := txn.Get([]byte(id)); err == nil {
+				continue
+			}
+			if err := fn(id, nil); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func decodeEntity(kind string, item *badger.Item) (interface{}, error) {
+	var entity interface{}
+	err := item.Value(func(v []byte) error {
+		switch kind {
+		case "account":
+			var account Account
+			err := json.Unmarshal(v, &account)
+			entity = account
+			return err
+		case "category":
+			var category Category
+			err := json.Unmarshal(v, &category)
+			entity = category
+			return err
+		default:
+			var record Record
+			err := json.Unmarshal(v, (*storedRecord)(&record))
+			entity = record
+			return err
+		}
+	})
+
+	return entity, err
+}
+
+func (s *badgerLedgerStore) PruneDeletions(version uint64) error {
+	batch := s.db.NewWriteBatch()
+	defer batch.Cancel()
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(deletionMarkerPrefix)
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			if it.Item().Version() > version {
+				continue
+			}
+			if err := batch.Delete(it.Item().KeyCopy(nil)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return batch.Flush()
+}
+
+func (s *badgerLedgerStore) GetMeta(key string) (string, error) {
+	value := ""
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte("meta:" + key))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(v []byte) error {
+			value = string(v)
+			return nil
+		})
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return "", nil
+	}
+
+	return value, err
+}
+
+func (s *badgerLedgerStore) SetMeta(key, value string) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte("meta:"+key), []byte(value))
+	})
+}
+
+func (s *badgerLedgerStore) RewriteEntities(kind string, fn func(id string, data []byte) ([]byte, error)) error {
+	batch := s.db.NewWriteBatch()
+	defer batch.Cancel()
+
+	prefix := []byte(kind + ":")
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			id := string(item.KeyCopy(nil))
+
+			data, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			newData, err := fn(id, data)
+			if err != nil {
+				return err
+			}
+			if newData == nil {
+				continue
+			}
+
+			if err := batch.Set([]byte(id), newData); err != nil {
+				return err
+			}
+
+			// Date or account may be changed
+			if kind == "record" {
+				var oldRecord, newRecord Record
+				if json.Unmarshal(data, (*storedRecord)(&oldRecord)) == nil && json.Unmarshal(newData, (*storedRecord)(&newRecord)) == nil {
+					if err := batch.Delete(recordDateIndexKey(oldRecord)); err != nil {
+						return err
+					}
+					if oldRecord.AccountID != "" {
+						if err := batch.Delete(recordAccountIndexKey(oldRecord)); err != nil {
+							return err
+						}
+					}
+					if err := batch.Set(recordDateIndexKey(newRecord), nil); err != nil {
+						return err
+					}
+					if newRecord.AccountID != "" {
+						if err := batch.Set(recordAccountIndexKey(newRecord), nil); err != nil {
+							return err
+						}
+					}
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return batch.Flush()
+}
+
+// Backup loads the badger backup stream into a new badger directory encrypted by the same key
+func (s *badgerLedgerStore) Backup(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("backup already exists: %s", path)
+	}
+
+	backupDB, err := badger.Open(badgerOptions(path, s.key))
+	if err != nil {
+		return err
+	}
+	defer backupDB.Close()
+
+	reader, writer := io.Pipe()
+	go func() {
+		_, err := s.db.Backup(writer, 0)
+		writer.CloseWithError(err)
+	}()
+
+	err = backupDB.Load(reader, 256)
+	reader.CloseWithError(err)
+
+	return err
+}
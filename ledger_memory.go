@@ -0,0 +1,325 @@
+package server
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+// In-memory ledger - nothing persists. For tests and demos.
+type memoryLedgerStore struct {
+	mutex      sync.RWMutex
+	accounts   map[string]Account
+	categories map[string]Category
+	records    map[string]Record
+	meta       map[string]string
+}
+
+func newMemoryLedgerStore() *memoryLedgerStore {
+	return &memoryLedgerStore{
+		accounts:   map[string]Account{},
+		categories: map[string]Category{},
+		records:    map[string]Record{},
+		meta:       map[string]string{},
+	}
+}
+
+func (s *memoryLedgerStore) Close() error {
+	return nil
+}
+
+func (s *memoryLedgerStore) AddAccount(account Account) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exist := s.accounts[account.ID]; exist {
+		return errKeyExists(account.ID)
+	}
+	s.accounts[account.ID] = account
+	return nil
+}
+
+func (s *memoryLedgerStore) UpdateAccount(account Account) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exist := s.accounts[account.ID]; !exist {
+		return errKeyNotFound(account.ID)
+	}
+	s.accounts[account.ID] = account
+	return nil
+}
+
+func (s *memoryLedgerStore) DeleteAccount(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exist := s.accounts[id]; !exist {
+		return errKeyNotFound(id)
+	}
+	delete(s.accounts, id)
+	return nil
+}
+
+func (s *memoryLedgerStore) GetAccount(id string) (Account, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	account, exist := s.accounts[id]
+	if !exist {
+		return Account{}, errKeyNotFound(id)
+	}
+	return account, nil
+}
+
+func (s *memoryLedgerStore) ListAccounts() ([]Account, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	results := []Account{}
+	for _, account := range s.accounts {
+		results = append(results, account)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].ID < results[j].ID })
+
+	return results, nil
+}
+
+func (s *memoryLedgerStore) AddCategory(category Category) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exist := s.categories[category.ID]; exist {
+		return errKeyExists(category.ID)
+	}
+	s.categories[category.ID] = category
+	return nil
+}
+
+func (s *memoryLedgerStore) UpdateCategory(category Category) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exist := s.categories[category.ID]; !exist {
+		return errKeyNotFound(category.ID)
+	}
+	s.categories[category.ID] = category
+	return nil
+}
+
+func (s *memoryLedgerStore) DeleteCategory(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exist := s.categories[id]; !exist {
+		return errKeyNotFound(id)
+	}
+	delete(s.categories, id)
+	return nil
+}
+
+func (s *memoryLedgerStore) GetCategory(id string) (Category, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	category, exist := s.categories[id]
+	if !exist {
+		return Category{}, errKeyNotFound(id)
+	}
+	return category, nil
+}
+
+func (s *memoryLedgerStore) ListCategories() ([]Category, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	results := []Category{}
+	for _, category := range s.categories {
+		results = append(results, category)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].ID < results[j].ID })
+
+	return results, nil
+}
+
+func (s *memoryLedgerStore) AddRecord(record Record) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exist := s.records[record.ID]; exist {
+		return errKeyExists(record.ID)
+	}
+	s.records[record.ID] = record
+	return nil
+}
+
+func (s *memoryLedgerStore) UpdateRecord(record Record) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exist := s.records[record.ID]; !exist {
+		return errKeyNotFound(record.ID)
+	}
+	s.records[record.ID] = record
+	return nil
+}
+
+func (s *memoryLedgerStore) DeleteRecord(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exist := s.records[id]; !exist {
+		return errKeyNotFound(id)
+	}
+	delete(s.records, id)
+	return nil
+}
+
+func (s *memoryLedgerStore) GetRecord(id string) (Record, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	record, exist := s.records[id]
+	if !exist {
+		return Record{}, errKeyNotFound(id)
+	}
+	return record, nil
+}
+
+func (s *memoryLedgerStore) GetRecords(ids []string) ([]Record, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	results := make([]Record, 0, len(ids))
+	for _, id := range ids {
+		if record, exist := s.records[id]; exist {
+			results = append(results, record)
+		}
+	}
+
+	return results, nil
+}
+
+func (s *memoryLedgerStore) ListRecords(accountID, from, to string) ([]Record, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	results := []Record{}
+	for _, record := range s.records {
+		if accountID != "" && record.AccountID != accountID {
+			continue
+		}
+		if (from != "" && record.Date < from) || (to != "" && record.Date > to) {
+			continue
+		}
+		results = append(results, record)
+	}
+	sortRecords(results)
+
+	return results, nil
+}
+
+func (s *memoryLedgerStore) SumRecords(to string) ([]RecordSum, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	sums := map[RecordSum]Money{}
+	for _, record := range s.records {
+		if record.Date <= to {
+			addRecordSum(sums, record)
+		}
+	}
+
+	return listRecordSums(sums), nil
+}
+
+func (s *memoryLedgerStore) GetMeta(key string) (string, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.meta[key], nil
+}
+
+func (s *memoryLedgerStore) SetMeta(key, value string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.meta[key] = value
+	return nil
+}
+
+func (s *memoryLedgerStore) RewriteEntities(kind string, fn func(id string, data []byte) ([]byte, error)) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	rewrite := func(id string, value interface{}, set func(data []byte) error) error {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		newData, err := fn(id, data)
+		if err != nil || newData == nil {
+			return err
+		}
+		return set(newData)
+	}
+
+	switch kind {
+	case "account":
+		for id, account := range s.accounts {
+			err := rewrite(id, account, func(data []byte) error {
+				var account Account
+				err := json.Unmarshal(data, &account)
+				s.accounts[id] = account
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+	case "category":
+		for id, category := range s.categories {
+			err := rewrite(id, category, func(data []byte) error {
+				var category Category
+				err := json.Unmarshal(data, &category)
+				s.categories[id] = category
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+	case "record":
+		for id, record := range s.records {
+			err := rewrite(id, storedRecord(record), func(data []byte) error {
+				var record Record
+				err := json.Unmarshal(data, (*storedRecord)(&record))
+				s.records[id] = record
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Backup is not available, nothing persists
+func (s *memoryLedgerStore) Backup(path string) error {
+	return nil
+}
+
+// sortRecords sorts ASC by date, time and ID
+func sortRecords(records []Record) {
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].Date != records[j].Date {
+			return records[i].Date < records[j].Date
+		}
+		if records[i].Time != records[j].Time {
+			return records[i].Time < records[j].Time
+		}
+		return records[i].ID < records[j].ID
+	})
+}
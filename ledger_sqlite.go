@@ -0,0 +1,486 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLite ledger - plaintext, for querying with SQL tools.
+// "data" keeps the whole JSON of the entity, the other columns are copies for queries.
+type sqliteLedgerStore struct {
+	db *sql.DB
+}
+
+var sqliteSchema = []string{
+	`CREATE TABLE IF NOT EXISTS accounts (
+		id TEXT PRIMARY KEY,
+		account_name TEXT NOT NULL,
+		pay_type TEXT NOT NULL,
+		repay_day TEXT,
+		use_day_from TEXT,
+		use_day_to TEXT,
+		description TEXT,
+		regdttm TEXT,
+		data TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS categories (
+		id TEXT PRIMARY KEY,
+		category_name TEXT NOT NULL,
+		regdttm TEXT,
+		data TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS records (
+		id TEXT PRIMARY KEY,
+		transaction_type TEXT NOT NULL,
+		account_id TEXT,
+		pay_type TEXT NOT NULL,
+		currency TEXT NOT NULL,
+		amount INTEGER NOT NULL,
+		category TEXT NOT NULL,
+		description TEXT,
+		date TEXT NOT NULL,
+		time TEXT,
+		regdttm TEXT,
+		data TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS meta (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS records_date ON records (date, time, id)`,
+	`CREATE INDEX IF NOT EXISTS records_account_date ON records (account_id, date, time, id)`,
+}
+
+func openSQLiteLedgerStore(path string) (*sqliteLedgerStore, error) {
+	sqliteDB, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// database/sql pool would open more connections to the same file
+	sqliteDB.SetMaxOpenConns(1)
+
+	for _, stmt := range sqliteSchema {
+		if _, err := sqliteDB.Exec(stmt); err != nil {
+			sqliteDB.Close()
+			return nil, err
+		}
+	}
+
+	return &sqliteLedgerStore{db: sqliteDB}, nil
+}
+
+func (s *sqliteLedgerStore) Close() error {
+	return s.db.Close()
+}
+
+// execOne runs a statement for one row. Zero affected rows is not found.
+func (s *sqliteLedgerStore) execOne(id string, query string, args ...interface{}) error {
+	result, err := s.db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errKeyNotFound(id)
+	}
+
+	return nil
+}
+
+// insertOne runs an INSERT ... ON CONFLICT(id) DO NOTHING, an existing id is a conflict
+func (s *sqliteLedgerStore) insertOne(id string, query string, args ...interface{}) error {
+	result, err := s.db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errKeyExists(id)
+	}
+
+	return nil
+}
+
+// scanRows calls fn with "data" of each row
+func scanRows(rows *sql.Rows, fn func(data []byte) error) error {
+	defer rows.Close()
+
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return err
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (s *sqliteLedgerStore) getData(query, id string, value interface{}) error {
+	var data []byte
+
+	err := s.db.QueryRow(query, id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errKeyNotFound(id)
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, value)
+}
+
+func accountArgs(account Account) ([]interface{}, error) {
+	data, err := json.Marshal(account)
+	if err != nil {
+		return nil, err
+	}
+
+	return []interface{}{account.AccountName, account.PayType, account.RepayDay, account.UseDayFrom, account.UseDayTo, account.Description, account.RegDTTM, string(data), account.ID}, nil
+}
+
+func (s *sqliteLedgerStore) AddAccount(account Account) error {
+	args, err := accountArgs(account)
+	if err != nil {
+		return err
+	}
+
+	return s.insertOne(account.ID, `INSERT INTO accounts (account_name, pay_type, repay_day, use_day_from, use_day_to, description, regdttm, data, id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT(id) DO NOTHING`, args...)
+}
+
+func (s *sqliteLedgerStore) UpdateAccount(account Account) error {
+	args, err := accountArgs(account)
+	if err != nil {
+		return err
+	}
+
+	return s.execOne(account.ID, `UPDATE accounts SET account_name = ?, pay_type = ?, repay_day = ?, use_day_from = ?, use_day_to = ?, description = ?, regdttm = ?, data = ? WHERE id = ?`, args...)
+}
+
+func (s *sqliteLedgerStore) DeleteAccount(id string) error {
+	return s.execOne(id, `DELETE FROM accounts WHERE id = ?`, id)
+}
+
+func (s *sqliteLedgerStore) GetAccount(id string) (Account, error) {
+	var account Account
+	err := s.getData(`SELECT data FROM accounts WHERE id = ?`, id, &account)
+	return account, err
+}
+
+func (s *sqliteLedgerStore) ListAccounts() ([]Account, error) {
+	results := []Account{}
+
+	rows, err := s.db.Query(`SELECT data FROM accounts ORDER BY id`)
+	if err != nil {
+		return results, err
+	}
+	err = scanRows(rows, func(data []byte) error {
+		var account Account
+		if err := json.Unmarshal(data, &account); err != nil {
+			return err
+		}
+		results = append(results, account)
+		return nil
+	})
+
+	return results, err
+}
+
+func categoryArgs(category Category) ([]interface{}, error) {
+	data, err := json.Marshal(category)
+	if err != nil {
+		return nil, err
+	}
+
+	return []interface{}{category.CategoryName, category.RegDTTM, string(data), category.ID}, nil
+}
+
+func (s *sqliteLedgerStore) AddCategory(category Category) error {
+	args, err := categoryArgs(category)
+	if err != nil {
+		return err
+	}
+
+	return s.insertOne(category.ID, `INSERT INTO categories (category_name, regdttm, data, id) VALUES (?, ?, ?, ?) ON CONFLICT(id) DO NOTHING`, args...)
+}
+
+func (s *sqliteLedgerStore) UpdateCategory(category Category) error {
+	args, err := categoryArgs(category)
+	if err != nil {
+		return err
+	}
+
+	return s.execOne(category.ID, `UPDATE categories SET category_name = ?, regdttm = ?, data = ? WHERE id = ?`, args...)
+}
+
+func (s *sqliteLedgerStore) DeleteCategory(id string) error {
+	return s.execOne(id, `DELETE FROM categories WHERE id = ?`, id)
+}
+
+func (s *sqliteLedgerStore) GetCategory(id string) (Category, error) {
+	var category Category
+	err := s.getData(`SELECT data FROM categories WHERE id = ?`, id, &category)
+	return category, err
+}
+
+func (s *sqliteLedgerStore) ListCategories() ([]Category, error) {
+	results := []Category{}
+
+	rows, err := s.db.Query(`SELECT data FROM categories ORDER BY id`)
+	if err != nil {
+		return results, err
+	}
+	err = scanRows(rows, func(data []byte) error {
+		var category Category
+		if err := json.Unmarshal(data, &category); err != nil {
+			return err
+		}
+		results = append(results, category)
+		return nil
+	})
+
+	return results, err
+}
+
+func recordArgs(record Record) ([]interface{}, error) {
+	data, err := json.Marshal(storedRecord(record))
+	if err != nil {
+		return nil, err
+	}
+
+	return []interface{}{record.TransactionType, record.AccountID, record.PayType, record.Currency, int64(record.Amount), record.Category, record.Description, record.Date, record.Time, record.RegDTTM, string(data), record.ID}, nil
+}
+
+func (s *sqliteLedgerStore) AddRecord(record Record) error {
+	args, err := recordArgs(record)
+	if err != nil {
+		return err
+	}
+
+	return s.insertOne(record.ID, `INSERT INTO records (transaction_type, account_id, pay_type, currency, amount, category, description, date, time, regdttm, data, id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT(id) DO NOTHING`, args...)
+}
+
+func (s *sqliteLedgerStore) UpdateRecord(record Record) error {
+	args, err := recordArgs(record)
+	if err != nil {
+		return err
+	}
+
+	return s.execOne(record.ID, `UPDATE records SET transaction_type = ?, account_id = ?, pay_type = ?, currency = ?, amount = ?, category = ?, description = ?, date = ?, time = ?, regdttm = ?, data = ? WHERE id = ?`, args...)
+}
+
+func (s *sqliteLedgerStore) DeleteRecord(id string) error {
+	return s.execOne(id, `DELETE FROM records WHERE id = ?`, id)
+}
+
+func (s *sqliteLedgerStore) GetRecord(id string) (Record, error) {
+	var record Record
+	err := s.getData(`SELECT data FROM records WHERE id = ?`, id, (*storedRecord)(&record))
+	return record, err
+}
+
+func (s *sqliteLedgerStore) GetRecords(ids []string) ([]Record, error) {
+	results := make([]Record, 0, len(ids))
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return results, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`SELECT data FROM records WHERE id = ?`)
+	if err != nil {
+		return results, err
+	}
+	defer stmt.Close()
+
+	for _, id := range ids {
+		var data []byte
+		if err := stmt.QueryRow(id).Scan(&data); err != nil {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(data, (*storedRecord)(&record)); err != nil {
+			continue
+		}
+		results = append(results, record)
+	}
+
+	return results, nil
+}
+
+func (s *sqliteLedgerStore) ListRecords(accountID, from, to string) ([]Record, error) {
+	results := []Record{}
+
+	if to == "" {
+		to = "9999-12-31"
+	}
+
+	query := `SELECT data FROM records WHERE date >= ? AND date <= ?`
+	args := []interface{}{from, to}
+	if accountID != "" {
+		query += ` AND account_id = ?`
+		args = append(args, accountID)
+	}
+	query += ` ORDER BY date, time, id`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return results, err
+	}
+	err = scanRows(rows, func(data []byte) error {
+		var record Record
+		if err := json.Unmarshal(data, (*storedRecord)(&record)); err != nil {
+			return err
+		}
+		results = append(results, record)
+		return nil
+	})
+
+	return results, err
+}
+
+func (s *sqliteLedgerStore) SumRecords(to string) ([]RecordSum, error) {
+	results := []RecordSum{}
+
+	rows, err := s.db.Query(`SELECT COALESCE(account_id, ''), transaction_type, pay_type, currency, SUM(amount) FROM records WHERE date <= ? GROUP BY 1, 2, 3, 4`, to)
+	if err != nil {
+		return results, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sum RecordSum
+		if err := rows.Scan(&sum.AccountID, &sum.TransactionType, &sum.PayType, &sum.Currency, &sum.Amount); err != nil {
+			return results, err
+		}
+		results = append(results, sum)
+	}
+
+	return results, rows.Err()
+}
+
+func (s *sqliteLedgerStore) GetMeta(key string) (string, error) {
+	var value string
+
+	err := s.db.QueryRow(`SELECT value FROM meta WHERE key = ?`, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+
+	return value, err
+}
+
+func (s *sqliteLedgerStore) SetMeta(key, value string) error {
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO meta (key, value) VALUES (?, ?)`, key, value)
+	return err
+}
+
+// RewriteEntities replaces "data" in one transaction, the columns are refreshed from the new JSON
+func (s *sqliteLedgerStore) RewriteEntities(kind string, fn func(id string, data []byte) ([]byte, error)) error {
+	tables := map[string]string{"account": "accounts", "category": "categories", "record": "records"}
+	table, exist := tables[kind]
+	if !exist {
+		return fmt.Errorf("unknown kind: %s", kind)
+	}
+
+	rows, err := s.db.Query(`SELECT id, data FROM ` + table)
+	if err != nil {
+		return err
+	}
+
+	changes := map[string][]byte{}
+	for rows.Next() {
+		var id string
+		var data []byte
+		if err := rows.Scan(&id, &data); err != nil {
+			rows.Close()
+			return err
+		}
+
+		newData, err := fn(id, data)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		if newData != nil {
+			changes[id] = newData
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for id, data := range changes {
+		var args []interface{}
+		var query string
+		switch kind {
+		case "account":
+			var account Account
+			if json.Unmarshal(data, &account) == nil {
+				args, err = accountArgs(account)
+				query = `UPDATE accounts SET account_name = ?, pay_type = ?, repay_day = ?, use_day_from = ?, use_day_to = ?, description = ?, regdttm = ?, data = ? WHERE id = ?`
+			}
+		case "category":
+			var category Category
+			if json.Unmarshal(data, &category) == nil {
+				args, err = categoryArgs(category)
+				query = `UPDATE categories SET category_name = ?, regdttm = ?, data = ? WHERE id = ?`
+			}
+		case "record":
+			var record Record
+			if json.Unmarshal(data, (*storedRecord)(&record)) == nil {
+				args, err = recordArgs(record)
+				query = `UPDATE records SET transaction_type = ?, account_id = ?, pay_type = ?, currency = ?, amount = ?, category = ?, description = ?, date = ?, time = ?, regdttm = ?, data = ? WHERE id = ?`
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if query == "" {
+			query = `UPDATE ` + table + ` SET data = ? WHERE id = ?`
+			args = []interface{}{nil, id}
+		}
+
+		// data as returned by fn, it may have fields the struct does not know yet
+		args[len(args)-2] = string(data)
+		if _, err := tx.Exec(query, args...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqliteLedgerStore) Backup(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	_, err := s.db.Exec(`VACUUM INTO ?`, path)
+	return err
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"fmt"
+)
+
+const (
+	storageBackendBadger = "badger"
+	storageBackendSQLite = "sqlite"
+	storageBackendMemory = "memory"
+)
+
+// Persistence of accounts, categories and records. Validation, IDs and the search index are not its concern.
+// Not found errors contain "Key not found".
+type LedgerStore interface {
+	AddAccount(account Account) error
+	UpdateAccount(account Account) error
+	DeleteAccount(id string) error
+	GetAccount(id string) (Account, error)
+	ListAccounts() ([]Account, error)
+
+	AddCategory(category Category) error
+	UpdateCategory(category Category) error
+	DeleteCategory(id string) error
+	GetCategory(id string) (Category, error)
+	ListCategories() ([]Category, error)
+
+	AddRecord(record Record) error
+	UpdateRecord(record Record) error
+	DeleteRecord(id string) error
+	GetRecord(id string) (Record, error)
+	// GetRecords loads the records in one read, missing IDs are skipped
+	GetRecords(ids []string) ([]Record, error)
+	// ListRecords returns records between from and to(YYYY-MM-DD, inclusive, empty for unbounded) sorted by date and time
+	ListRecords(accountID, from, to string) ([]Record, error)
+	// SumRecords sums the amounts of the records until to(YYYY-MM-DD, inclusive), without listing them
+	SumRecords(to string) ([]RecordSum, error)
+
+	// Meta values like the schema version. "" when not set.
+	GetMeta(key string) (string, error)
+	SetMeta(key, value string) error
+	// RewriteEntities calls fn with the JSON of each entity of kind(account, category, record).
+	// JSON returned by fn replaces the entity, nil keeps it.
+	RewriteEntities(kind string, fn func(id string, data []byte) ([]byte, error)) error
+	// Backup copies the whole ledger to path, in the same protection(encryption) as the ledger
+	Backup(path string) error
+
+	Close() error
+}
+
+// Sum of the records of an account by transaction type, pay type and currency
+type RecordSum struct {
+	AccountID       string
+	TransactionType string
+	PayType         string
+	Currency        string
+	Amount          Money
+}
+
+// addRecordSum adds the amount of record to its sum in sums
+func addRecordSum(sums map[RecordSum]Money, record Record) {
+	sums[RecordSum{AccountID: record.AccountID, TransactionType: record.TransactionType, PayType: record.PayType, Currency: record.Currency}] += record.Amount
+}
+
+func listRecordSums(sums map[RecordSum]Money) []RecordSum {
+	results := []RecordSum{}
+	for sum, amount := range sums {
+		sum.Amount = amount
+		results = append(results, sum)
+	}
+	return results
+}
+
+// Optional for a LedgerStore - lets the search index sync only the changes since the last unlock
+type ChangeTracker interface {
+	Version() uint64
+	// EntityChangesSince calls fn with the Account, Category or Record changed after version. entity is nil for deleted one.
+	EntityChangesSince(version uint64, fn func(id string, entity interface{}) error) error
+	// PruneDeletions drops the deletion markers up to version, once the index is synced with it
+	PruneDeletions(version uint64) error
+}
+
+// openLedgerStore opens the store of StorageBackend. masterKey encrypts the badger store.
+func openLedgerStore(masterKey []byte) (LedgerStore, error) {
+	switch StorageBackend {
+	case storageBackendBadger:
+		return openBadgerLedgerStore(badgerPath, masterKey)
+	case storageBackendSQLite:
+		return openSQLiteLedgerStore(sqlitePath)
+	case storageBackendMemory:
+		return newMemoryLedgerStore(), nil
+	}
+
+	return nil, fmt.Errorf("unknown storage backend: %s", StorageBackend)
+}
+
+func closeLedgerStore() {
+	if ledger != nil {
+		ledger.Close()
+		ledger = nil
+	}
+}
@@ -0,0 +1,274 @@
+package server
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/index/scorch"
+)
+
+// openTestStores opens every backend in a temporary directory
+func openTestStores(t *testing.T) map[string]LedgerStore {
+	t.Helper()
+	dir := t.TempDir()
+
+	sqliteStore, err := openSQLiteLedgerStore(filepath.Join(dir, "ledger.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	badgerStore, err := openBadgerLedgerStore(filepath.Join(dir, "badger"), make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stores := map[string]LedgerStore{
+		storageBackendMemory: newMemoryLedgerStore(),
+		storageBackendSQLite: sqliteStore,
+		storageBackendBadger: badgerStore,
+	}
+	t.Cleanup(func() {
+		for _, store := range stores {
+			store.Close()
+		}
+	})
+
+	return stores
+}
+
+// useTestLedger sets the globals of the store layer to store and a memory index
+func useTestLedger(t *testing.T, store LedgerStore) {
+	t.Helper()
+
+	index, err := bleve.NewUsing("", bleve.NewIndexMapping(), scorch.Name, scorch.Name, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledger, bleveIndex = store, index
+	invalidateAccountCache()
+	invalidateHolidayCache()
+
+	t.Cleanup(func() {
+		index.Close()
+		ledger, bleveIndex = nil, nil
+	})
+}
+
+func assertErrorCode(t *testing.T, err error, code string) {
+	t.Helper()
+
+	var appError *AppError
+	if !errors.As(err, &appError) || appError.Code != code {
+		t.Fatalf("got error %v, want code %s", err, code)
+	}
+}
+
+func TestLedgerStoreAccounts(t *testing.T) {
+	for name, store := range openTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			account := Account{ID: "account:1", AccountName: "wallet", PayType: "direct", OpeningBalance: "1000"}
+			if err := store.AddAccount(account); err != nil {
+				t.Fatal(err)
+			}
+			assertErrorCode(t, store.AddAccount(account), errorCodeConflict)
+
+			got, err := store.GetAccount(account.ID)
+			if err != nil || got.AccountName != "wallet" || got.OpeningBalance != "1000" {
+				t.Fatalf("got %+v, %v", got, err)
+			}
+			_, err = store.GetAccount("account:missing")
+			assertErrorCode(t, err, errorCodeNotFound)
+
+			account.AccountName = "bank"
+			if err := store.UpdateAccount(account); err != nil {
+				t.Fatal(err)
+			}
+			assertErrorCode(t, store.UpdateAccount(Account{ID: "account:missing"}), errorCodeNotFound)
+
+			accounts, err := store.ListAccounts()
+			if err != nil || len(accounts) != 1 || accounts[0].AccountName != "bank" {
+				t.Fatalf("got %+v, %v", accounts, err)
+			}
+
+			if err := store.DeleteAccount(account.ID); err != nil {
+				t.Fatal(err)
+			}
+			_, err = store.GetAccount(account.ID)
+			assertErrorCode(t, err, errorCodeNotFound)
+			assertErrorCode(t, store.DeleteAccount(account.ID), errorCodeNotFound)
+		})
+	}
+}
+
+func TestLedgerStoreCategories(t *testing.T) {
+	for name, store := range openTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			category := Category{ID: "category:1", CategoryName: "food"}
+			if err := store.AddCategory(category); err != nil {
+				t.Fatal(err)
+			}
+			assertErrorCode(t, store.AddCategory(category), errorCodeConflict)
+
+			categories, err := store.ListCategories()
+			if err != nil || len(categories) != 1 || categories[0].CategoryName != "food" {
+				t.Fatalf("got %+v, %v", categories, err)
+			}
+
+			if err := store.DeleteCategory(category.ID); err != nil {
+				t.Fatal(err)
+			}
+			_, err = store.GetCategory(category.ID)
+			assertErrorCode(t, err, errorCodeNotFound)
+			assertErrorCode(t, store.DeleteCategory(category.ID), errorCodeNotFound)
+		})
+	}
+}
+
+func TestLedgerStoreRecords(t *testing.T) {
+	records := []Record{
+		{ID: "record:3", AccountID: "account:2", Date: "2026-03-01", Time: "09:00"},
+		{ID: "record:1", AccountID: "account:1", Date: "2026-01-15", Time: "12:00"},
+		{ID: "record:2", AccountID: "account:1", Date: "2026-01-15", Time: "08:30"},
+		{ID: "record:4", AccountID: "account:1", Date: "2026-02-01"},
+	}
+
+	for name, store := range openTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, record := range records {
+				record.TransactionType, record.PayType, record.Currency, record.Amount, record.Category = "record_type_pay", "direct", "USD", 1250, "food"
+				if err := store.AddRecord(record); err != nil {
+					t.Fatal(err)
+				}
+			}
+			assertErrorCode(t, store.AddRecord(Record{ID: "record:1", Date: "2026-01-01"}), errorCodeConflict)
+
+			got, err := store.GetRecord("record:1")
+			if err != nil || got.Amount != 1250 || got.Currency != "USD" || got.Date != "2026-01-15" {
+				t.Fatalf("got %+v, %v", got, err)
+			}
+
+			loaded, err := store.GetRecords([]string{"record:4", "record:missing", "record:1"})
+			if err != nil || len(loaded) != 2 {
+				t.Fatalf("got %+v, %v", loaded, err)
+			}
+
+			listIDs := func(accountID, from, to string) []string {
+				t.Helper()
+				results, err := store.ListRecords(accountID, from, to)
+				if err != nil {
+					t.Fatal(err)
+				}
+				ids := []string{}
+				for _, record := range results {
+					ids = append(ids, record.ID)
+				}
+				return ids
+			}
+			if ids := listIDs("", "", ""); !slices.Equal(ids, []string{"record:2", "record:1", "record:4", "record:3"}) {
+				t.Fatalf("all records: got %v", ids)
+			}
+			if ids := listIDs("", "2026-01-15", "2026-02-01"); !slices.Equal(ids, []string{"record:2", "record:1", "record:4"}) {
+				t.Fatalf("records of a period: got %v", ids)
+			}
+			if ids := listIDs("account:1", "2026-02-01", ""); !slices.Equal(ids, []string{"record:4"}) {
+				t.Fatalf("records of an account: got %v", ids)
+			}
+
+			// Date and account indexes follow the update
+			got.Date, got.AccountID = "2026-04-01", "account:2"
+			if err := store.UpdateRecord(got); err != nil {
+				t.Fatal(err)
+			}
+			if ids := listIDs("account:2", "2026-04-01", "2026-04-30"); !slices.Equal(ids, []string{"record:1"}) {
+				t.Fatalf("updated record: got %v", ids)
+			}
+			if ids := listIDs("account:1", "", ""); !slices.Equal(ids, []string{"record:2", "record:4"}) {
+				t.Fatalf("old account of the updated record: got %v", ids)
+			}
+
+			if err := store.DeleteRecord("record:2"); err != nil {
+				t.Fatal(err)
+			}
+			if ids := listIDs("", "", ""); !slices.Equal(ids, []string{"record:4", "record:3", "record:1"}) {
+				t.Fatalf("after delete: got %v", ids)
+			}
+
+			sumAmounts := func(to string) []Money {
+				t.Helper()
+				sums, err := store.SumRecords(to)
+				if err != nil {
+					t.Fatal(err)
+				}
+				slices.SortFunc(sums, func(a, b RecordSum) int { return strings.Compare(a.AccountID, b.AccountID) })
+				amounts := []Money{}
+				for _, sum := range sums {
+					if sum.TransactionType != "record_type_pay" || sum.PayType != "direct" || sum.Currency != "USD" {
+						t.Fatalf("got sum %+v", sum)
+					}
+					amounts = append(amounts, sum.Amount)
+				}
+				return amounts
+			}
+			if amounts := sumAmounts("2026-03-31"); !slices.Equal(amounts, []Money{1250, 1250}) {
+				t.Fatalf("sums until 2026-03-31: got %v", amounts)
+			}
+			if amounts := sumAmounts("9999-12-31"); !slices.Equal(amounts, []Money{1250, 2500}) {
+				t.Fatalf("sums of all records: got %v", amounts)
+			}
+		})
+	}
+}
+
+func TestLedgerStoreMeta(t *testing.T) {
+	for name, store := range openTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if value, err := store.GetMeta("settings"); err != nil || value != "" {
+				t.Fatalf("got %q, %v", value, err)
+			}
+			if err := store.SetMeta("settings", `{"base-currency":"KRW"}`); err != nil {
+				t.Fatal(err)
+			}
+			if value, err := store.GetMeta("settings"); err != nil || value != `{"base-currency":"KRW"}` {
+				t.Fatalf("got %q, %v", value, err)
+			}
+		})
+	}
+}
+
+// Entities added in the same second get their own IDs
+func TestAddEntitiesInOneSecond(t *testing.T) {
+	for name, store := range openTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			useTestLedger(t, store)
+
+			ids := []string{}
+			for i := 0; i < 3; i++ {
+				id, err := addAccount(Account{AccountName: "card", PayType: "credit"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				ids = append(ids, id)
+
+				id, err = addCategory(Category{CategoryName: "food"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				ids = append(ids, id)
+
+				id, err = addRecord(Record{TransactionType: "record_type_pay", PayType: "direct", Currency: "KRW", Amount: 1000, Category: "food", Date: "2026-01-01"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				ids = append(ids, id)
+			}
+
+			slices.Sort(ids)
+			if len(slices.Compact(ids)) != 9 {
+				t.Fatalf("duplicate IDs: %v", ids)
+			}
+		})
+	}
+}
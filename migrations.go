@@ -0,0 +1,191 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const schemaVersionKey = "schema-version"
+
+// newMigrationDryRunError reports what a migration would change. The ledger is not opened.
+func newMigrationDryRunError(reports []MigrationReport) error {
+	return &AppError{Code: errorCodeMigrationDryRun, Message: "Migration dry run: ledger is not migrated", Migrations: reports}
+}
+
+// Schema migration of the stored JSON. Migrate gets one entity of Kind(account, category, record)
+// as a generic map and changes it in place. It must be idempotent - an interrupted run is repeated.
+type Migration struct {
+	Version     int
+	Description string
+	Kind        string
+	Migrate     func(entity map[string]interface{}) (changed bool, err error)
+}
+
+// Result of one migration, also for the dry run
+type MigrationReport struct {
+	Version     int    `json:"version"`
+	Description string `json:"description"`
+	Kind        string `json:"kind"`
+	Changed     int    `json:"changed"`
+}
+
+// Registered migrations in the order of Version
+var migrations = []Migration{}
+
+func init() {
+	registerMigration(Migration{Version: 1, Kind: "record", Description: "float amount to the minor unit of the currency", Migrate: migrateAmountToMinorUnits})
+}
+
+func registerMigration(migration Migration) {
+	if len(migrations) > 0 && migration.Version <= migrations[len(migrations)-1].Version {
+		panic(fmt.Sprintf("migration %d is registered out of order", migration.Version))
+	}
+	migrations = append(migrations, migration)
+}
+
+func latestSchemaVersion() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+func getSchemaVersion() (int, error) {
+	value, err := ledger.GetMeta(schemaVersionKey)
+	if err != nil || value == "" {
+		return 0, err
+	}
+	return strconv.Atoi(value)
+}
+
+func setSchemaVersion(version int) error {
+	return ledger.SetMeta(schemaVersionKey, strconv.Itoa(version))
+}
+
+func isLedgerEmpty() (bool, error) {
+	accounts, err := ledger.ListAccounts()
+	if err != nil {
+		return false, err
+	}
+	categories, err := ledger.ListCategories()
+	if err != nil {
+		return false, err
+	}
+	records, err := ledger.ListRecords("", "", "")
+	if err != nil {
+		return false, err
+	}
+
+	return len(accounts) == 0 && len(categories) == 0 && len(records) == 0, nil
+}
+
+// migrateLedger brings the opened ledger to the latest schema version.
+// The ledger is backed up before the first change. With MigrationDryRun, only reports what would change.
+func migrateLedger() ([]MigrationReport, error) {
+	version, err := getSchemaVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schema version: %w", err)
+	}
+
+	latest := latestSchemaVersion()
+	if version == latest {
+		return nil, nil
+	}
+	if version > latest {
+		return nil, fmt.Errorf("ledger schema version %d is newer than this program(%d)", version, latest)
+	}
+
+	// Nothing to migrate on a new ledger
+	empty, err := isLedgerEmpty()
+	if err != nil {
+		return nil, err
+	}
+	if empty {
+		return nil, setSchemaVersion(latest)
+	}
+
+	pending := []Migration{}
+	for _, migration := range migrations {
+		if migration.Version > version {
+			pending = append(pending, migration)
+		}
+	}
+
+	if !MigrationDryRun && StorageBackend != storageBackendMemory {
+		backupFile := filepath.Join(backupPath, fmt.Sprintf("%s-v%d-%s", StorageBackend, version, time.Now().Format("20060102150405")))
+		if err := ledger.Backup(backupFile); err != nil {
+			return nil, fmt.Errorf("failed to backup before migration: %w", err)
+		}
+		fmt.Println("Ledger is backed up to", backupFile)
+	}
+
+	reports := []MigrationReport{}
+
+	// Consecutive migrations of the same kind run in one pass
+	for start := 0; start < len(pending); {
+		end := start + 1
+		for end < len(pending) && pending[end].Kind == pending[start].Kind {
+			end++
+		}
+
+		groupReports, err := runMigrations(pending[start:end])
+		if err != nil {
+			return reports, err
+		}
+		reports = append(reports, groupReports...)
+
+		if !MigrationDryRun {
+			if err := setSchemaVersion(pending[end-1].Version); err != nil {
+				return reports, err
+			}
+		}
+
+		start = end
+	}
+
+	if MigrationDryRun {
+		for _, report := range reports {
+			fmt.Printf("Migration %d(%s): %d %s(s) would change - %s\n", report.Version, report.Kind, report.Changed, report.Kind, report.Description)
+		}
+		return reports, newMigrationDryRunError(reports)
+	}
+
+	return reports, nil
+}
+
+// runMigrations applies migrations of one kind to each entity in order
+func runMigrations(group []Migration) ([]MigrationReport, error) {
+	reports := make([]MigrationReport, len(group))
+	for i, migration := range group {
+		reports[i] = MigrationReport{Version: migration.Version, Description: migration.Description, Kind: migration.Kind}
+	}
+
+	err := ledger.RewriteEntities(group[0].Kind, func(id string, data []byte) ([]byte, error) {
+		entity := map[string]interface{}{}
+		if err := json.Unmarshal(data, &entity); err != nil {
+			return nil, fmt.Errorf("%s: %w", id, err)
+		}
+
+		changedAny := false
+		for i, migration := range group {
+			changed, err := migration.Migrate(entity)
+			if err != nil {
+				return nil, fmt.Errorf("migration %d, %s: %w", migration.Version, id, err)
+			}
+			if changed {
+				reports[i].Changed++
+				changedAny = true
+			}
+		}
+
+		if !changedAny || MigrationDryRun {
+			return nil, nil
+		}
+		return json.Marshal(entity)
+	})
+
+	return reports, err
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+func TestMigrateAmountToMinorUnits(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		currency string
+		want     int64
+	}{
+		{12000, "KRW", 12000},
+		{3.5, "KRW", 4},
+		{-3.5, "KRW", -4},
+		{12.5, "USD", 1250},
+		{1.005, "USD", 101}, // 100.49999999999999 as 1.005*100
+		{0.1 + 0.2, "USD", 30},
+		{-2.675, "USD", -268},
+		{19.99, "EUR", 1999},
+		{0.004, "USD", 0},
+		{1.5, "", 150}, // 2 decimals of an unknown currency
+	}
+
+	for _, test := range tests {
+		record := map[string]interface{}{"amount": test.amount, "currency": test.currency}
+		changed, err := migrateAmountToMinorUnits(record)
+		if err != nil || !changed {
+			t.Fatalf("%v %s: changed %v, %v", test.amount, test.currency, changed, err)
+		}
+		if record["amount-minor"] != test.want {
+			t.Errorf("%v %s: got %v, want %d", test.amount, test.currency, record["amount-minor"], test.want)
+		}
+		if _, exist := record["amount"]; exist {
+			t.Errorf("%v %s: float amount is kept", test.amount, test.currency)
+		}
+
+		// Idempotent - an interrupted run is repeated
+		if changed, err := migrateAmountToMinorUnits(record); changed || err != nil || record["amount-minor"] != test.want {
+			t.Errorf("%v %s: migrated again to %v, %v", test.amount, test.currency, record["amount-minor"], err)
+		}
+	}
+
+	if _, err := migrateAmountToMinorUnits(map[string]interface{}{"amount": 1e30, "currency": "KRW"}); err == nil {
+		t.Error("amount out of int64 is migrated")
+	}
+}
+
+// A dry run reports the changes to the caller without touching the ledger
+func TestMigrationDryRun(t *testing.T) {
+	store, err := openBadgerLedgerStore(filepath.Join(t.TempDir(), "badger_data"), make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldBackupPath, oldDryRun := backupPath, MigrationDryRun
+	ledger, backupPath = store, t.TempDir()
+	t.Cleanup(func() {
+		store.Close()
+		ledger, backupPath, MigrationDryRun = nil, oldBackupPath, oldDryRun
+	})
+
+	if err := store.AddAccount(Account{ID: "account:1", AccountName: "wallet", PayType: "direct"}); err != nil {
+		t.Fatal(err)
+	}
+	legacy := `{"id":"record:1","transaction-type":"record_type_pay","pay-type":"direct","currency":"USD","amount":1.005,"category":"food","date":"2026-01-02"}`
+	err = store.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte("record:1"), []byte(legacy))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	MigrationDryRun = true
+	_, err = migrateLedger()
+	var appError *AppError
+	if !errors.As(err, &appError) || appError.Code != errorCodeMigrationDryRun || len(appError.Migrations) != 1 || appError.Migrations[0].Changed != 1 {
+		t.Fatalf("got %#v, want the dry run error with the reports", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	writeError(recorder, err, "Failed to initialize database")
+	var response AppError
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Code != http.StatusConflict || len(response.Migrations) != 1 || response.Migrations[0].Version != 1 {
+		t.Fatalf("got %d %+v", recorder.Code, response)
+	}
+
+	if version, err := getSchemaVersion(); err != nil || version != 0 {
+		t.Fatalf("schema version after the dry run: got %d, %v", version, err)
+	}
+
+	MigrationDryRun = false
+	if _, err := migrateLedger(); err != nil {
+		t.Fatal(err)
+	}
+	record, err := store.GetRecord("record:1")
+	if err != nil || record.Amount != 101 {
+		t.Fatalf("got %+v, %v", record, err)
+	}
+	if version, err := getSchemaVersion(); err != nil || version != latestSchemaVersion() {
+		t.Fatalf("schema version: got %d, %v", version, err)
+	}
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Amount in the minor unit of a currency - 1234 is 12.34 USD or 1,234 KRW
+type Money int64
+
+// Total of amounts in mixed currencies by face value, in 1/10^totalDecimals
+type Total int64
+
+// ISO 4217 minor units(decimals) of the supported currencies
+var currencyDecimals = map[string]int{
+	"KRW": 0,
+	"JPY": 0,
+	"USD": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"CNY": 2,
+}
+
+// The largest decimals of currencyDecimals - totals keep every currency exact
+const totalDecimals = 2
+
+func isSupportedCurrency(currency string) bool {
+	_, exist := currencyDecimals[currency]
+	return exist
+}
+
+// getCurrencyDecimals returns 2 for unknown currencies, like most of ISO 4217
+func getCurrencyDecimals(currency string) int {
+	if decimals, exist := currencyDecimals[currency]; exist {
+		return decimals
+	}
+	return 2
+}
+
+// parseMoney parses a decimal like "12.34" to the minor unit of currency, without float rounding.
+// More decimals than the currency has is an error, except trailing zeros.
+// Amounts are limited so that toTotal does not overflow.
+func parseMoney(amount string, currency string) (Money, error) {
+	minor, err := parseDecimal(amount, getCurrencyDecimals(currency))
+	if errors.Is(err, errTooManyDecimals) {
+		return 0, fmt.Errorf("amount %s has more than %d decimal(s) of %s", amount, getCurrencyDecimals(currency), currency)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if limit := math.MaxInt64 / totalScale(currency); minor > limit || minor < -limit {
+		return 0, fmt.Errorf("%w: %s %s", errAmountOutOfRange, amount, currency)
+	}
+
+	return Money(minor), nil
+}
+
+var errTooManyDecimals = errors.New("too many decimals")
+
+var errAmountOutOfRange = errors.New("amount out of range")
+
+// parseDecimal parses a decimal to an integer in 1/10^decimals.
+// Only digits with one leading "-" and one "." - no "+", exponent or spaces.
+func parseDecimal(amount string, decimals int) (int64, error) {
+	if amount == "" {
+		return 0, nil
+	}
+
+	negative := strings.HasPrefix(amount, "-")
+	integerPart, fractionPart, _ := strings.Cut(strings.TrimPrefix(amount, "-"), ".")
+	if integerPart == "" || !isDigits(integerPart) || !isDigits(fractionPart) {
+		return 0, fmt.Errorf("invalid amount: %s", amount)
+	}
+
+	fractionPart = strings.TrimRight(fractionPart, "0")
+	if len(fractionPart) > decimals {
+		return 0, errTooManyDecimals
+	}
+	fractionPart += strings.Repeat("0", decimals-len(fractionPart))
+
+	minor, err := strconv.ParseInt(integerPart+fractionPart, 10, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return 0, fmt.Errorf("%w: %s", errAmountOutOfRange, amount)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount: %s", amount)
+	}
+	if negative {
+		minor = -minor
+	}
+
+	return minor, nil
+}
+
+func isDigits(text string) bool {
+	for _, c := range text {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func formatDecimal(minor int64, decimals int) string {
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+
+	digits := strconv.FormatInt(minor, 10)
+	if decimals == 0 {
+		return sign + digits
+	}
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+
+	return sign + digits[:len(digits)-decimals] + "." + digits[len(digits)-decimals:]
+}
+
+// formatMoney formats the minor unit as a decimal of the currency - 1234 USD is "12.34"
+func formatMoney(amount Money, currency string) string {
+	return formatDecimal(int64(amount), getCurrencyDecimals(currency))
+}
+
+// totalScale is the multiplier of the minor unit of currency to a Total
+func totalScale(currency string) int64 {
+	return int64(math.Pow10(totalDecimals - getCurrencyDecimals(currency)))
+}
+
+// toTotal scales amount of currency to the face value of a Total.
+// Amounts over the range of Total - never from parseMoney - stop at the limit.
+func toTotal(amount Money, currency string) Total {
+	scale := totalScale(currency)
+	switch {
+	case int64(amount) > math.MaxInt64/scale:
+		return math.MaxInt64
+	case int64(amount) < math.MinInt64/scale:
+		return math.MinInt64
+	}
+	return Total(int64(amount) * scale)
+}
+
+func (t Total) MarshalJSON() ([]byte, error) {
+	return []byte(formatDecimal(int64(t), totalDecimals)), nil
+}
+
+func (t *Total) UnmarshalJSON(data []byte) error {
+	value, err := parseDecimal(string(data), totalDecimals)
+	*t = Total(value)
+	return err
+}
+
+// String formats the face value like "1500.30"
+func (t Total) String() string {
+	return formatDecimal(int64(t), totalDecimals)
+}
+
+// Stored JSON of a record - the amount is kept in the minor unit as "amount-minor"
+type storedRecord Record
+
+// MarshalJSON writes the amount as a decimal of the currency
+func (r Record) MarshalJSON() ([]byte, error) {
+	type recordJSON Record
+	return json.Marshal(struct {
+		recordJSON
+		Amount json.Number `json:"amount"`
+		// Hides "amount-minor" of recordJSON
+		AmountMinor *struct{} `json:"amount-minor,omitempty"`
+	}{
+		recordJSON: recordJSON(r),
+		Amount:     json.Number(formatMoney(r.Amount, r.Currency)),
+	})
+}
+
+// UnmarshalJSON reads a decimal amount. An amount not fitting the currency is reported by validateRecord.
+func (r *Record) UnmarshalJSON(data []byte) error {
+	type recordJSON Record
+	aux := struct {
+		*recordJSON
+		Amount      json.Number     `json:"amount"`
+		AmountMinor json.RawMessage `json:"amount-minor"`
+	}{
+		recordJSON: (*recordJSON)(r),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	r.amountText = aux.Amount.String()
+	r.Amount, r.amountErr = parseMoney(r.amountText, r.Currency)
+	return nil
+}
+
+// Float "amount" of the stored records to "amount-minor"
+func migrateAmountToMinorUnits(record map[string]interface{}) (bool, error) {
+	if _, exist := record["amount-minor"]; exist {
+		return false, nil
+	}
+
+	amount, _ := record["amount"].(float64)
+	currency, _ := record["currency"].(string)
+
+	minor, err := roundDecimal(amount, getCurrencyDecimals(currency))
+	if limit := math.MaxInt64 / totalScale(currency); err == nil && (minor > limit || minor < -limit) {
+		err = fmt.Errorf("%w: %v %s", errAmountOutOfRange, amount, currency)
+	}
+	if err != nil {
+		return false, err
+	}
+	record["amount-minor"] = minor
+	delete(record, "amount")
+
+	return true, nil
+}
+
+// roundDecimal rounds value to an integer in 1/10^decimals, half away from zero.
+// Rounded by the shortest decimal of the float - 1.005 is 101 for 2 decimals, not 100 of 1.005*100.
+func roundDecimal(value float64, decimals int) (int64, error) {
+	text := strconv.FormatFloat(math.Abs(value), 'f', -1, 64)
+	integerPart, fractionPart, _ := strings.Cut(text, ".")
+	fractionPart += strings.Repeat("0", decimals+1)
+
+	minor, err := strconv.ParseInt(integerPart+fractionPart[:decimals], 10, 64)
+	if err != nil || (fractionPart[decimals] >= '5' && minor == math.MaxInt64) {
+		return 0, fmt.Errorf("amount out of range: %v", value)
+	}
+	if fractionPart[decimals] >= '5' {
+		minor++
+	}
+	if value < 0 {
+		minor = -minor
+	}
+
+	return minor, nil
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		amount   string
+		decimals int
+		want     int64
+		err      error // nil for no error, errInvalid for any error
+	}{
+		{"", 2, 0, nil},
+		{"0", 2, 0, nil},
+		{"12.34", 2, 1234, nil},
+		{"12.3", 2, 1230, nil},
+		{"12.", 2, 1200, nil},
+		{"12.3400", 2, 1234, nil},
+		{"-12.34", 2, -1234, nil},
+		{"-0.05", 2, -5, nil},
+		{"1500", 0, 1500, nil},
+		{"1500.0", 0, 1500, nil},
+		{"12.345", 2, 0, errTooManyDecimals},
+		{"1500.5", 0, 0, errTooManyDecimals},
+		{"92233720368547758.07", 2, math.MaxInt64, nil},
+		{"-92233720368547758.07", 2, -math.MaxInt64, nil},
+		{"92233720368547758.08", 2, 0, errAmountOutOfRange},
+		{"99999999999999999999", 0, 0, errAmountOutOfRange},
+		{"--5", 2, 0, errInvalid},
+		{"+5", 2, 0, errInvalid},
+		{"-+5", 2, 0, errInvalid},
+		{"5-", 2, 0, errInvalid},
+		{"1.2.3", 2, 0, errInvalid},
+		{"1e3", 2, 0, errInvalid},
+		{".5", 2, 0, errInvalid},
+		{"-", 2, 0, errInvalid},
+		{" 5", 2, 0, errInvalid},
+		{"1,000", 2, 0, errInvalid},
+		{"abc", 2, 0, errInvalid},
+	}
+
+	for _, test := range tests {
+		got, err := parseDecimal(test.amount, test.decimals)
+		switch {
+		case test.err == nil && err != nil:
+			t.Errorf("%q: got error %v", test.amount, err)
+		case test.err == errInvalid && err == nil:
+			t.Errorf("%q: got %d, want an error", test.amount, got)
+		case test.err != nil && test.err != errInvalid && !errors.Is(err, test.err):
+			t.Errorf("%q: got error %v, want %v", test.amount, err, test.err)
+		case test.err == nil && got != test.want:
+			t.Errorf("%q: got %d, want %d", test.amount, got, test.want)
+		}
+	}
+}
+
+var errInvalid = errors.New("any error")
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		want     Money
+		ok       bool
+	}{
+		{"12000", "KRW", 12000, true},
+		{"-500", "JPY", -500, true},
+		{"12.34", "USD", 1234, true},
+		{"0.5", "EUR", 50, true},
+		{"7.10", "GBP", 710, true},
+		{"88.8", "CNY", 8880, true},
+		{"1.5", "", 150, true}, // 2 decimals of an unknown currency
+		{"12000.5", "KRW", 0, false},
+		{"1.234", "USD", 0, false},
+		{"--5", "USD", 0, false},
+		// KRW is scaled by 100 in a Total
+		{"92233720368547758", "KRW", 92233720368547758, true},
+		{"92233720368547759", "KRW", 0, false},
+		{"-92233720368547759", "KRW", 0, false},
+		{"92233720368547758.07", "USD", math.MaxInt64, true},
+	}
+
+	for _, test := range tests {
+		got, err := parseMoney(test.amount, test.currency)
+		if test.ok && (err != nil || got != test.want) {
+			t.Errorf("%s %s: got %d, %v, want %d", test.amount, test.currency, got, err, test.want)
+		}
+		if !test.ok && err == nil {
+			t.Errorf("%s %s: got %d, want an error", test.amount, test.currency, got)
+		}
+	}
+}
+
+func TestFormatMoney(t *testing.T) {
+	tests := []struct {
+		amount   Money
+		currency string
+		want     string
+	}{
+		{0, "KRW", "0"},
+		{12000, "KRW", "12000"},
+		{-12000, "JPY", "-12000"},
+		{1234, "USD", "12.34"},
+		{5, "USD", "0.05"},
+		{-5, "EUR", "-0.05"},
+		{100, "GBP", "1.00"},
+		{0, "USD", "0.00"},
+		{math.MaxInt64, "USD", "92233720368547758.07"},
+	}
+
+	for _, test := range tests {
+		got := formatMoney(test.amount, test.currency)
+		if got != test.want {
+			t.Errorf("%d %s: got %s, want %s", test.amount, test.currency, got, test.want)
+		}
+
+		// Formatted amounts parse back
+		if back, err := parseMoney(got, test.currency); err != nil || back != test.amount {
+			t.Errorf("%s %s: parsed back to %d, %v", got, test.currency, back, err)
+		}
+	}
+
+	if got := Total(-123456).String(); got != "-1234.56" {
+		t.Errorf("total: got %s", got)
+	}
+}
+
+func TestToTotal(t *testing.T) {
+	tests := []struct {
+		amount   Money
+		currency string
+		want     Total
+	}{
+		{12000, "KRW", 1200000},
+		{-12000, "JPY", -1200000},
+		{1234, "USD", 1234},
+		{-5, "EUR", -5},
+		{7, "", 7},
+		{math.MaxInt64 / 100, "KRW", math.MaxInt64 / 100 * 100},
+		{math.MaxInt64, "USD", math.MaxInt64},
+		// Over the range of Total - stops at the limit
+		{math.MaxInt64/100 + 1, "KRW", math.MaxInt64},
+		{math.MinInt64/100 - 1, "KRW", math.MinInt64},
+		{math.MaxInt64, "JPY", math.MaxInt64},
+	}
+
+	for _, test := range tests {
+		if got := toTotal(test.amount, test.currency); got != test.want {
+			t.Errorf("%d %s: got %d, want %d", test.amount, test.currency, got, test.want)
+		}
+	}
+}
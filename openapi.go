@@ -0,0 +1,515 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const apiPrefix = "/api/v1"
+
+// Route of the versioned API. The mux and the OpenAPI document are both built from apiRoutes.
+type apiRoute struct {
+	Method   string
+	Path     string // ServeMux pattern under apiPrefix, path parameters as {name}
+	Handler  http.HandlerFunc
+	Tag      string
+	Summary  string
+	Query    []apiParam
+	Body     string // Schema of the request body
+	Status   int    // Success status
+	Response string // Schema of the success response
+}
+
+type apiParam struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+var apiRoutes = []apiRoute{
+	{Method: "GET", Path: "/setup/db", Handler: databaseSetupHandler, Tag: "setup", Summary: "Unlock or create the ledger", Query: []apiParam{{"password", "Password or recovery key", true}}, Status: http.StatusOK, Response: "SetupResult"},
+	{Method: "GET", Path: "/setup/db/password", Handler: databasePasswordChangeHandler, Tag: "setup", Summary: "Change the password", Query: []apiParam{{"old-password", "Current password or recovery key", true}, {"new-password", "New password", true}}, Status: http.StatusOK, Response: "Status"},
+	{Method: "GET", Path: "/setup/keyslots", Handler: getKeySlotListHandler, Tag: "setup", Summary: "List key slots", Status: http.StatusOK, Response: "KeySlotList"},
+	{Method: "POST", Path: "/setup/keyslots", Handler: addKeySlotHandler, Tag: "setup", Summary: "Add a key slot", Body: "KeySlotRequest", Status: http.StatusCreated, Response: "KeySlotResult"},
+	{Method: "DELETE", Path: "/setup/keyslots/{id}", Handler: deleteKeySlotHandler, Tag: "setup", Summary: "Remove a key slot", Body: "KeySlotDeleteRequest", Status: http.StatusOK, Response: "Status"},
+
+	{Method: "POST", Path: "/accounts", Handler: addAccountHandler, Tag: "accounts", Summary: "Add an account", Body: "Account", Status: http.StatusCreated, Response: "Created"},
+	{Method: "GET", Path: "/accounts", Handler: getAccountListHandler, Tag: "accounts", Summary: "List accounts", Status: http.StatusOK, Response: "AccountList"},
+	{Method: "GET", Path: "/accounts/{id}", Handler: getAccountHandler, Tag: "accounts", Summary: "Get an account", Status: http.StatusOK, Response: "Account"},
+	{Method: "PUT", Path: "/accounts/{id}", Handler: updateAccountHandler, Tag: "accounts", Summary: "Update an account", Body: "Account", Status: http.StatusOK, Response: "Status"},
+	{Method: "DELETE", Path: "/accounts/{id}", Handler: deleteAccountHandler, Tag: "accounts", Summary: "Delete an account", Status: http.StatusOK, Response: "Status"},
+	{Method: "GET", Path: "/accounts/{id}/benefits", Handler: getCardBenefitReportHandler, Tag: "accounts", Summary: "Card benefits of a credit account by the spending of the previous use period", Query: []apiParam{
+		{"month", "YYYY-MM of the repay date, the bill of the current use period by default", false},
+	}, Status: http.StatusOK, Response: "CardBenefitReport"},
+
+	{Method: "POST", Path: "/categories", Handler: addCategoryHandler, Tag: "categories", Summary: "Add a category", Body: "Category", Status: http.StatusCreated, Response: "Created"},
+	{Method: "GET", Path: "/categories", Handler: getCategoryListHandler, Tag: "categories", Summary: "List categories", Status: http.StatusOK, Response: "CategoryList"},
+	{Method: "GET", Path: "/categories/{id}", Handler: getCategoryHandler, Tag: "categories", Summary: "Get a category", Status: http.StatusOK, Response: "Category"},
+	{Method: "PUT", Path: "/categories/{id}", Handler: updateCategoryHandler, Tag: "categories", Summary: "Update a category", Body: "Category", Status: http.StatusOK, Response: "Status"},
+	{Method: "DELETE", Path: "/categories/{id}", Handler: deleteCategoryHandler, Tag: "categories", Summary: "Delete a category", Status: http.StatusOK, Response: "Status"},
+
+	{Method: "POST", Path: "/records", Handler: addRecordHandler, Tag: "records", Summary: "Add a record", Body: "Record", Status: http.StatusCreated, Response: "Created"},
+	{Method: "GET", Path: "/records", Handler: getRecordListHandler, Tag: "records", Summary: "List or search records with stats", Query: []apiParam{
+		{"from", "Start date, YYYY-MM-DD. Without from and to, the current month of the settings", false},
+		{"to", "End date, YYYY-MM-DD", false},
+		{"q", "Search words, separated by spaces. Without q, all records of the period", false},
+		{"queryType", "AND or OR(default) of the search words", false},
+		{"account-id", "Only records of the account", false},
+	}, Status: http.StatusOK, Response: "RecordList"},
+	{Method: "GET", Path: "/records/{id}", Handler: getRecordHandler, Tag: "records", Summary: "Get a record", Status: http.StatusOK, Response: "Record"},
+	{Method: "PUT", Path: "/records/{id}", Handler: updateRecordHandler, Tag: "records", Summary: "Update a record", Body: "Record", Status: http.StatusOK, Response: "Status"},
+	{Method: "DELETE", Path: "/records/{id}", Handler: deleteRecordHandler, Tag: "records", Summary: "Delete a record", Status: http.StatusOK, Response: "Status"},
+
+	{Method: "GET", Path: "/reports/trend", Handler: getTrendReportHandler, Tag: "reports", Summary: "Totals by day, week, month or year", Query: []apiParam{
+		{"from", "Start date, YYYY-MM-DD. Without from and to, the current year of the settings", false},
+		{"to", "End date, YYYY-MM-DD", false},
+		{"granularity", "day, week, month(default) or year. Weeks and months follow the settings", false},
+		{"group-by", "Series by category, account or type(default)", false},
+	}, Status: http.StatusOK, Response: "TrendReport"},
+	{Method: "GET", Path: "/reports/compare", Handler: getCompareReportHandler, Tag: "reports", Summary: "Spending changes of a month or year by category and account", Query: []apiParam{
+		{"period", "YYYY-MM for a month or YYYY for a year of the settings. The current month by default", false},
+		{"against", "previous(default) period or the same period of last-year", false},
+	}, Status: http.StatusOK, Response: "CompareReport"},
+	{Method: "GET", Path: "/reports/calendar", Handler: getCalendarReportHandler, Tag: "reports", Summary: "Daily totals of a year with weekday and hour of day breakdowns", Query: []apiParam{
+		{"year", "YYYY, this year by default", false},
+	}, Status: http.StatusOK, Response: "CalendarReport"},
+	{Method: "GET", Path: "/reports/savings-rate", Handler: getSavingsReportHandler, Tag: "reports", Summary: "Monthly income, spending, net savings and savings rate", Query: []apiParam{
+		{"from", "Start date, YYYY-MM-DD. Without from and to, the current year of the settings", false},
+		{"to", "End date, YYYY-MM-DD", false},
+	}, Status: http.StatusOK, Response: "SavingsReport"},
+	{Method: "GET", Path: "/reports/forecast", Handler: getForecastHandler, Tag: "reports", Summary: "Day by day balance of the direct accounts with card repayments, recurring items and average spending", Query: []apiParam{
+		{"months", "Months after today, 1-24. 6 by default", false},
+	}, Status: http.StatusOK, Response: "Forecast"},
+	{Method: "GET", Path: "/reports/tax-deduction", Handler: getTaxDeductionReportHandler, Tag: "reports", Summary: "Estimated card deduction of 연말정산 with suggestions", Query: []apiParam{
+		{"year", "YYYY, this year by default", false},
+		{"salary", "Gross salary of the year in KRW", true},
+	}, Status: http.StatusOK, Response: "TaxDeductionReport"},
+	{Method: "GET", Path: "/reports/dashboard", Handler: getDashboardHandler, Tag: "reports", Summary: "This month against last month, next card repayments and top spending", Status: http.StatusOK, Response: "Dashboard"},
+
+	{Method: "GET", Path: "/recurring", Handler: getRecurringItemsHandler, Tag: "recurring", Summary: "List recurring expenses and incomes", Status: http.StatusOK, Response: "RecurringItemList"},
+	{Method: "PUT", Path: "/recurring", Handler: updateRecurringItemsHandler, Tag: "recurring", Summary: "Replace recurring expenses and incomes", Body: "RecurringItemList", Status: http.StatusOK, Response: "Status"},
+
+	{Method: "GET", Path: "/rules", Handler: getRecordRulesHandler, Tag: "rules", Summary: "List categorization rules", Status: http.StatusOK, Response: "RecordRuleList"},
+	{Method: "PUT", Path: "/rules", Handler: updateRecordRulesHandler, Tag: "rules", Summary: "Replace categorization rules, applied in order to new and imported records", Body: "RecordRuleList", Status: http.StatusOK, Response: "Status"},
+	{Method: "POST", Path: "/rules/apply", Handler: applyRecordRulesHandler, Tag: "rules", Summary: "Recategorize the records of a period by the rules", Query: []apiParam{
+		{"from", "Start date, YYYY-MM-DD. Without from and to, the current year of the settings", false},
+		{"to", "End date, YYYY-MM-DD", false},
+		{"dry-run", "true to report the changes without updating the records", false},
+		{"overwrite", "true to replace the categories and accounts set before. By default only blank ones are filled.", false},
+	}, Status: http.StatusOK, Response: "RuleApplyResult"},
+
+	{Method: "GET", Path: "/settings", Handler: getSettingsHandler, Tag: "settings", Summary: "Get the settings", Status: http.StatusOK, Response: "Settings"},
+	{Method: "PUT", Path: "/settings", Handler: updateSettingsHandler, Tag: "settings", Summary: "Replace the settings", Body: "Settings", Status: http.StatusOK, Response: "Status"},
+}
+
+func registerAPIRoutes(mux *http.ServeMux) {
+	for _, route := range apiRoutes {
+		mux.HandleFunc(route.Method+" "+apiPrefix+route.Path, route.Handler)
+	}
+	mux.HandleFunc("GET "+apiPrefix+"/openapi.json", openAPIHandler)
+}
+
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(buildOpenAPIDocument())
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+// buildOpenAPIDocument generates the OpenAPI 3 document of apiRoutes
+func buildOpenAPIDocument() map[string]interface{} {
+	paths := map[string]interface{}{}
+
+	for _, route := range apiRoutes {
+		parameters := []interface{}{}
+		for _, match := range pathParamPattern.FindAllStringSubmatch(route.Path, -1) {
+			parameters = append(parameters, map[string]interface{}{
+				"name": match[1], "in": "path", "required": true, "schema": map[string]string{"type": "string"},
+			})
+		}
+		for _, param := range route.Query {
+			parameters = append(parameters, map[string]interface{}{
+				"name": param.Name, "in": "query", "required": param.Required, "description": param.Description, "schema": map[string]string{"type": "string"},
+			})
+		}
+
+		operation := map[string]interface{}{
+			"tags":        []string{route.Tag},
+			"summary":     route.Summary,
+			"operationId": operationID(route),
+			"parameters":  parameters,
+			"responses": map[string]interface{}{
+				strconv.Itoa(route.Status): map[string]interface{}{
+					"description": http.StatusText(route.Status),
+					"content":     jsonContent(route.Response),
+				},
+				"default": map[string]interface{}{
+					"description": "Error - 400 validation, 404 not found, 409 conflict or migration dry run, 423 locked",
+					"content":     jsonContent("Error"),
+				},
+			},
+		}
+		if route.Body != "" {
+			operation["requestBody"] = map[string]interface{}{"required": true, "content": jsonContent(route.Body)}
+		}
+
+		path := apiPrefix + route.Path
+		if _, exist := paths[path]; !exist {
+			paths[path] = map[string]interface{}{}
+		}
+		paths[path].(map[string]interface{})[strings.ToLower(route.Method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info":    map[string]string{"title": "Ledger API", "version": "1"},
+		"servers": []map[string]string{{"url": "/"}},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": openAPISchemas,
+		},
+	}
+}
+
+// operationID like "getRecords", "getRecordsByID"
+func operationID(route apiRoute) string {
+	name := strings.ToLower(route.Method)
+	for _, part := range strings.Split(strings.Trim(route.Path, "/"), "/") {
+		if pathParamPattern.MatchString(part) {
+			name += "ByID"
+			continue
+		}
+		for _, word := range strings.Split(part, "-") {
+			name += strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return name
+}
+
+func jsonContent(schema string) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{
+			"schema": map[string]string{"$ref": "#/components/schemas/" + schema},
+		},
+	}
+}
+
+func stringProperties(names ...string) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for _, name := range names {
+		properties[name] = map[string]string{"type": "string"}
+	}
+	return properties
+}
+
+func arrayOf(schema string) map[string]interface{} {
+	return map[string]interface{}{"type": "array", "items": map[string]string{"$ref": "#/components/schemas/" + schema}}
+}
+
+func withProperties(properties map[string]interface{}, more map[string]interface{}) map[string]interface{} {
+	for name, property := range more {
+		properties[name] = property
+	}
+	return properties
+}
+
+var decimalProperty = map[string]string{"type": "number", "description": "Decimal in the currency, without float rounding"}
+
+// Schemas follow the JSON of types.go
+var openAPISchemas = map[string]interface{}{
+	"Status":  map[string]interface{}{"type": "object", "properties": stringProperties("status")},
+	"Created": map[string]interface{}{"type": "object", "properties": stringProperties("status", "id")},
+	"Error": map[string]interface{}{
+		"type":     "object",
+		"required": []string{"code", "message"},
+		"properties": withProperties(stringProperties("code", "message", "field"), map[string]interface{}{
+			"migrations": map[string]interface{}{"type": "array", "description": "Reports of the code migration_dry_run", "items": map[string]string{"$ref": "#/components/schemas/MigrationReport"}},
+		}),
+	},
+	"MigrationReport": map[string]interface{}{
+		"type":       "object",
+		"properties": withProperties(stringProperties("description", "kind"), map[string]interface{}{"version": map[string]string{"type": "integer"}, "changed": map[string]string{"type": "integer"}}),
+	},
+	"SetupResult": map[string]interface{}{"type": "object", "properties": stringProperties("status", "recovery-key")},
+	"KeySlot": map[string]interface{}{
+		"type": "object",
+		"properties": withProperties(stringProperties("id", "type", "RegDTTM"), map[string]interface{}{
+			"kdf": map[string]interface{}{"type": "object", "properties": withProperties(stringProperties("algorithm"), map[string]interface{}{"version": map[string]string{"type": "integer"}})},
+		}),
+	},
+	"KeySlotList":          arrayOf("KeySlot"),
+	"KeySlotDeleteRequest": map[string]interface{}{"type": "object", "required": []string{"password"}, "properties": stringProperties("password")},
+	"KeySlotRequest":       map[string]interface{}{"type": "object", "required": []string{"password", "type"}, "properties": stringProperties("password", "type", "new-password")},
+	"KeySlotResult":        map[string]interface{}{"type": "object", "properties": stringProperties("status", "id", "recovery-key")},
+	"Account": map[string]interface{}{
+		"type":     "object",
+		"required": []string{"account-name", "pay-type"},
+		"properties": withProperties(stringProperties("id", "account-name", "pay-type", "repay-day", "use-day-from", "use-day-to", "description", "opening-balance", "repay-account-id", "deduction-class", "required-spending", "RegDTTM"), map[string]interface{}{
+			"excluded-categories": map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}},
+			"benefits":            arrayOf("CardBenefit"),
+		}),
+	},
+	"CardBenefit": map[string]interface{}{
+		"type":       "object",
+		"required":   []string{"kind", "rate"},
+		"properties": withProperties(stringProperties("name", "category", "kind", "monthly-cap"), map[string]interface{}{"rate": map[string]string{"type": "number"}}),
+	},
+	"CardBenefitUsage": map[string]interface{}{
+		"type": "object",
+		"properties": withProperties(stringProperties("name", "category", "kind"), map[string]interface{}{
+			"rate": map[string]string{"type": "number"}, "spending": decimalProperty, "earned": decimalProperty, "cap": decimalProperty, "remaining": decimalProperty,
+		}),
+	},
+	"CardBenefitReport": map[string]interface{}{
+		"type": "object",
+		"properties": withProperties(stringProperties("currency", "account-id", "account-name", "month", "repay-date", "use-date-from", "use-date-to", "previous-date-from", "previous-date-to"), map[string]interface{}{
+			"previous-spending":  decimalProperty,
+			"required-spending":  decimalProperty,
+			"qualified":          map[string]string{"type": "boolean"},
+			"spending":           decimalProperty,
+			"remaining-spending": decimalProperty,
+			"benefits":           arrayOf("CardBenefitUsage"),
+			"earned":             decimalProperty,
+			"excluded-spending":  decimalProperty,
+		}),
+	},
+	"AccountList": arrayOf("Account"),
+	"Category": map[string]interface{}{
+		"type":       "object",
+		"required":   []string{"category-name"},
+		"properties": stringProperties("id", "category-name", "RegDTTM"),
+	},
+	"CategoryList": arrayOf("Category"),
+	"Record": map[string]interface{}{
+		"type":     "object",
+		"required": []string{"transaction-type", "pay-type", "amount", "date"},
+		"properties": withProperties(stringProperties("id", "transaction-type", "account-id", "pay-type", "currency", "category", "description", "date", "time", "deduction-class", "RegDTTM"), map[string]interface{}{
+			"amount": decimalProperty,
+			"tags":   map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}},
+		}),
+	},
+	"TrendBucket": map[string]interface{}{
+		"type":       "object",
+		"properties": withProperties(stringProperties("label", "from", "to"), map[string]interface{}{"pay": decimalProperty, "credit-pay": decimalProperty, "income": decimalProperty}),
+	},
+	"TrendSeries": map[string]interface{}{
+		"type": "object",
+		"properties": withProperties(stringProperties("key", "label"), map[string]interface{}{
+			"pay":        map[string]interface{}{"type": "array", "items": decimalProperty},
+			"credit-pay": map[string]interface{}{"type": "array", "items": decimalProperty},
+			"income":     map[string]interface{}{"type": "array", "items": decimalProperty},
+		}),
+	},
+	"TrendReport": map[string]interface{}{
+		"type": "object",
+		"properties": withProperties(stringProperties("granularity", "group-by", "currency"), map[string]interface{}{
+			"labels":  map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}},
+			"buckets": arrayOf("TrendBucket"),
+			"series":  arrayOf("TrendSeries"),
+		}),
+	},
+	"ComparePeriod": map[string]interface{}{
+		"type":       "object",
+		"properties": withProperties(stringProperties("label", "from", "to"), map[string]interface{}{"sum-pay": decimalProperty, "sum-credit-pay": decimalProperty, "sum-income": decimalProperty}),
+	},
+	"CompareDelta": map[string]interface{}{
+		"type": "object",
+		"properties": withProperties(stringProperties("key", "label", "status"), map[string]interface{}{
+			"current":     decimalProperty,
+			"previous":    decimalProperty,
+			"change":      decimalProperty,
+			"percent":     map[string]interface{}{"type": "number", "nullable": true, "description": "Change in percent of previous, null when previous is 0"},
+			"top-records": arrayOf("Record"),
+		}),
+	},
+	"CompareReport": map[string]interface{}{
+		"type": "object",
+		"properties": withProperties(stringProperties("against", "currency"), map[string]interface{}{
+			"current":                map[string]string{"$ref": "#/components/schemas/ComparePeriod"},
+			"previous":               map[string]string{"$ref": "#/components/schemas/ComparePeriod"},
+			"categories":             arrayOf("CompareDelta"),
+			"accounts":               arrayOf("CompareDelta"),
+			"new-categories":         map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}},
+			"disappeared-categories": map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}},
+		}),
+	},
+	"CalendarDay": map[string]interface{}{
+		"type":       "object",
+		"properties": withProperties(stringProperties("date"), map[string]interface{}{"spending": decimalProperty, "income": decimalProperty, "count": map[string]string{"type": "integer"}}),
+	},
+	"CalendarBucket": map[string]interface{}{
+		"type":       "object",
+		"properties": withProperties(stringProperties("key"), map[string]interface{}{"spending": decimalProperty, "count": map[string]string{"type": "integer"}}),
+	},
+	"CalendarReport": map[string]interface{}{
+		"type": "object",
+		"properties": withProperties(stringProperties("currency"), map[string]interface{}{
+			"year":               map[string]string{"type": "integer"},
+			"spending":           decimalProperty,
+			"income":             decimalProperty,
+			"max-daily-spending": decimalProperty,
+			"days":               arrayOf("CalendarDay"),
+			"weekdays":           arrayOf("CalendarBucket"),
+			"hours":              arrayOf("CalendarBucket"),
+			"no-time":            map[string]string{"$ref": "#/components/schemas/CalendarBucket"},
+		}),
+	},
+	"SavingsMonth": map[string]interface{}{
+		"type": "object",
+		"properties": withProperties(stringProperties("label", "from", "to"), map[string]interface{}{
+			"income": decimalProperty, "spending": decimalProperty, "net": decimalProperty,
+			"rate": map[string]interface{}{"type": "number", "nullable": true, "description": "Net in percent of income, null without income"},
+		}),
+	},
+	"SavingsReport": map[string]interface{}{
+		"type": "object",
+		"properties": withProperties(stringProperties("currency"), map[string]interface{}{
+			"months": arrayOf("SavingsMonth"),
+			"income": decimalProperty, "spending": decimalProperty, "net": decimalProperty,
+			"rate": map[string]interface{}{"type": "number", "nullable": true},
+		}),
+	},
+	"RecurringItem": map[string]interface{}{
+		"type":     "object",
+		"required": []string{"transaction-type", "currency", "amount", "day"},
+		"properties": withProperties(stringProperties("name", "transaction-type", "account-id", "category", "currency", "amount", "start-date", "end-date"), map[string]interface{}{
+			"day":   map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 31},
+			"month": map[string]interface{}{"type": "integer", "minimum": 0, "maximum": 12, "description": "0 for every month"},
+		}),
+	},
+	"RecurringItemList": arrayOf("RecurringItem"),
+	"RecordRule": map[string]interface{}{
+		"type":     "object",
+		"required": []string{"name"},
+		"properties": withProperties(stringProperties("name", "description-contains", "description-regex", "amount-min", "amount-max", "currency", "account-id", "time-from", "time-to", "category", "set-account-id"), map[string]interface{}{
+			"tags": map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}},
+		}),
+	},
+	"RecordRuleList": arrayOf("RecordRule"),
+	"RuleChange": map[string]interface{}{
+		"type": "object",
+		"properties": withProperties(stringProperties("record-id", "date", "description", "category", "new-category", "account-id", "new-account-id"), map[string]interface{}{
+			"rules":    map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}},
+			"tags":     map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}},
+			"new-tags": map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}},
+		}),
+	},
+	"RuleApplyResult": map[string]interface{}{
+		"type": "object",
+		"properties": withProperties(stringProperties("from", "to"), map[string]interface{}{
+			"dry-run":   map[string]string{"type": "boolean"},
+			"overwrite": map[string]string{"type": "boolean"},
+			"checked":   map[string]string{"type": "integer"},
+			"changes":   arrayOf("RuleChange"),
+		}),
+	},
+	"ForecastEvent": map[string]interface{}{
+		"type":       "object",
+		"properties": withProperties(stringProperties("date", "account-id", "kind", "description"), map[string]interface{}{"amount": decimalProperty}),
+	},
+	"ForecastDay": map[string]interface{}{
+		"type":       "object",
+		"properties": withProperties(stringProperties("date"), map[string]interface{}{"change": decimalProperty, "balance": decimalProperty}),
+	},
+	"ForecastAccount": map[string]interface{}{
+		"type": "object",
+		"properties": withProperties(stringProperties("account-id", "account-name", "min-date"), map[string]interface{}{
+			"balance":        decimalProperty,
+			"min-balance":    decimalProperty,
+			"negative-dates": map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}},
+			"days":           arrayOf("ForecastDay"),
+		}),
+	},
+	"ForecastVariable": map[string]interface{}{
+		"type":       "object",
+		"properties": withProperties(stringProperties("account-id", "category"), map[string]interface{}{"monthly": decimalProperty}),
+	},
+	"Forecast": map[string]interface{}{
+		"type": "object",
+		"properties": withProperties(stringProperties("currency", "from", "to"), map[string]interface{}{
+			"accounts":       arrayOf("ForecastAccount"),
+			"events":         arrayOf("ForecastEvent"),
+			"variable":       arrayOf("ForecastVariable"),
+			"negative-dates": map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}},
+			"unrepaid-cards": map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}, "description": "Credit accounts without a direct repay account. Their charges are not in the balances."},
+		}),
+	},
+	"TaxDeductionClass": map[string]interface{}{
+		"type": "object",
+		"properties": withProperties(stringProperties("class"), map[string]interface{}{
+			"spending": decimalProperty, "rate": map[string]string{"type": "number"}, "threshold": decimalProperty, "deduction": decimalProperty,
+		}),
+	},
+	"TaxDeductionSuggestion": map[string]interface{}{
+		"type":       "object",
+		"properties": withProperties(stringProperties("class", "reason"), map[string]interface{}{"spending": decimalProperty, "deduction": decimalProperty}),
+	},
+	"TaxDeductionReport": map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"year":             map[string]string{"type": "integer"},
+			"salary":           decimalProperty,
+			"spending":         decimalProperty,
+			"threshold":        decimalProperty,
+			"basic-limit":      decimalProperty,
+			"additional-limit": decimalProperty,
+			"deduction":        decimalProperty,
+			"classes":          arrayOf("TaxDeductionClass"),
+			"ineligible":       decimalProperty,
+			"foreign":          map[string]interface{}{"type": "object", "description": "Spending not in KRW by currency, in the currency. Never deducted.", "additionalProperties": decimalProperty},
+			"suggestions":      arrayOf("TaxDeductionSuggestion"),
+		},
+	},
+	"DashboardRepayment": map[string]interface{}{
+		"type":       "object",
+		"properties": withProperties(stringProperties("account-id", "account-name", "repay-date", "use-date-from", "use-date-to"), map[string]interface{}{"amount": decimalProperty, "count": map[string]string{"type": "integer"}}),
+	},
+	"DashboardItem": map[string]interface{}{
+		"type":       "object",
+		"properties": withProperties(stringProperties("key"), map[string]interface{}{"amount": decimalProperty, "count": map[string]string{"type": "integer"}}),
+	},
+	"Dashboard": map[string]interface{}{
+		"type": "object",
+		"properties": withProperties(stringProperties("currency"), map[string]interface{}{
+			"current":           map[string]string{"$ref": "#/components/schemas/ComparePeriod"},
+			"previous":          map[string]string{"$ref": "#/components/schemas/ComparePeriod"},
+			"spending":          decimalProperty,
+			"previous-spending": decimalProperty,
+			"spending-change":   decimalProperty,
+			"spending-percent":  map[string]interface{}{"type": "number", "nullable": true},
+			"income":            decimalProperty,
+			"net":               decimalProperty,
+			"repayments":        arrayOf("DashboardRepayment"),
+			"top-categories":    arrayOf("DashboardItem"),
+			"top-merchants":     arrayOf("DashboardItem"),
+		}),
+	},
+	"Settings": map[string]interface{}{
+		"type": "object",
+		"properties": withProperties(stringProperties("base-currency", "default-account-id", "default-category", "week-start", "RegDTTM"), map[string]interface{}{
+			"holidays":           map[string]interface{}{"type": "array", "description": "YYYY-MM-DD, more holidays than the Korean public holidays", "items": map[string]string{"type": "string"}},
+			"fiscal-month-start": map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 28},
+			"exchange-rates":     map[string]interface{}{"type": "object", "description": "Base currency per 1 unit of the currency", "additionalProperties": map[string]string{"type": "number"}},
+		}),
+	},
+	"Stat": map[string]interface{}{
+		"type":       "object",
+		"properties": withProperties(stringProperties("category"), map[string]interface{}{"amount": decimalProperty}),
+	},
+	"RecordList": map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"records":        arrayOf("Record"),
+			"stats":          map[string]interface{}{"type": "object", "additionalProperties": map[string]string{"$ref": "#/components/schemas/Stat"}},
+			"stats-credit":   map[string]interface{}{"type": "object", "additionalProperties": map[string]string{"$ref": "#/components/schemas/Stat"}},
+			"stats-income":   map[string]interface{}{"type": "object", "additionalProperties": map[string]string{"$ref": "#/components/schemas/Stat"}},
+			"sum-pay":        decimalProperty,
+			"sum-credit-pay": decimalProperty,
+			"sum-income":     decimalProperty,
+		},
+	},
+}
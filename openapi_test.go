@@ -0,0 +1,354 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Go types of the schemas. The other schemas are checked by the responses of the handlers.
+var openAPISchemaTypes = map[string]interface{}{
+	"Error":                  AppError{},
+	"MigrationReport":        MigrationReport{},
+	"Account":                Account{},
+	"CardBenefit":            CardBenefit{},
+	"CardBenefitUsage":       CardBenefitUsage{},
+	"CardBenefitReport":      CardBenefitReport{},
+	"Category":               Category{},
+	"Record":                 Record{},
+	"TrendBucket":            TrendBucket{},
+	"TrendSeries":            TrendSeries{},
+	"TrendReport":            TrendReport{},
+	"ComparePeriod":          ComparePeriod{},
+	"CompareDelta":           CompareDelta{},
+	"CompareReport":          CompareReport{},
+	"CalendarDay":            CalendarDay{},
+	"CalendarBucket":         CalendarBucket{},
+	"CalendarReport":         CalendarReport{},
+	"SavingsMonth":           SavingsMonth{},
+	"SavingsReport":          SavingsReport{},
+	"RecurringItem":          RecurringItem{},
+	"RecordRule":             RecordRule{},
+	"RuleChange":             RuleChange{},
+	"RuleApplyResult":        RuleApplyResult{},
+	"ForecastEvent":          ForecastEvent{},
+	"ForecastDay":            ForecastDay{},
+	"ForecastAccount":        ForecastAccount{},
+	"ForecastVariable":       ForecastVariable{},
+	"Forecast":               Forecast{},
+	"TaxDeductionClass":      TaxDeductionClass{},
+	"TaxDeductionSuggestion": TaxDeductionSuggestion{},
+	"TaxDeductionReport":     TaxDeductionReport{},
+	"DashboardRepayment":     DashboardRepayment{},
+	"DashboardItem":          DashboardItem{},
+	"Dashboard":              Dashboard{},
+	"Settings":               Settings{},
+	"Stat":                   Stat{},
+}
+
+// fillValue sets every field, so that omitempty fields are written too
+func fillValue(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString("KRW")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(1)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(1.5)
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Pointer:
+		v.Set(reflect.New(v.Type().Elem()))
+		fillValue(v.Elem())
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		fillValue(v.Index(0))
+	case reflect.Map:
+		v.Set(reflect.MakeMap(v.Type()))
+		key, value := reflect.New(v.Type().Key()).Elem(), reflect.New(v.Type().Elem()).Elem()
+		fillValue(key)
+		fillValue(value)
+		v.SetMapIndex(key, value)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				fillValue(v.Field(i))
+			}
+		}
+	}
+}
+
+// validateSchema reports the differences of value(decoded JSON) from schema. Unknown properties are errors.
+// null is accepted for any type - nil slices and pointers of Go.
+func validateSchema(path string, schema interface{}, value interface{}) []string {
+	object := toSchemaMap(schema)
+	if ref, ok := object["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		referred, exist := openAPISchemas[name]
+		if !exist {
+			return []string{fmt.Sprintf("%s: unknown $ref %s", path, ref)}
+		}
+		return validateSchema(path, referred, value)
+	}
+	if value == nil {
+		return nil
+	}
+
+	errs := []string{}
+	switch object["type"] {
+	case "string":
+		if _, ok := value.(string); !ok {
+			errs = append(errs, fmt.Sprintf("%s: got %T, want string", path, value))
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			errs = append(errs, fmt.Sprintf("%s: got %T, want number", path, value))
+		}
+	case "integer":
+		if number, ok := value.(float64); !ok || number != math.Trunc(number) {
+			errs = append(errs, fmt.Sprintf("%s: got %v, want integer", path, value))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			errs = append(errs, fmt.Sprintf("%s: got %T, want boolean", path, value))
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return append(errs, fmt.Sprintf("%s: got %T, want array", path, value))
+		}
+		for i, item := range items {
+			errs = append(errs, validateSchema(fmt.Sprintf("%s[%d]", path, i), object["items"], item)...)
+		}
+	case "object":
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return append(errs, fmt.Sprintf("%s: got %T, want object", path, value))
+		}
+		properties := toSchemaMap(object["properties"])
+		for name, field := range fields {
+			property, exist := properties[name]
+			if !exist {
+				property, exist = object["additionalProperties"]
+			}
+			if !exist {
+				errs = append(errs, fmt.Sprintf("%s.%s: not in the schema", path, name))
+				continue
+			}
+			errs = append(errs, validateSchema(path+"."+name, property, field)...)
+		}
+		if required, ok := object["required"].([]string); ok {
+			for _, name := range required {
+				if _, exist := fields[name]; !exist {
+					errs = append(errs, fmt.Sprintf("%s.%s: required", path, name))
+				}
+			}
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("%s: schema without type: %v", path, schema))
+	}
+
+	return errs
+}
+
+// toSchemaMap reads the map[string]string and map[string]interface{} of openAPISchemas alike
+func toSchemaMap(schema interface{}) map[string]interface{} {
+	switch schema := schema.(type) {
+	case map[string]interface{}:
+		return schema
+	case map[string]string:
+		results := map[string]interface{}{}
+		for key, value := range schema {
+			results[key] = value
+		}
+		return results
+	}
+	return map[string]interface{}{}
+}
+
+func decodeJSON(t *testing.T, value interface{}) interface{} {
+	t.Helper()
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+// Every property of a type is in its schema and every property of a schema is written by the type
+func TestOpenAPISchemasMatchTypes(t *testing.T) {
+	for name, value := range openAPISchemaTypes {
+		filled := reflect.New(reflect.TypeOf(value)).Elem()
+		fillValue(filled)
+		decoded := decodeJSON(t, filled.Interface())
+
+		for _, err := range validateSchema(name, openAPISchemas[name], decoded) {
+			t.Error(err)
+		}
+
+		properties := toSchemaMap(toSchemaMap(openAPISchemas[name])["properties"])
+		for property := range properties {
+			if _, exist := decoded.(map[string]interface{})[property]; !exist {
+				t.Errorf("%s.%s: not written by %T", name, property, value)
+			}
+		}
+	}
+}
+
+func TestOpenAPIDocumentRefs(t *testing.T) {
+	var refs []string
+	var walk func(value interface{})
+	walk = func(value interface{}) {
+		switch value := value.(type) {
+		case map[string]interface{}:
+			for key, item := range value {
+				if ref, ok := item.(string); ok && key == "$ref" {
+					refs = append(refs, ref)
+				}
+				walk(item)
+			}
+		case []interface{}:
+			for _, item := range value {
+				walk(item)
+			}
+		}
+	}
+	walk(decodeJSON(t, buildOpenAPIDocument()))
+
+	for _, ref := range refs {
+		if _, exist := openAPISchemas[strings.TrimPrefix(ref, "#/components/schemas/")]; !exist {
+			t.Errorf("unknown $ref %s", ref)
+		}
+	}
+	for _, route := range apiRoutes {
+		for _, name := range []string{route.Body, route.Response} {
+			if _, exist := openAPISchemas[name]; name != "" && !exist {
+				t.Errorf("%s %s: unknown schema %s", route.Method, route.Path, name)
+			}
+		}
+	}
+}
+
+// Responses of the handlers match the schemas of their routes
+func TestOpenAPIResponsesMatchHandlers(t *testing.T) {
+	server := newTestServer(t)
+
+	findRoute := func(method, path string) apiRoute {
+		t.Helper()
+		for _, route := range apiRoutes {
+			pattern := strings.ReplaceAll(route.Path, "{id}", "*")
+			if route.Method == method && matchRoutePath(pattern, path) {
+				return route
+			}
+		}
+		t.Fatalf("no route of %s %s", method, path)
+		return apiRoute{}
+	}
+	check := func(method, path string, body interface{}) interface{} {
+		t.Helper()
+		route := findRoute(method, strings.SplitN(path, "?", 2)[0])
+		status, response := doTestRequest(t, server, method, apiPrefix+path, body)
+		if status != route.Status {
+			t.Fatalf("%s %s: got status %d, want %d: %v", method, path, status, route.Status, response)
+		}
+		for _, err := range validateSchema(route.Response, openAPISchemas[route.Response], response) {
+			t.Errorf("%s %s: %s", method, path, err)
+		}
+		return response
+	}
+
+	check("GET", "/setup/db?password=test", nil)
+	check("POST", "/setup/keyslots", map[string]string{"password": "test", "type": "password", "new-password": "second"})
+	check("GET", "/setup/keyslots", nil)
+
+	check("PUT", "/settings", map[string]interface{}{"base-currency": "KRW", "fiscal-month-start": 1, "week-start": "monday", "exchange-rates": map[string]float64{"USD": 1350}})
+	check("GET", "/settings", nil)
+
+	card := check("POST", "/accounts", Account{AccountName: "card", PayType: "credit", RepayDay: "14", UseDayFrom: "1", UseDayTo: "말일", RequiredSpending: "300000",
+		Benefits: []CardBenefit{{Name: "all", Kind: "cashback", Rate: 1, MonthlyCap: "10000"}}}).(map[string]interface{})["id"].(string)
+	check("POST", "/accounts", Account{AccountName: "bank", PayType: "direct", OpeningBalance: "1000000"})
+	check("GET", "/accounts", nil)
+	check("GET", "/accounts/"+card, nil)
+	check("GET", "/accounts/"+card+"/benefits?month=2026-03", nil)
+	check("POST", "/categories", Category{CategoryName: "food"})
+	check("GET", "/categories", nil)
+
+	for i, date := range []string{"2026-01-10", "2026-02-10", "2026-02-11"} {
+		check("POST", "/records", map[string]interface{}{"transaction-type": "record_type_pay", "account-id": card, "pay-type": "credit", "amount": "12000", "category": "food", "description": fmt.Sprintf("lunch %d", i), "date": date, "time": "12:30"})
+	}
+	check("POST", "/records", map[string]interface{}{"transaction-type": "record_type_income", "pay-type": "direct", "amount": "3000000", "category": "salary", "date": "2026-02-25"})
+	records := check("GET", "/records?from=2026-01-01&to=2026-12-31", nil).(map[string]interface{})["records"].([]interface{})
+	check("GET", "/records/"+records[0].(map[string]interface{})["id"].(string), nil)
+	check("GET", "/records?from=2026-01-01&to=2026-12-31&q=lunch", nil)
+
+	check("PUT", "/recurring", []RecurringItem{{Name: "rent", TransactionType: "record_type_pay", Currency: "KRW", Amount: "500000", Day: 25}})
+	check("GET", "/recurring", nil)
+	check("PUT", "/rules", []RecordRule{{Name: "lunch", DescriptionContains: "lunch", Category: "meal", Tags: []string{"work"}}})
+	check("GET", "/rules", nil)
+	check("POST", "/rules/apply?from=2026-01-01&to=2026-12-31&dry-run=true&overwrite=true", nil)
+
+	for _, path := range []string{
+		"/reports/trend?from=2026-01-01&to=2026-12-31&group-by=category",
+		"/reports/compare?period=2026-02",
+		"/reports/calendar?year=2026",
+		"/reports/savings-rate?from=2026-01-01&to=2026-12-31",
+		"/reports/forecast?months=2",
+		"/reports/tax-deduction?year=2026&salary=50000000",
+		"/reports/dashboard",
+	} {
+		check("GET", path, nil)
+	}
+
+	// Error envelope
+	status, response := doTestRequest(t, server, "GET", apiPrefix+"/accounts/account:missing", nil)
+	if status != http.StatusNotFound {
+		t.Fatalf("got status %d, want 404", status)
+	}
+	for _, err := range validateSchema("Error", openAPISchemas["Error"], response) {
+		t.Error(err)
+	}
+}
+
+// matchRoutePath matches path to pattern with * for a path parameter
+func matchRoutePath(pattern, path string) bool {
+	patternParts, pathParts := strings.Split(pattern, "/"), strings.Split(path, "/")
+	if len(patternParts) != len(pathParts) {
+		return false
+	}
+	for i := range patternParts {
+		if patternParts[i] != "*" && patternParts[i] != pathParts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Every schema is checked by a type or a handler response above
+func TestOpenAPISchemasCovered(t *testing.T) {
+	handlerChecked := []string{"Status", "Created", "SetupResult", "KeySlot", "KeySlotList", "KeySlotRequest", "KeySlotDeleteRequest", "KeySlotResult",
+		"AccountList", "CategoryList", "RecurringItemList", "RecordRuleList", "RecordList"}
+
+	names := []string{}
+	for name := range openAPISchemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, exist := openAPISchemaTypes[name]; !exist && !slices.Contains(handlerChecked, name) {
+			t.Errorf("schema %s is not checked", name)
+		}
+	}
+}
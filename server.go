@@ -10,21 +10,30 @@ import (
 	"time"
 )
 
-func StartServer() {
+// NewServeMux returns the routes of the server
+func NewServeMux() *http.ServeMux {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("GET /health", healthHandler)
 
+	// Versioned API and its OpenAPI document - see apiRoutes
+	registerAPIRoutes(mux)
+
+	// Routes before /api/v1, kept as aliases
 	mux.HandleFunc("GET /setup/db", databaseSetupHandler)
 	mux.HandleFunc("GET /setup/db/password", databasePasswordChangeHandler)
+	mux.HandleFunc("GET /setup/keyslot", getKeySlotListHandler)
+	mux.HandleFunc("POST /setup/keyslot", addKeySlotHandler)
+	mux.HandleFunc("DELETE /setup/keyslot", deleteKeySlotHandler)
 
 	// Pay account
 	mux.HandleFunc("POST /account", addAccountHandler)
 	mux.HandleFunc("DELETE /account", deleteAccountHandler)
 	mux.HandleFunc("PUT /account", updateAccountHandler)
 	mux.HandleFunc("GET /account", getAccountListHandler)
+	mux.HandleFunc("GET /account/{id}/benefits", getCardBenefitReportHandler)
 
-	// Pay account
+	// Pay category
 	mux.HandleFunc("POST /category", addCategoryHandler)
 	mux.HandleFunc("DELETE /category", deleteCategoryHandler)
 	mux.HandleFunc("PUT /category", updateCategoryHandler)
@@ -34,12 +43,38 @@ func StartServer() {
 	mux.HandleFunc("POST /record", addRecordHandler)
 	mux.HandleFunc("DELETE /record", deleteRecordHandler)
 	mux.HandleFunc("PUT /record", updateRecordHandler)
-	mux.HandleFunc("GET /record", getRecordHandler)
+	mux.HandleFunc("GET /record", getRecordListHandler)
+
+	// Reports
+	mux.HandleFunc("GET /report/trend", getTrendReportHandler)
+	mux.HandleFunc("GET /report/compare", getCompareReportHandler)
+	mux.HandleFunc("GET /report/dashboard", getDashboardHandler)
+	mux.HandleFunc("GET /report/calendar", getCalendarReportHandler)
+	mux.HandleFunc("GET /report/savings-rate", getSavingsReportHandler)
+	mux.HandleFunc("GET /report/forecast", getForecastHandler)
+	mux.HandleFunc("GET /report/tax-deduction", getTaxDeductionReportHandler)
+
+	// Recurring expenses and incomes
+	mux.HandleFunc("GET /recurring", getRecurringItemsHandler)
+	mux.HandleFunc("PUT /recurring", updateRecurringItemsHandler)
+
+	// Categorization rules
+	mux.HandleFunc("GET /rules", getRecordRulesHandler)
+	mux.HandleFunc("PUT /rules", updateRecordRulesHandler)
+	mux.HandleFunc("POST /rules/apply", applyRecordRulesHandler)
+
+	// Settings
+	mux.HandleFunc("GET /settings", getSettingsHandler)
+	mux.HandleFunc("PUT /settings", updateSettingsHandler)
 
 	// Serve files for html
 	mux.HandleFunc("GET /", handleStaticFiles)
 
-	server := &http.Server{Addr: listenADDR, Handler: mux}
+	return mux
+}
+
+func StartServer() {
+	server := &http.Server{Addr: listenADDR, Handler: NewServeMux()}
 	go func() {
 		fmt.Println("Server starting on " + listenADDR)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -61,12 +96,8 @@ func StartServer() {
 		fmt.Printf("Server forced to shutdown: %v", err)
 	}
 
-	if bleveIndex != nil {
-		bleveIndex.Close()
-	}
-	if db != nil {
-		db.Close()
-	}
+	closeBleveIndex()
+	closeLedgerStore()
 
 	fmt.Println("Server exited")
 }
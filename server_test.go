@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestServer serves NewServeMux on the memory backend, unlocked with the password "test".
+// Key slots and the index live in a temporary directory.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	dir := t.TempDir()
+
+	backend, indexMode := StorageBackend, IndexMode
+	paths := []*string{&keySlotsFile, &kdfHeaderFile, &legacySaltFile, &bleveIndexPath, &badgerPath}
+	oldPaths := []string{}
+	for _, path := range paths {
+		oldPaths = append(oldPaths, *path)
+		*path = filepath.Join(dir, filepath.Base(*path))
+	}
+	argon2Time, argon2Memory := Argon2Time, Argon2Memory
+
+	StorageBackend, IndexMode = storageBackendMemory, indexModeMemory
+	Argon2Time, Argon2Memory = 1, 1024
+
+	server := httptest.NewServer(NewServeMux())
+	t.Cleanup(func() {
+		server.Close()
+		closeBleveIndex()
+		closeLedgerStore()
+
+		StorageBackend, IndexMode = backend, indexMode
+		for i, path := range paths {
+			*path = oldPaths[i]
+		}
+		Argon2Time, Argon2Memory = argon2Time, argon2Memory
+	})
+
+	return server
+}
+
+// doTestRequest sends body as JSON and decodes the JSON response
+func doTestRequest(t *testing.T, server *httptest.Server, method, path string, body interface{}) (int, interface{}) {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = strings.NewReader(string(data))
+	}
+
+	request, err := http.NewRequest(method, server.URL+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := server.Client().Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var result interface{}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	return response.StatusCode, result
+}
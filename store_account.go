@@ -1,48 +1,47 @@
 package server
 
 import (
-	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
-
-	"github.com/dgraph-io/badger/v3"
 )
 
-func addAccount(account Account) error {
+// addAccount returns the ID of the new account
+func addAccount(account Account) (string, error) {
 	var err error
 
 	err = validateAccount(account)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	now := time.Now()
-	timestamp := now.Unix()
-	id := fmt.Sprintf("account:%d", timestamp)
+	id := newEntityID("account")
 	account.ID = id
 	regdttm := now.Format("20060102150405")
 	account.RegDTTM = regdttm
 
-	err = db.Update(func(txn *badger.Txn) error {
-		value, _ := json.Marshal(account)
-		return txn.Set([]byte(id), value)
-	})
+	err = ledger.AddAccount(account)
+	invalidateAccountCache()
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	return bleveIndex.Index(id, account)
+	return id, bleveIndex.Index(id, account)
 }
 
 func deleteAccount(id string) error {
 	var err error
 
-	// Remove Badger record
-	err = db.Update(func(txn *badger.Txn) error {
-		return txn.Delete([]byte(id))
-	})
+	if err := checkAccountUnused(id); err != nil {
+		return err
+	}
+
+	// Remove stored account
+	err = ledger.DeleteAccount(id)
+	invalidateAccountCache()
 	if err != nil {
-		return fmt.Errorf("failed to delete record: %w", err)
+		return fmt.Errorf("failed to delete account: %w", err)
 	}
 
 	// Remove Bleve index
@@ -54,65 +53,55 @@ func deleteAccount(id string) error {
 	return nil
 }
 
-func updateAccount(id string, updatedAccount Account) error {
-	var err error
-
-	var existingAccount Account
-	err = db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(id))
-		if err != nil {
-			return err
-		}
-		return item.Value(func(v []byte) error {
-			return json.Unmarshal(v, &existingAccount)
-		})
-	})
+// checkAccountUnused returns a conflict while records or credit accounts refer to the account
+func checkAccountUnused(id string) error {
+	records, err := ledger.ListRecords(id, "", "")
 	if err != nil {
 		return err
 	}
+	if len(records) > 0 {
+		return newConflictError("account %s has %d record(s)", id, len(records))
+	}
 
-	err = db.Update(func(txn *badger.Txn) error {
-		updatedAccount.RegDTTM = existingAccount.RegDTTM
-		updatedAccount.ID = existingAccount.ID
-		value, _ := json.Marshal(updatedAccount)
-		err = txn.Set([]byte(id), value)
-		if err != nil {
-			return err
+	accounts, err := ledger.ListAccounts()
+	if err != nil {
+		return err
+	}
+	for _, account := range accounts {
+		if account.RepayAccountID == id {
+			return newConflictError("account %s repays %s", id, account.ID)
 		}
+	}
 
-		// Update Bleve index
-		return bleveIndex.Index(id, updatedAccount)
-	})
+	return nil
+}
 
-	return err
+func getAccount(id string) (Account, error) {
+	return ledger.GetAccount(id)
 }
 
-func getAccountList() ([]Account, error) {
-	var results []Account = []Account{}
-
-	err := db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchSize = 10
-		it := txn.NewIterator(opts)
-		defer it.Close()
-
-		for it.Seek([]byte("account:")); it.ValidForPrefix([]byte("account:")); it.Next() {
-			item := it.Item()
-			var account Account
-
-			err := item.Value(func(v []byte) error {
-				return json.Unmarshal(v, &account)
-			})
-			if err != nil {
-				return err
-			}
-
-			results = append(results, account)
-		}
+func updateAccount(id string, updatedAccount Account) error {
+	var err error
+
+	existingAccount, err := ledger.GetAccount(id)
+	if err != nil {
+		return err
+	}
 
-		return nil
-	})
+	updatedAccount.RegDTTM = existingAccount.RegDTTM
+	updatedAccount.ID = existingAccount.ID
+	err = ledger.UpdateAccount(updatedAccount)
+	invalidateAccountCache()
+	if err != nil {
+		return err
+	}
 
+	// Update Bleve index
+	return bleveIndex.Index(id, updatedAccount)
+}
+
+func getAccountList() ([]Account, error) {
+	results, err := ledger.ListAccounts()
 	if err != nil {
 		return []Account{}, err
 	}
@@ -120,36 +109,46 @@ func getAccountList() ([]Account, error) {
 	return results, nil
 }
 
-func getAccountListMAP() (map[string]Account, error) {
-	var results map[string]Account = map[string]Account{}
+// getRecordPayType returns the pay type of a record of account. A hybrid(revolving) account pays directly.
+func getRecordPayType(account Account) string {
+	if account.PayType == "credit" {
+		return "credit"
+	}
+	return "direct"
+}
 
-	err := db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchSize = 10
-		it := txn.NewIterator(opts)
-		defer it.Close()
-
-		for it.Seek([]byte("account:")); it.ValidForPrefix([]byte("account:")); it.Next() {
-			item := it.Item()
-			var account Account
-
-			err := item.Value(func(v []byte) error {
-				return json.Unmarshal(v, &account)
-			})
-			if err != nil {
-				return err
-			}
-
-			// results = append(results, account)
-			results[account.ID] = account
-		}
+// Accounts are read on every search, so keep them in memory until an account write
+var accountCache map[string]Account
+var accountCacheMutex sync.RWMutex
 
-		return nil
-	})
+func invalidateAccountCache() {
+	accountCacheMutex.Lock()
+	accountCache = nil
+	accountCacheMutex.Unlock()
+}
 
+// getAccountListMAP returns the cached map. Do not modify it.
+func getAccountListMAP() (map[string]Account, error) {
+	accountCacheMutex.RLock()
+	cached := accountCache
+	accountCacheMutex.RUnlock()
+	if cached != nil {
+		return cached, nil
+	}
+
+	var results map[string]Account = map[string]Account{}
+
+	accounts, err := ledger.ListAccounts()
 	if err != nil {
 		return map[string]Account{}, err
 	}
+	for _, account := range accounts {
+		results[account.ID] = account
+	}
+
+	accountCacheMutex.Lock()
+	accountCache = results
+	accountCacheMutex.Unlock()
 
 	return results, nil
 }
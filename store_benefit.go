@@ -0,0 +1,148 @@
+package server
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+var cardBenefitKinds = []string{"discount", "cashback"}
+
+// getCardBenefitMonth returns the first day of the repay month whose use period has today
+func getCardBenefitMonth(repayDay, useDayFrom, useDayTo int, today time.Time) time.Time {
+	month := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())
+
+	_, useDateFrom, useDateTo := getCreditDates(repayDay, useDayFrom, useDayTo, month)
+	if today.After(useDateTo) {
+		return month.AddDate(0, 1, 0)
+	}
+	if today.Before(useDateFrom) {
+		return month.AddDate(0, -1, 0)
+	}
+	return month
+}
+
+// getCardSpending sums the credit pay records of the account in a use period, without the excluded categories
+func getCardSpending(account Account, useDateFrom, useDateTo time.Time, settings Settings) (spending, excluded Total, records []Record, err error) {
+	results, err := ledger.ListRecords(account.ID, useDateFrom.Format("2006-01-02"), useDateTo.Format("2006-01-02"))
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	for _, record := range results {
+		if record.TransactionType != "record_type_pay" || record.PayType != "credit" {
+			continue
+		}
+
+		amount := toBaseTotal(record.Amount, record.Currency, settings)
+		if slices.Contains(account.ExcludedCategories, record.Category) {
+			excluded += amount
+			continue
+		}
+		spending += amount
+		records = append(records, record)
+	}
+
+	return spending, excluded, records, nil
+}
+
+// getCardBenefitIndex returns the benefit of a record of category: the benefit of the category before one of all categories,
+// the highest rate of them. -1 without a benefit.
+func getCardBenefitIndex(benefits []CardBenefit, category string) int {
+	best := -1
+	for i, benefit := range benefits {
+		if benefit.Category != "" && benefit.Category != category {
+			continue
+		}
+		if best < 0 {
+			best = i
+			continue
+		}
+
+		specific, bestSpecific := benefit.Category != "", benefits[best].Category != ""
+		if (specific && !bestSpecific) || (specific == bestSpecific && benefit.Rate > benefits[best].Rate) {
+			best = i
+		}
+	}
+
+	return best
+}
+
+// getCardBenefitReport reports the benefits of a credit account for the bill of month, the current bill when month is zero.
+// A record gets one benefit, by getCardBenefitIndex.
+func getCardBenefitReport(id string, month time.Time, today time.Time) (CardBenefitReport, error) {
+	account, err := getAccount(id)
+	if err != nil {
+		return CardBenefitReport{}, err
+	}
+
+	repayDay, useDayFrom, useDayTo, ok := getCreditDays(account)
+	if account.PayType != "credit" || !ok {
+		return CardBenefitReport{}, newBadRequestError("Not a credit account with repay-day and use days: %s", id)
+	}
+
+	settings, err := getSettings()
+	if err != nil {
+		return CardBenefitReport{}, err
+	}
+
+	if month.IsZero() {
+		month = getCardBenefitMonth(repayDay, useDayFrom, useDayTo, today)
+	}
+	repayDate, useDateFrom, useDateTo := getCreditDates(repayDay, useDayFrom, useDayTo, month)
+	_, previousDateFrom, previousDateTo := getCreditDates(repayDay, useDayFrom, useDayTo, month.AddDate(0, -1, 0))
+
+	required, _ := parseDecimal(account.RequiredSpending, totalDecimals)
+	report := CardBenefitReport{
+		Currency:         settings.BaseCurrency,
+		AccountID:        account.ID,
+		AccountName:      account.AccountName,
+		Month:            month.Format("2006-01"),
+		RepayDate:        repayDate.Format("2006-01-02"),
+		UseDateFrom:      useDateFrom.Format("2006-01-02"),
+		UseDateTo:        useDateTo.Format("2006-01-02"),
+		PreviousDateFrom: previousDateFrom.Format("2006-01-02"),
+		PreviousDateTo:   previousDateTo.Format("2006-01-02"),
+		RequiredSpending: Total(required),
+		Benefits:         []CardBenefitUsage{},
+	}
+
+	report.PreviousSpending, _, _, err = getCardSpending(account, previousDateFrom, previousDateTo, settings)
+	if err != nil {
+		return CardBenefitReport{}, err
+	}
+	report.Qualified = report.PreviousSpending >= report.RequiredSpending
+
+	var records []Record
+	report.Spending, report.ExcludedSpending, records, err = getCardSpending(account, useDateFrom, useDateTo, settings)
+	if err != nil {
+		return CardBenefitReport{}, err
+	}
+	report.RemainingSpending = max(report.RequiredSpending-report.Spending, 0)
+
+	for _, benefit := range account.Benefits {
+		report.Benefits = append(report.Benefits, CardBenefitUsage{Name: benefit.Name, Category: benefit.Category, Kind: benefit.Kind, Rate: benefit.Rate})
+	}
+	for _, record := range records {
+		if i := getCardBenefitIndex(account.Benefits, record.Category); i >= 0 {
+			report.Benefits[i].Spending += toBaseTotal(record.Amount, record.Currency, settings)
+		}
+	}
+
+	for i, benefit := range account.Benefits {
+		usage := &report.Benefits[i]
+		if report.Qualified {
+			usage.Earned = max(Total(math.Floor(float64(usage.Spending)*benefit.Rate/100)), 0)
+		}
+		if benefit.MonthlyCap != "" {
+			value, _ := parseDecimal(benefit.MonthlyCap, totalDecimals)
+			limit := Total(value)
+			usage.Earned = min(usage.Earned, limit)
+			remaining := limit - usage.Earned
+			usage.Cap, usage.Remaining = &limit, &remaining
+		}
+		report.Earned += usage.Earned
+	}
+
+	return report, nil
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+// A category benefit is chosen over a catch-all before it, the best rate of the same kind
+func TestGetCardBenefitIndex(t *testing.T) {
+	benefits := []CardBenefit{
+		{Name: "all", Rate: 1},
+		{Name: "cafe", Category: "cafe", Rate: 5},
+		{Name: "cafe plus", Category: "cafe", Rate: 10},
+		{Name: "all plus", Rate: 2},
+	}
+
+	for category, want := range map[string]int{"cafe": 2, "food": 3} {
+		if got := getCardBenefitIndex(benefits, category); got != want {
+			t.Fatalf("%s: got %d, want %d", category, got, want)
+		}
+	}
+	if got := getCardBenefitIndex(benefits[1:3], "food"); got != -1 {
+		t.Fatalf("without a benefit: got %d", got)
+	}
+}
+
+func TestCardBenefitReport(t *testing.T) {
+	tests := []struct {
+		name      string
+		previous  Money // Spending of the previous use period(전월실적)
+		qualified bool
+		earned    [3]Money
+	}{
+		{"under the required spending", 299999, false, [3]Money{0, 0, 0}},
+		{"on the required spending", 300000, true, [3]Money{1000, 5000, 0}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useTestLedger(t, newMemoryLedgerStore())
+
+			account := Account{
+				ID: "account:card", AccountName: "card", PayType: "credit", RepayDay: "14", UseDayFrom: "1", UseDayTo: "말일",
+				RequiredSpending: "300000", ExcludedCategories: []string{"tax"},
+				Benefits: []CardBenefit{
+					{Name: "all", Kind: "cashback", Rate: 1},
+					{Name: "cafe", Category: "cafe", Kind: "discount", Rate: 10, MonthlyCap: "5000"},
+					{Name: "books", Category: "books", Kind: "discount", Rate: 5},
+				},
+			}
+			if err := ledger.AddAccount(account); err != nil {
+				t.Fatal(err)
+			}
+			addTestRecords(t,
+				Record{Amount: test.previous, Date: "2026-02-27", AccountID: account.ID, PayType: "credit"},
+				Record{Amount: 50000, Date: "2026-02-28", AccountID: account.ID, PayType: "credit", Category: "tax"},
+				Record{Amount: 100000, Date: "2026-03-01", AccountID: account.ID, PayType: "credit"},
+				Record{Amount: 80000, Date: "2026-03-31", AccountID: account.ID, PayType: "credit", Category: "cafe"},
+				Record{Amount: 30000, Date: "2026-03-15", AccountID: account.ID, PayType: "credit", Category: "tax"},
+				Record{Amount: 70000, Date: "2026-04-01", AccountID: account.ID, PayType: "credit", Category: "cafe"},
+			)
+
+			report, err := getCardBenefitReport(account.ID, time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC), time.Time{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.UseDateFrom != "2026-03-01" || report.UseDateTo != "2026-03-31" || report.PreviousDateFrom != "2026-02-01" || report.PreviousDateTo != "2026-02-28" {
+				t.Fatalf("got periods %+v", report)
+			}
+			if report.PreviousSpending != toTotal(test.previous, "KRW") || report.Qualified != test.qualified {
+				t.Fatalf("got previous spending %v, qualified %v", report.PreviousSpending, report.Qualified)
+			}
+			if report.Spending != toTotal(180000, "KRW") || report.ExcludedSpending != toTotal(30000, "KRW") || report.RemainingSpending != toTotal(120000, "KRW") {
+				t.Fatalf("got spending %v, excluded %v, remaining %v", report.Spending, report.ExcludedSpending, report.RemainingSpending)
+			}
+
+			// The cafe spending is not given the benefit of all categories before it
+			spending := [3]Money{100000, 80000, 0}
+			var total Money
+			for i, usage := range report.Benefits {
+				if usage.Spending != toTotal(spending[i], "KRW") || usage.Earned != toTotal(test.earned[i], "KRW") {
+					t.Fatalf("benefit %s: got spending %v, earned %v", usage.Name, usage.Spending, usage.Earned)
+				}
+				total += test.earned[i]
+			}
+			if report.Earned != toTotal(total, "KRW") {
+				t.Fatalf("got earned %v", report.Earned)
+			}
+
+			// 10% of the cafe spending is over the cap
+			cafe := report.Benefits[1]
+			if cafe.Cap == nil || *cafe.Cap != toTotal(5000, "KRW") || *cafe.Remaining != toTotal(5000-test.earned[1], "KRW") {
+				t.Fatalf("got cafe cap %v, remaining %v", cafe.Cap, cafe.Remaining)
+			}
+			if report.Benefits[0].Cap != nil {
+				t.Fatalf("got a cap without monthly-cap")
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Items of the top lists of the dashboard
+const dashboardTopCount = 5
+
+// getDashboard gathers the home screen of today: this month against last month, the next repayments and the top spending
+func getDashboard(today time.Time) (Dashboard, error) {
+	settings, err := getSettings()
+	if err != nil {
+		return Dashboard{}, err
+	}
+
+	startDate, endDate := getFiscalMonth(settings, today)
+	previousStartDate, previousEndDate := getFiscalMonth(settings, startDate.AddDate(0, 0, -1))
+
+	current, err := getCompareTotals(startDate.Format("2006-01"), startDate, endDate, settings)
+	if err != nil {
+		return Dashboard{}, err
+	}
+	previous, err := getCompareTotals(previousStartDate.Format("2006-01"), previousStartDate, previousEndDate, settings)
+	if err != nil {
+		return Dashboard{}, err
+	}
+
+	dashboard := Dashboard{
+		Currency:         settings.BaseCurrency,
+		Current:          current.period,
+		Previous:         previous.period,
+		Spending:         current.period.SumPay + current.period.SumCreditPay,
+		PreviousSpending: previous.period.SumPay + previous.period.SumCreditPay,
+		Income:           current.period.SumIncome,
+	}
+	dashboard.SpendingChange = dashboard.Spending - dashboard.PreviousSpending
+	dashboard.SpendingPercent = getPercent(dashboard.SpendingChange, dashboard.PreviousSpending)
+	dashboard.Net = dashboard.Income - dashboard.Spending
+
+	dashboard.Repayments, err = getUpcomingRepayments(today, settings)
+	if err != nil {
+		return Dashboard{}, err
+	}
+
+	categories := map[string]*DashboardItem{}
+	merchants := map[string]*DashboardItem{}
+	for _, record := range current.records {
+		amount := toBaseTotal(record.Amount, record.Currency, settings)
+		addDashboardItem(categories, record.Category, amount)
+
+		// Merchants are the descriptions, case and spaces ignored
+		if merchant := strings.Join(strings.Fields(record.Description), " "); merchant != "" {
+			addDashboardItem(merchants, strings.ToLower(merchant), amount)
+		}
+	}
+	dashboard.TopCategories = getTopDashboardItems(categories)
+	dashboard.TopMerchants = getTopDashboardItems(merchants)
+
+	return dashboard, nil
+}
+
+func addDashboardItem(items map[string]*DashboardItem, key string, amount Total) {
+	item, exist := items[key]
+	if !exist {
+		item = &DashboardItem{Key: key}
+		items[key] = item
+	}
+	item.Amount += amount
+	item.Count++
+}
+
+func getTopDashboardItems(items map[string]*DashboardItem) []DashboardItem {
+	results := []DashboardItem{}
+	for _, item := range items {
+		results = append(results, *item)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Amount != results[j].Amount {
+			return results[i].Amount > results[j].Amount
+		}
+		return results[i].Key < results[j].Key
+	})
+	if len(results) > dashboardTopCount {
+		results = results[:dashboardTopCount]
+	}
+
+	return results
+}
+
+// getUpcomingRepayments returns the next repayment from today of each credit account, by getCreditDates
+func getUpcomingRepayments(today time.Time, settings Settings) ([]DashboardRepayment, error) {
+	accounts, err := getAccountListMAP()
+	if err != nil {
+		return nil, err
+	}
+
+	results := []DashboardRepayment{}
+	for _, account := range accounts {
+		repayDay, useDayFrom, useDayTo, ok := getCreditDays(account)
+		if account.PayType != "credit" || !ok {
+			continue
+		}
+
+		repayDate, useDateFrom, useDateTo := getCreditDates(repayDay, useDayFrom, useDayTo, today)
+		if repayDate.Before(today) {
+			repayDate, useDateFrom, useDateTo = getCreditDates(repayDay, useDayFrom, useDayTo, time.Date(today.Year(), today.Month()+1, 1, 0, 0, 0, 0, today.Location()))
+		}
+
+		records, err := ledger.ListRecords(account.ID, useDateFrom.Format("2006-01-02"), useDateTo.Format("2006-01-02"))
+		if err != nil {
+			return nil, err
+		}
+
+		repayment := DashboardRepayment{
+			AccountID:   account.ID,
+			AccountName: account.AccountName,
+			RepayDate:   repayDate.Format("2006-01-02"),
+			UseDateFrom: useDateFrom.Format("2006-01-02"),
+			UseDateTo:   useDateTo.Format("2006-01-02"),
+		}
+		for _, record := range records {
+			if record.TransactionType == "record_type_pay" && record.PayType == "credit" {
+				repayment.Amount += toBaseTotal(record.Amount, record.Currency, settings)
+				repayment.Count++
+			}
+		}
+		results = append(results, repayment)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].RepayDate != results[j].RepayDate {
+			return results[i].RepayDate < results[j].RepayDate
+		}
+		return results[i].AccountName < results[j].AccountName
+	})
+
+	return results, nil
+}
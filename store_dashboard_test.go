@@ -0,0 +1,84 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDashboard(t *testing.T) {
+	useTestLedger(t, newMemoryLedgerStore())
+
+	accounts := []Account{
+		{ID: "account:bank", AccountName: "bank", PayType: "direct"},
+		{ID: "account:card", AccountName: "card", PayType: "credit", RepayDay: "14", UseDayFrom: "1", UseDayTo: "말일", RepayAccountID: "account:bank"},
+	}
+	for _, account := range accounts {
+		if err := ledger.AddAccount(account); err != nil {
+			t.Fatal(err)
+		}
+	}
+	addTestRecords(t,
+		Record{Amount: 10000, Date: "2026-02-03", AccountID: "account:bank"},
+		Record{Amount: 12000, Date: "2026-02-10", AccountID: "account:card", PayType: "credit"},
+		Record{Amount: 15000, Date: "2026-03-02", AccountID: "account:bank", Description: "Star Bucks"},
+		Record{Amount: 5000, Date: "2026-03-05", AccountID: "account:bank", Category: "cafe", Description: " star  bucks "},
+		Record{Amount: 100000, Date: "2026-03-06", AccountID: "account:bank", Category: "salary", TransactionType: "record_type_income"},
+	)
+
+	dashboard, err := getDashboard(time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dashboard.Current.From != "2026-03-01" || dashboard.Previous.From != "2026-02-01" || dashboard.Previous.To != "2026-02-28" {
+		t.Fatalf("got periods %+v, %+v", dashboard.Current, dashboard.Previous)
+	}
+
+	// Paid and not yet repaid
+	if dashboard.Spending != toTotal(20000, "KRW") || dashboard.PreviousSpending != toTotal(22000, "KRW") || dashboard.SpendingChange != toTotal(-2000, "KRW") {
+		t.Fatalf("got spending %v, previous %v, change %v", dashboard.Spending, dashboard.PreviousSpending, dashboard.SpendingChange)
+	}
+	if dashboard.SpendingPercent == nil || *dashboard.SpendingPercent != -9.1 {
+		t.Fatalf("got spending percent %v", dashboard.SpendingPercent)
+	}
+	if dashboard.Income != toTotal(100000, "KRW") || dashboard.Net != toTotal(80000, "KRW") {
+		t.Fatalf("got income %v, net %v", dashboard.Income, dashboard.Net)
+	}
+
+	// Merchants ignore case and spaces, income is not an item
+	if len(dashboard.TopCategories) != 2 || dashboard.TopCategories[0].Key != "food" || dashboard.TopCategories[1].Key != "cafe" {
+		t.Fatalf("got top categories %+v", dashboard.TopCategories)
+	}
+	if len(dashboard.TopMerchants) != 1 || dashboard.TopMerchants[0] != (DashboardItem{Key: "star bucks", Amount: toTotal(20000, "KRW"), Count: 2}) {
+		t.Fatalf("got top merchants %+v", dashboard.TopMerchants)
+	}
+
+	// The 14th is a Saturday, the card of February is repaid on Monday
+	if len(dashboard.Repayments) != 1 {
+		t.Fatalf("got repayments %+v", dashboard.Repayments)
+	}
+	repayment := dashboard.Repayments[0]
+	if repayment.RepayDate != "2026-03-16" || repayment.UseDateFrom != "2026-02-01" || repayment.UseDateTo != "2026-02-28" || repayment.Amount != toTotal(12000, "KRW") || repayment.Count != 1 {
+		t.Fatalf("got repayment %+v", repayment)
+	}
+}
+
+// After the repay date of this month, the repayment of next month is shown
+func TestDashboardNextRepayment(t *testing.T) {
+	useTestLedger(t, newMemoryLedgerStore())
+
+	if err := ledger.AddAccount(Account{ID: "account:card", AccountName: "card", PayType: "credit", RepayDay: "14", UseDayFrom: "1", UseDayTo: "말일"}); err != nil {
+		t.Fatal(err)
+	}
+	addTestRecords(t, Record{Amount: 3000, Date: "2026-03-05", AccountID: "account:card", PayType: "credit"})
+
+	dashboard, err := getDashboard(time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dashboard.Repayments) != 1 || dashboard.Repayments[0].RepayDate != "2026-04-14" || dashboard.Repayments[0].Amount != toTotal(3000, "KRW") {
+		t.Fatalf("got repayments %+v", dashboard.Repayments)
+	}
+	if dashboard.SpendingPercent != nil {
+		t.Fatalf("got spending percent %v without spending last month", *dashboard.SpendingPercent)
+	}
+}
@@ -0,0 +1,299 @@
+package server
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Months of the average variable spending, before this month
+const forecastLookbackMonths = 3
+
+const maxForecastMonths = 24
+
+// Months from a card charge to its repayment at most, as searched by getRepayDate
+const forecastRepayMonths = 3
+
+// getRepayDate returns the repay date of a charge on the credit account at date, by getCreditDates
+func getRepayDate(account Account, date time.Time) (time.Time, bool) {
+	repayDay, useDayFrom, useDayTo, ok := getCreditDays(account)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	// The use period ends before the repay date, within a few months
+	for i := 0; i <= forecastRepayMonths; i++ {
+		repayDate, useDateFrom, useDateTo := getCreditDates(repayDay, useDayFrom, useDayTo, time.Date(date.Year(), date.Month()+time.Month(i), 1, 0, 0, 0, 0, date.Location()))
+		if !date.Before(useDateFrom) && !date.After(useDateTo) {
+			return repayDate, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// getForecastAmount returns the cash flow of a record: negative for spending, and whether it is charged on a card.
+// Records not moving cash are not ok.
+func getForecastAmount(transactionType, payType string, money Money, currency string, settings Settings) (Total, bool, bool) {
+	// Totals are in the base currency
+	amount := toBaseTotal(money, currency, settings)
+	switch {
+	case transactionType == "record_type_pay" && (payType == "direct" || payType == "credit"):
+		return -amount, payType == "credit", true
+	case transactionType == "record_type_income":
+		return amount, false, true
+	}
+
+	return 0, false, false
+}
+
+// Cash flows of a forecast by account and date
+type forecastFlows struct {
+	accounts map[string]Account
+	today    time.Time
+	balances map[string]Total
+	flows    map[string]map[string]Total
+	repays   map[string]map[string]Total // Credit account, repay date
+	unrepaid map[string]bool             // Credit accounts without a direct repay account
+}
+
+// cashAccount is the direct account of accountID, or "" for a missing or credit account
+func (f *forecastFlows) cashAccount(accountID string) string {
+	if account, exist := f.accounts[accountID]; exist && account.PayType != "credit" {
+		return accountID
+	}
+	return ""
+}
+
+// add moves amount of accountID at date. Charges on a credit account move at the repay date from its repay account.
+// Charges on a card without a direct repay account are left out, the card is reported as unrepaid.
+func (f *forecastFlows) add(accountID string, credit bool, date time.Time, amount Total) {
+	cashAccountID := f.cashAccount(accountID)
+	if credit {
+		card := f.accounts[accountID]
+		cashAccountID = f.cashAccount(card.RepayAccountID)
+		if cashAccountID == "" {
+			f.unrepaid[accountID] = true
+			return
+		}
+
+		if repayDate, ok := getRepayDate(card, date); ok {
+			date = repayDate
+			if date.After(f.today) {
+				if f.repays[accountID] == nil {
+					f.repays[accountID] = map[string]Total{}
+				}
+				f.repays[accountID][date.Format("2006-01-02")] += amount
+			}
+		}
+	}
+
+	if !date.After(f.today) {
+		f.balances[cashAccountID] += amount
+		return
+	}
+	if f.flows[cashAccountID] == nil {
+		f.flows[cashAccountID] = map[string]Total{}
+	}
+	f.flows[cashAccountID][date.Format("2006-01-02")] += amount
+}
+
+// getForecast projects the balance of the direct accounts for months after today.
+// Unpaid card charges move at their repay dates, with the future records, the recurring items
+// and the average spending of the last months by account and category.
+func getForecast(today time.Time, months int) (Forecast, error) {
+	settings, err := getSettings()
+	if err != nil {
+		return Forecast{}, err
+	}
+	accounts, err := getAccountListMAP()
+	if err != nil {
+		return Forecast{}, err
+	}
+	recurringItems, err := getRecurringItems()
+	if err != nil {
+		return Forecast{}, err
+	}
+
+	startDate := today.AddDate(0, 0, 1)
+	endDate := today.AddDate(0, months, 0)
+
+	forecast := Forecast{
+		Currency:      settings.BaseCurrency,
+		From:          startDate.Format("2006-01-02"),
+		To:            endDate.Format("2006-01-02"),
+		Accounts:      []ForecastAccount{},
+		Events:        []ForecastEvent{},
+		Variable:      []ForecastVariable{},
+		NegativeDates: []string{},
+		UnrepaidCards: []string{},
+	}
+	f := &forecastFlows{accounts: accounts, today: today, balances: map[string]Total{}, flows: map[string]map[string]Total{}, repays: map[string]map[string]Total{}, unrepaid: map[string]bool{}}
+
+	for _, account := range accounts {
+		if account.PayType != "credit" {
+			balance, _ := parseDecimal(account.OpeningBalance, totalDecimals)
+			f.balances[account.ID] = Total(balance)
+		}
+	}
+
+	// Only the records of the window are loaded: the lookback months and the card charges not yet repaid.
+	// Earlier records are summed into the balances, their charges were repaid before today.
+	thisMonth, _ := getFiscalMonth(settings, today)
+	lookbackStart := thisMonth.AddDate(0, -forecastLookbackMonths, 0)
+	lookbackEnd := thisMonth.AddDate(0, 0, -1)
+	windowStart := time.Date(today.Year(), today.Month()-forecastRepayMonths, 1, 0, 0, 0, 0, today.Location())
+	if lookbackStart.Before(windowStart) {
+		windowStart = lookbackStart
+	}
+
+	sums, err := ledger.SumRecords(windowStart.AddDate(0, 0, -1).Format("2006-01-02"))
+	if err != nil {
+		return Forecast{}, err
+	}
+	for _, sum := range sums {
+		if amount, credit, ok := getForecastAmount(sum.TransactionType, sum.PayType, sum.Amount, sum.Currency, settings); ok {
+			f.add(sum.AccountID, credit, windowStart.AddDate(0, 0, -1), amount)
+		}
+	}
+
+	// Records until today make the balance. Later records are scheduled.
+	records, err := ledger.ListRecords("", windowStart.Format("2006-01-02"), endDate.Format("2006-01-02"))
+	if err != nil {
+		return Forecast{}, err
+	}
+	for _, record := range records {
+		date, err := time.Parse("2006-01-02", record.Date)
+		if err != nil {
+			continue
+		}
+
+		amount, credit, ok := getForecastAmount(record.TransactionType, record.PayType, record.Amount, record.Currency, settings)
+		if !ok {
+			continue
+		}
+
+		f.add(record.AccountID, credit, date, amount)
+		if date.After(today) && !credit {
+			forecast.Events = append(forecast.Events, ForecastEvent{Date: record.Date, AccountID: f.cashAccount(record.AccountID), Kind: "scheduled", Description: strings.TrimSpace(record.Category + " " + record.Description), Amount: amount})
+		}
+	}
+
+	for _, item := range recurringItems {
+		money, _ := parseMoney(item.Amount, item.Currency)
+		amount := toBaseTotal(money, item.Currency, settings)
+		if item.TransactionType == "record_type_pay" {
+			amount = -amount
+		}
+		credit := item.TransactionType == "record_type_pay" && accounts[item.AccountID].PayType == "credit"
+
+		for _, date := range getRecurringDates(item, startDate, endDate) {
+			f.add(item.AccountID, credit, date, amount)
+			if !credit {
+				forecast.Events = append(forecast.Events, ForecastEvent{Date: date.Format("2006-01-02"), AccountID: f.cashAccount(item.AccountID), Kind: "recurring", Description: item.Name, Amount: amount})
+			}
+		}
+	}
+
+	// Average spending of the last months by account and category, without the recurring items in them
+	lookbackDays := int64(thisMonth.Sub(lookbackStart).Hours() / 24)
+
+	variable := map[[2]string]Total{}
+	for _, record := range records {
+		if record.Date < lookbackStart.Format("2006-01-02") || record.Date > lookbackEnd.Format("2006-01-02") {
+			continue
+		}
+		if record.TransactionType == "record_type_pay" && (record.PayType == "direct" || record.PayType == "credit") {
+			variable[[2]string{record.AccountID, record.Category}] += toBaseTotal(record.Amount, record.Currency, settings)
+		}
+	}
+	for _, item := range recurringItems {
+		key := [2]string{item.AccountID, item.Category}
+		if _, exist := variable[key]; !exist || item.TransactionType != "record_type_pay" {
+			continue
+		}
+		money, _ := parseMoney(item.Amount, item.Currency)
+		variable[key] -= toBaseTotal(money, item.Currency, settings) * Total(len(getRecurringDates(item, lookbackStart, lookbackEnd)))
+	}
+	for key, total := range variable {
+		if total <= 0 {
+			continue
+		}
+		forecast.Variable = append(forecast.Variable, ForecastVariable{AccountID: key[0], Category: key[1], Monthly: total / forecastLookbackMonths})
+
+		daily := -total / Total(lookbackDays)
+		for date := startDate; !date.After(endDate); date = date.AddDate(0, 0, 1) {
+			f.add(key[0], accounts[key[0]].PayType == "credit", date, daily)
+		}
+	}
+	sort.Slice(forecast.Variable, func(i, j int) bool {
+		if forecast.Variable[i].Monthly != forecast.Variable[j].Monthly {
+			return forecast.Variable[i].Monthly > forecast.Variable[j].Monthly
+		}
+		return forecast.Variable[i].AccountID+forecast.Variable[i].Category < forecast.Variable[j].AccountID+forecast.Variable[j].Category
+	})
+
+	for cardID, repays := range f.repays {
+		for date, amount := range repays {
+			if date > forecast.To {
+				continue
+			}
+			forecast.Events = append(forecast.Events, ForecastEvent{Date: date, AccountID: f.cashAccount(accounts[cardID].RepayAccountID), Kind: "credit-repay", Description: accounts[cardID].AccountName, Amount: amount})
+		}
+	}
+	sort.SliceStable(forecast.Events, func(i, j int) bool {
+		if forecast.Events[i].Date != forecast.Events[j].Date {
+			return forecast.Events[i].Date < forecast.Events[j].Date
+		}
+		return forecast.Events[i].Kind+forecast.Events[i].Description < forecast.Events[j].Kind+forecast.Events[j].Description
+	})
+
+	// Day by day balances of the direct accounts. Records without a direct account are shown when they move cash.
+	accountIDs := []string{}
+	for accountID := range f.balances {
+		if accountID != "" || f.balances[""] != 0 || len(f.flows[""]) > 0 {
+			accountIDs = append(accountIDs, accountID)
+		}
+	}
+	for accountID := range f.flows {
+		if _, exist := f.balances[accountID]; !exist {
+			accountIDs = append(accountIDs, accountID)
+		}
+	}
+	sort.Strings(accountIDs)
+
+	negativeDates := map[string]bool{}
+	for _, accountID := range accountIDs {
+		account := ForecastAccount{AccountID: accountID, AccountName: accounts[accountID].AccountName, Balance: f.balances[accountID], NegativeDates: []string{}, Days: []ForecastDay{}}
+		account.MinBalance, account.MinDate = account.Balance, today.Format("2006-01-02")
+
+		balance := account.Balance
+		for date := startDate; !date.After(endDate); date = date.AddDate(0, 0, 1) {
+			day := date.Format("2006-01-02")
+			change := f.flows[accountID][day]
+			balance += change
+			account.Days = append(account.Days, ForecastDay{Date: day, Change: change, Balance: balance})
+
+			if balance < account.MinBalance {
+				account.MinBalance, account.MinDate = balance, day
+			}
+			if balance < 0 {
+				account.NegativeDates = append(account.NegativeDates, day)
+				negativeDates[day] = true
+			}
+		}
+		forecast.Accounts = append(forecast.Accounts, account)
+	}
+
+	for day := range negativeDates {
+		forecast.NegativeDates = append(forecast.NegativeDates, day)
+	}
+	sort.Strings(forecast.NegativeDates)
+
+	for cardID := range f.unrepaid {
+		forecast.UnrepaidCards = append(forecast.UnrepaidCards, cardID)
+	}
+	sort.Strings(forecast.UnrepaidCards)
+
+	return forecast, nil
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// Charges on a card without a repay account are not in any balance, the card is reported
+func TestForecastUnrepaidCards(t *testing.T) {
+	useTestLedger(t, newMemoryLedgerStore())
+
+	accounts := []Account{
+		{ID: "account:bank", AccountName: "bank", PayType: "direct", OpeningBalance: "100000"},
+		{ID: "account:card", AccountName: "card", PayType: "credit", RepayDay: "14", UseDayFrom: "1", UseDayTo: "31", RepayAccountID: "account:bank"},
+		{ID: "account:orphan", AccountName: "orphan", PayType: "credit", RepayDay: "14", UseDayFrom: "1", UseDayTo: "31"},
+	}
+	for _, account := range accounts {
+		if err := ledger.AddAccount(account); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, accountID := range []string{"account:card", "account:orphan"} {
+		record := Record{ID: "record:" + accountID, TransactionType: "record_type_pay", AccountID: accountID, PayType: "credit", Currency: "KRW", Amount: Money(10000 * (i + 1)), Category: "food", Date: "2026-03-05"}
+		if err := ledger.AddRecord(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	forecast, err := getForecast(time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(forecast.UnrepaidCards, []string{"account:orphan"}) {
+		t.Fatalf("unrepaid cards: got %v", forecast.UnrepaidCards)
+	}
+	if len(forecast.Accounts) != 1 || forecast.Accounts[0].AccountID != "account:bank" {
+		t.Fatalf("accounts: got %+v", forecast.Accounts)
+	}
+	days := forecast.Accounts[0].Days
+	if balance := days[len(days)-1].Balance; balance != toTotal(90000, "KRW") {
+		t.Fatalf("bank balance: got %v", balance)
+	}
+}
+
+// Records before the window are summed into the balance today, their card charges already repaid
+func TestForecastBalanceBeforeWindow(t *testing.T) {
+	useTestLedger(t, newMemoryLedgerStore())
+
+	accounts := []Account{
+		{ID: "account:bank", AccountName: "bank", PayType: "direct", OpeningBalance: "100000"},
+		{ID: "account:card", AccountName: "card", PayType: "credit", RepayDay: "14", UseDayFrom: "1", UseDayTo: "말일", RepayAccountID: "account:bank"},
+	}
+	for _, account := range accounts {
+		if err := ledger.AddAccount(account); err != nil {
+			t.Fatal(err)
+		}
+	}
+	addTestRecords(t,
+		Record{Amount: 50000, Date: "2024-12-01", AccountID: "account:bank", Category: "salary", TransactionType: "record_type_income"},
+		Record{Amount: 10000, Date: "2025-01-05", AccountID: "account:bank"},
+		Record{Amount: 20000, Date: "2025-06-05", AccountID: "account:card", PayType: "credit"},
+		Record{Amount: 5000, Date: "2026-02-05", AccountID: "account:card", PayType: "credit"},
+		Record{Amount: 1000, Date: "2026-04-01", AccountID: "account:bank", Category: "rent"},
+	)
+
+	forecast, err := getForecast(time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(forecast.Accounts) != 1 || forecast.Accounts[0].Balance != toTotal(120000, "KRW") {
+		t.Fatalf("accounts: got %+v", forecast.Accounts)
+	}
+
+	// The charge of February is repaid on Monday the 16th, after today
+	events := []string{}
+	for _, event := range forecast.Events {
+		events = append(events, event.Date+" "+event.Kind+" "+formatDecimal(int64(event.Amount), totalDecimals))
+	}
+	if !slices.Equal(events, []string{"2026-03-16 credit-repay -5000.00", "2026-04-01 scheduled -1000.00"}) {
+		t.Fatalf("events: got %v", events)
+	}
+}
@@ -1,161 +1,432 @@
 package server
 
 import (
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/index/scorch"
+	"github.com/blevesearch/bleve/v2/index/upsidedown"
 	"github.com/dgraph-io/badger/v3"
 )
 
-func initBadgerDB(password string) error {
-	var err error
-
-	saltFile := "salt"
-	salt, err := getSalt(saltFile)
-	if err != nil {
-		return fmt.Errorf("failed to get salt: %w", err)
-	}
-
-	key := generateKey(password, salt)
-
-	opts := badger.DefaultOptions("./badger_data")
+func badgerOptions(dir string, key []byte) badger.Options {
+	opts := badger.DefaultOptions(dir)
 	// opts.EncryptionKey = []byte("0123456789abcdefghijklmn") // 16 or 24 or 32 byte
 	opts.EncryptionKey = key
-	opts.IndexCacheSize = 100 << 20          // 100 MB
+	opts.BlockCacheSize = badgerBlockCacheSize
+	opts.IndexCacheSize = badgerIndexCacheSize
 	opts.ValueLogFileSize = 64 * 1024 * 1024 // 64MB
 	opts.ValueLogMaxEntries = 1000000
 	opts.Logger = nil
 
-	db, err = badger.Open(opts)
+	return opts
+}
+
+// initBadgerDB unlocks the master key with the password and opens the ledger store.
+// recoveryKey is returned only when it is newly generated - first setup or migration from the legacy key.
+func initBadgerDB(password string) (recoveryKey string, err error) {
+	keySlots, exist, err := loadKeySlots(keySlotsFile)
 	if err != nil {
-		db = nil
+		return "", fmt.Errorf("failed to get key slots: %w", err)
+	}
+	if !exist {
+		return setupKeySlots(password)
 	}
 
-	return err
-}
+	masterKey, slotIDX, err := keySlots.unlock(password)
+	if err != nil {
+		return "", err
+	}
 
-func initBleveIndex() error {
-	indexPath := "record_index.bleve"
-	if _, err := os.Stat(indexPath); os.IsNotExist(err) {
-		mapping := bleve.NewIndexMapping()
-		var err error
-		bleveIndex, err = bleve.New(indexPath, mapping)
-		if err != nil {
-			return err
+	err = openLedgerDB(masterKey)
+	if err != nil {
+		return "", err
+	}
+
+	// Weak or old KDF parameters - rewrap this slot only, the master key stays
+	if keySlots.Slots[slotIDX].KDF.isLegacy() {
+		err = keySlots.Slots[slotIDX].rewrap(password, masterKey)
+		if err == nil {
+			err = writeKeySlots(keySlotsFile, keySlots)
 		}
-	} else {
-		bleveIndex, err = bleve.Open(indexPath)
 		if err != nil {
-			return err
+			fmt.Println("KDF upgrade skipped:", err)
 		}
 	}
 
-	if db == nil {
-		bleveIndex = nil
-		return errors.New("db is not set")
-	}
+	return "", nil
+}
 
-	return db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		it := txn.NewIterator(opts)
-		defer it.Close()
+// setupKeySlots creates key slots for a new database, or migrates a database encrypted by a password derived key
+func setupKeySlots(password string) (string, error) {
+	pendingFile := keySlotsFile + ".pending"
 
-		for it.Seek([]byte("record:")); it.ValidForPrefix([]byte("record:")); it.Next() {
-			item := it.Item()
-			err := item.Value(func(v []byte) error {
-				var record Record
-				if err := json.Unmarshal(v, &record); err != nil {
-					return err
+	// Interrupted migration - key registry may be already rotated to the pending master key
+	keySlots, exist, err := loadKeySlots(pendingFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to get pending key slots: %w", err)
+	}
+	if exist {
+		if masterKey, _, err := keySlots.unlock(password); err == nil {
+			if err = openLedgerDB(masterKey); err == nil {
+				// Closed not to report a failed unlock with the ledger open. Recovered again on the next unlock.
+				if err := os.Rename(pendingFile, keySlotsFile); err != nil {
+					closeBleveIndex()
+					closeLedgerStore()
+					return "", fmt.Errorf("failed to save key slots: %w", err)
 				}
-				return bleveIndex.Index(string(item.Key()), record)
-			})
-			if err != nil {
-				return err
+				removeLegacyKeyFiles()
+				return "", nil
 			}
 		}
+	}
 
-		return nil
-	})
+	header, exist, err := loadKDFHeader(kdfHeaderFile, legacySaltFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to get kdf header: %w", err)
+	}
+	if !exist {
+		if _, err := os.Stat(filepath.Join(badgerPath, badger.KeyRegistryFileName)); err == nil {
+			return "", fmt.Errorf("key slots file is missing: %s", keySlotsFile)
+		}
+	}
+
+	masterKey, err := newMasterKey()
+	if err != nil {
+		return "", err
+	}
+	passwordSlot, err := newKeySlot(keySlotTypePassword, password, masterKey)
+	if err != nil {
+		return "", err
+	}
+	recoveryKey, err := newRecoveryKey()
+	if err != nil {
+		return "", err
+	}
+	recoverySlot, err := newKeySlot(keySlotTypeRecovery, recoveryKey, masterKey)
+	if err != nil {
+		return "", err
+	}
+	keySlots = KeySlots{Slots: []KeySlot{passwordSlot, recoverySlot}}
+
+	if exist {
+		oldKey, err := header.deriveKey(password)
+		if err != nil {
+			return "", err
+		}
+
+		// Check the password before touching the key registry
+		oldDB, err := badger.Open(badgerOptions(badgerPath, oldKey))
+		if err != nil {
+			return "", err
+		}
+		oldDB.Close()
+
+		if err := writeKeySlots(pendingFile, keySlots); err != nil {
+			return "", err
+		}
+		if err := rotateBadgerKey(badgerPath, oldKey, masterKey); err != nil {
+			os.Remove(pendingFile)
+			return "", err
+		}
+		if err := os.Rename(pendingFile, keySlotsFile); err != nil {
+			return "", err
+		}
+		removeLegacyKeyFiles()
+	} else {
+		if err := writeKeySlots(keySlotsFile, keySlots); err != nil {
+			return "", err
+		}
+	}
+
+	err = openLedgerDB(masterKey)
+	if err != nil {
+		return "", err
+	}
+
+	return recoveryKey, nil
 }
 
-func changePassword(oldPassword, newPassword string) error {
-	oldSalt, err := getSalt("salt")
+func removeLegacyKeyFiles() {
+	os.Remove(kdfHeaderFile)
+	os.Remove(legacySaltFile)
+}
+
+func openLedgerDB(masterKey []byte) error {
+	var err error
+
+	invalidateAccountCache()
+	invalidateHolidayCache()
+
+	ledger, err = openLedgerStore(masterKey)
 	if err != nil {
-		return fmt.Errorf("failed to get old salt: %w", err)
+		ledger = nil
+		return err
 	}
-	oldKey := generateKey(oldPassword, oldSalt)
 
-	// oldOpts := badger.DefaultOptions("./badger_data").WithEncryptionKey(oldKey).WithIndexCacheSize(100 << 20)
-	oldOpts := badger.DefaultOptions("./badger_data")
-	oldOpts.EncryptionKey = oldKey
-	oldOpts.IndexCacheSize = 100 << 20          // 100 MB
-	oldOpts.ValueLogFileSize = 64 * 1024 * 1024 // 64MB
-	oldOpts.ValueLogMaxEntries = 1000000
-	oldOpts.Logger = nil
-	oldDB, err := badger.Open(oldOpts)
+	indexEncryptionKey, err = deriveSubKey(masterKey, "bleve-index")
+	if err == nil {
+		_, err = migrateLedger()
+	}
 	if err != nil {
-		return fmt.Errorf("failed to open old DB: %w", err)
+		closeLedgerStore()
 	}
-	defer oldDB.Close()
 
-	if err := os.Remove("salt"); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to remove old salt file: %w", err)
+	return err
+}
+
+func initBleveIndex() error {
+	var err error
+
+	if ledger == nil {
+		bleveIndex = nil
+		return errors.New("ledger is not set")
 	}
 
-	newSalt, err := getSalt("salt")
+	switch IndexMode {
+	case indexModeMemory:
+		// Nothing of the index may stay on the disk
+		if err = os.RemoveAll(bleveIndexPath); err != nil {
+			return err
+		}
+		bleveIndex, err = bleve.NewUsing("", bleve.NewIndexMapping(), scorch.Name, scorch.Name, nil)
+	case indexModeEncrypted:
+		bleveIndex, err = openEncryptedBleveIndex()
+	default:
+		err = fmt.Errorf("unknown index mode: %s", IndexMode)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to generate new salt: %w", err)
+		bleveIndex = nil
+		return err
+	}
+
+	// Only stores tracking changes can be synced incrementally
+	tracker, ok := ledger.(ChangeTracker)
+	if !ok {
+		return rebuildBleveIndex()
 	}
 
-	newKey := generateKey(newPassword, newSalt)
-	// newOpts := badger.DefaultOptions("./new_badger_data").WithEncryptionKey(newKey).WithIndexCacheSize(100 << 20)
-	newOpts := badger.DefaultOptions("./new_badger_data")
-	newOpts.EncryptionKey = newKey
-	newOpts.IndexCacheSize = 100 << 20          // 100 MB
-	newOpts.ValueLogFileSize = 64 * 1024 * 1024 // 64MB
-	newOpts.ValueLogMaxEntries = 1000000
-	newOpts.Logger = nil
-	newDB, err := badger.Open(newOpts)
+	indexedVersion, err := getIndexedVersion()
 	if err != nil {
-		return fmt.Errorf("failed to create new DB: %w", err)
+		return err
+	}
+
+	// Index from another database or newer than the database - not usable
+	if indexedVersion > tracker.Version() {
+		indexedVersion = 0
 	}
-	defer newDB.Close()
 
-	err = oldDB.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		it := txn.NewIterator(opts)
-		defer it.Close()
+	if indexedVersion > 0 {
+		err = updateBleveIndex(tracker, indexedVersion)
+		if err == nil {
+			return nil
+		}
+		fmt.Println("Rebuild search index:", err)
+	}
 
-		for it.Rewind(); it.Valid(); it.Next() {
-			item := it.Item()
-			err := item.Value(func(val []byte) error {
-				return newDB.Update(func(txn *badger.Txn) error {
-					return txn.Set(item.Key(), val)
-				})
-			})
-			if err != nil {
+	return rebuildBleveIndex()
+}
+
+// getIndexedVersion returns the ledger version which the index is synced with. 0 for an empty index.
+func getIndexedVersion() (uint64, error) {
+	value, err := bleveIndex.GetInternal(indexedVersionKey)
+	if err != nil || len(value) != 8 {
+		return 0, err
+	}
+
+	return binary.BigEndian.Uint64(value), nil
+}
+
+func setIndexedVersion(version uint64) error {
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, version)
+
+	return bleveIndex.SetInternal(indexedVersionKey, value)
+}
+
+// rebuildBleveIndex indexes every record again with batches
+func rebuildBleveIndex() error {
+	var err error
+
+	// Deleted records should not remain
+	docCount, _ := bleveIndex.DocCount()
+	if IndexMode == indexModeEncrypted && docCount > 0 {
+		bleveIndex.Close()
+		if err = os.RemoveAll(bleveIndexPath); err != nil {
+			return err
+		}
+		bleveIndex, err = openEncryptedBleveIndex()
+		if err != nil {
+			bleveIndex = nil
+			return err
+		}
+	}
+
+	version := uint64(0)
+	if tracker, ok := ledger.(ChangeTracker); ok {
+		version = tracker.Version()
+	}
+
+	// Accounts and categories are indexed by the live writes too
+	accounts, err := ledger.ListAccounts()
+	if err != nil {
+		return err
+	}
+	categories, err := ledger.ListCategories()
+	if err != nil {
+		return err
+	}
+	records, err := ledger.ListRecords("", "", "")
+	if err != nil {
+		return err
+	}
+
+	entities := map[string]interface{}{}
+	for _, account := range accounts {
+		entities[account.ID] = account
+	}
+	for _, category := range categories {
+		entities[category.ID] = category
+	}
+	for _, record := range records {
+		entities[record.ID] = record
+	}
+
+	batch := bleveIndex.NewBatch()
+	for id, entity := range entities {
+		if err := batch.Index(id, entity); err != nil {
+			return err
+		}
+
+		if batch.Size() >= indexBatchSize {
+			if err := bleveIndex.Batch(batch); err != nil {
 				return err
 			}
+			batch.Reset()
+		}
+	}
+	if err := bleveIndex.Batch(batch); err != nil {
+		return err
+	}
+
+	return setIndexedVersion(version)
+}
+
+// updateBleveIndex applies only the entities changed after sinceVersion.
+// The version is kept only when every change is indexed, the rest is tried again from sinceVersion.
+func updateBleveIndex(tracker ChangeTracker, sinceVersion uint64) error {
+	version := tracker.Version()
+	batch := bleveIndex.NewBatch()
+
+	err := tracker.EntityChangesSince(sinceVersion, func(id string, entity interface{}) error {
+		if entity == nil {
+			batch.Delete(id)
+		} else if err := batch.Index(id, entity); err != nil {
+			return err
+		}
+
+		if batch.Size() >= indexBatchSize {
+			if err := bleveIndex.Batch(batch); err != nil {
+				return err
+			}
+			batch.Reset()
 		}
 		return nil
 	})
 	if err != nil {
-		return fmt.Errorf("failed to copy data: %w", err)
+		return err
+	}
+	if err := bleveIndex.Batch(batch); err != nil {
+		return err
+	}
+
+	if err := setIndexedVersion(version); err != nil {
+		return err
+	}
+	return tracker.PruneDeletions(version)
+}
+
+// closeBleveIndex syncs the changes since the indexed version - live index writes may have failed,
+// or the ledger be written without the index - then closes the index
+func closeBleveIndex() {
+	if bleveIndex == nil {
+		return
+	}
+
+	if tracker, ok := ledger.(ChangeTracker); ok {
+		indexedVersion, err := getIndexedVersion()
+		if err == nil && indexedVersion > 0 {
+			err = updateBleveIndex(tracker, indexedVersion)
+		}
+		if err != nil {
+			fmt.Println("Search index sync skipped:", err)
+		}
+	}
+	bleveIndex.Close()
+	bleveIndex = nil
+}
+
+func openEncryptedBleveIndex() (bleve.Index, error) {
+	// Plaintext index of the older versions or broken one - remove and build again
+	var meta struct {
+		Storage string `json:"storage"`
+	}
+	data, err := os.ReadFile(filepath.Join(bleveIndexPath, "index_meta.json"))
+	if err == nil {
+		err = json.Unmarshal(data, &meta)
+	}
+	if err == nil && meta.Storage == encryptedKVStoreName {
+		index, err := bleve.OpenUsing(bleveIndexPath, nil)
+		if err == nil {
+			return index, nil
+		}
+		fmt.Println("Rebuild search index:", err)
 	}
 
-	oldDB.Close()
-	oldDB = nil
-	newDB.Close()
-	newDB = nil
-	if err := os.RemoveAll("./badger_data"); err != nil {
-		return fmt.Errorf("failed to remove old DB: %w", err)
+	if err := os.RemoveAll(bleveIndexPath); err != nil {
+		return nil, err
 	}
-	if err := os.Rename("./new_badger_data", "./badger_data"); err != nil {
-		return fmt.Errorf("failed to rename new DB: %w", err)
+
+	return bleve.NewUsing(bleveIndexPath, bleve.NewIndexMapping(), upsidedown.Name, encryptedKVStoreName, nil)
+}
+
+// changePassword rewraps the master key. The database itself is not touched.
+// With the recovery key as oldPassword, a new password slot is added instead.
+func changePassword(oldPassword, newPassword string) error {
+	if newPassword == "" {
+		return newValidationError("new-password", "new-password is required")
+	}
+
+	keySlots, exist, err := loadKeySlots(keySlotsFile)
+	if err != nil {
+		return fmt.Errorf("failed to get key slots: %w", err)
+	}
+	if !exist {
+		return errLedgerLocked
+	}
+
+	masterKey, slotIDX, err := keySlots.unlock(oldPassword)
+	if err != nil {
+		return err
+	}
+
+	if keySlots.Slots[slotIDX].Type == keySlotTypePassword {
+		err = keySlots.Slots[slotIDX].rewrap(newPassword, masterKey)
+		if err != nil {
+			return err
+		}
+	} else {
+		slot, err := newKeySlot(keySlotTypePassword, newPassword, masterKey)
+		if err != nil {
+			return err
+		}
+		keySlots.Slots = append(keySlots.Slots, slot)
 	}
 
-	return nil
+	return writeKeySlots(keySlotsFile, keySlots)
 }
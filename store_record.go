@@ -1,48 +1,50 @@
 package server
 
 import (
-	"encoding/json"
 	"fmt"
-	"strconv"
 	"time"
 
 	"github.com/blevesearch/bleve/v2"
-	"github.com/dgraph-io/badger/v3"
 )
 
-func addRecord(record Record) error {
+// addRecord returns the ID of the new record
+func addRecord(record Record) (string, error) {
 	var err error
 
+	settings, err := getSettings()
+	if err != nil {
+		return "", err
+	}
+	rules, err := loadRecordRules()
+	if err != nil {
+		return "", err
+	}
+	applyRecordDefaults(&record, settings, rules)
+
 	err = validateRecord(record)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	now := time.Now()
-	timestamp := now.Unix()
-	id := fmt.Sprintf("record:%d", timestamp)
+	id := newEntityID("record")
 	record.ID = id
 	regdttm := now.Format("20060102150405")
 	record.RegDTTM = regdttm
 
-	err = db.Update(func(txn *badger.Txn) error {
-		value, _ := json.Marshal(record)
-		return txn.Set([]byte(id), value)
-	})
+	err = ledger.AddRecord(record)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	return bleveIndex.Index(id, record)
+	return id, bleveIndex.Index(id, record)
 }
 
 func deleteRecord(id string) error {
 	var err error
 
-	// Remove Badger record
-	err = db.Update(func(txn *badger.Txn) error {
-		return txn.Delete([]byte(id))
-	})
+	// Remove stored record
+	err = ledger.DeleteRecord(id)
 	if err != nil {
 		return fmt.Errorf("failed to delete record: %w", err)
 	}
@@ -56,170 +58,191 @@ func deleteRecord(id string) error {
 	return nil
 }
 
+func getRecord(id string) (Record, error) {
+	return ledger.GetRecord(id)
+}
+
 func updateRecord(id string, updatedRecord Record) error {
 	var err error
 
-	var existingRecord Record
-	err = db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(id))
-		if err != nil {
-			return err
-		}
-		return item.Value(func(v []byte) error {
-			return json.Unmarshal(v, &existingRecord)
-		})
-	})
+	existingRecord, err := ledger.GetRecord(id)
 	if err != nil {
 		return err
 	}
 
-	err = db.Update(func(txn *badger.Txn) error {
-		updatedRecord.RegDTTM = existingRecord.RegDTTM
-		updatedRecord.ID = existingRecord.ID
-		value, _ := json.Marshal(updatedRecord)
-		err = txn.Set([]byte(id), value)
-		if err != nil {
-			return err
-		}
-
-		// Update Bleve index
-		return bleveIndex.Index(id, updatedRecord)
-	})
+	updatedRecord.RegDTTM = existingRecord.RegDTTM
+	updatedRecord.ID = existingRecord.ID
+	err = ledger.UpdateRecord(updatedRecord)
+	if err != nil {
+		return err
+	}
 
-	return err
+	// Update Bleve index
+	return bleveIndex.Index(id, updatedRecord)
 }
 
-func getRecords(queries []string, queryType string, startDate, endDate time.Time) ([]Record, map[string]Stat, map[string]Stat, float64, float64, float64, error) {
+// getRecords lists records between startDate and endDate with stats of pay, credit not repaid and income by category.
+// Without queries, the date listing of the ledger store is used instead of the search index.
+func getRecords(queries []string, queryType string, accountID string, startDate, endDate time.Time) ([]Record, map[string]Stat, map[string]Stat, map[string]Stat, Total, Total, Total, error) {
 	var results []Record = []Record{}
 	var stat map[string]Stat = map[string]Stat{}
 	var statCredit map[string]Stat = map[string]Stat{}
-	var totalPay float64 = 0
-	var totalCreditPay float64 = 0
-	var totalIncome float64 = 0
+	var statIncome map[string]Stat = map[string]Stat{}
+	var totalPay Total = 0
+	var totalCreditPay Total = 0
+	var totalIncome Total = 0
 
-	boolQuery := bleve.NewBooleanQuery()
+	var records []Record
+	var err error
+	if len(queries) > 0 {
+		var recordIDs []string
+		recordIDs, err = searchRecordIDs(queries, queryType, startDate, endDate)
+		if err != nil {
+			return nil, nil, nil, nil, 0, 0, 0, err
+		}
 
-	// 기존 쿼리 조건 추가
-	for _, query := range queries {
-		matchQuery := bleve.NewMatchQuery(query)
-		if queryType == "AND" {
-			boolQuery.AddMust(matchQuery)
-		} else {
-			boolQuery.AddShould(matchQuery)
+		// Load all hits in one read
+		records, err = ledger.GetRecords(recordIDs)
+		if err == nil && accountID != "" {
+			filtered := []Record{}
+			for _, record := range records {
+				if record.AccountID == accountID {
+					filtered = append(filtered, record)
+				}
+			}
+			records = filtered
 		}
+	} else {
+		records, err = ledger.ListRecords(accountID, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
 	}
-
-	dateRangeQuery := bleve.NewDateRangeQuery(startDate, endDate)
-	dateRangeQuery.SetField("date") // 'date' 필드에 대해 날짜 범위 검색
-	boolQuery.AddMust(dateRangeQuery)
-
-	search := bleve.NewSearchRequest(boolQuery)
-
-	// 페이징 리마크 - 일단 보류
-	// search.Size = pageSize
-	// search.From = (page - 1) * pageSize
-	search.Size = 1000
-
-	search.SortBy([]string{"date", "time", "_score"}) // SORT ASC
-	// search.SortBy([]string{"-date", "-time", "-_score"}) // SORT DESC
-
-	searchResults, err := bleveIndex.Search(search)
 	if err != nil {
-		return nil, nil, nil, 0, 0, 0, err
+		return nil, nil, nil, nil, 0, 0, 0, err
 	}
 
-	accounts, _ := getAccountListMAP()
-
-	for _, hit := range searchResults.Hits {
-		var record Record
-		err := db.View(func(txn *badger.Txn) error {
-			item, err := txn.Get([]byte(hit.ID))
-			if err != nil {
-				return err
-			}
+	// Search score is not meaningful for the list
+	sortRecords(records)
 
-			return item.Value(func(v []byte) error {
-				return json.Unmarshal(v, &record)
-			})
-		})
-		if err != nil {
-			continue
-		}
+	accounts, _ := getAccountListMAP()
+	settings, err := getSettings()
+	if err != nil {
+		return nil, nil, nil, nil, 0, 0, 0, err
+	}
 
+	for _, record := range records {
 		results = append(results, record)
 
+		// Totals are in the base currency
+		recordAmount := toBaseTotal(record.Amount, record.Currency, settings)
+
 		switch record.TransactionType {
 		case "record_type_pay":
 			switch record.PayType {
 			case "direct":
-				totalPay += record.Amount
+				totalPay += recordAmount
 
-				amount := record.Amount
+				amount := recordAmount
 				if s, exist := stat[record.Category]; exist {
-					amount = s.Amount + record.Amount
+					amount = s.Amount + recordAmount
 				}
 				stat[record.Category] = Stat{Category: record.Category, Amount: amount}
 			case "credit":
-				repayDay, err1 := strconv.Atoi(accounts[record.AccountID].RepayDay)
-				useDayFrom, err2 := strconv.Atoi(accounts[record.AccountID].UseDayFrom)
-				useDayTo, err3 := strconv.Atoi(accounts[record.AccountID].UseDayTo)
-
-				// If meet err, keep the type not repaid
-				if err1 != nil || err2 != nil || err3 != nil {
-					totalCreditPay += record.Amount
-
-					amount := record.Amount
-					if s, exist := statCredit[record.Category]; exist {
-						amount = s.Amount + record.Amount
-					}
-					statCredit[record.Category] = Stat{Category: record.Category, Amount: amount}
-
-					continue
-				}
+				if isCreditRepaid(record, accounts[record.AccountID], endDate) {
+					totalPay += recordAmount
 
-				repayDate, useDateFrom, useDateTo := getCreditDates(repayDay, useDayFrom, useDayTo, endDate)
-				recordDate, _ := time.Parse("2006-01-02 15:04", record.Date+" "+record.Time)
-
-				// Assume already paid: the day before "useDateFrom"
-				if recordDate.Before(useDateFrom) {
-					totalPay += record.Amount
-
-					amount := record.Amount
+					amount := recordAmount
 					if s, exist := stat[record.Category]; exist {
-						amount = s.Amount + record.Amount
+						amount = s.Amount + recordAmount
 					}
 					stat[record.Category] = Stat{Category: record.Category, Amount: amount}
 
 					continue
 				}
 
-				// Assume already paid: the day which meet all of the following conditions
-				// * "recordDate" is Between "useDateFrom" and "useDateTo" - "useDateFrom" is already filtered by the above condition
-				// * "endDate" is later than "repayDate"
-				if (recordDate.Before(useDateTo) || recordDate.Equal(useDateTo)) && (endDate.After(repayDate) || endDate.Equal(repayDate)) {
-					totalPay += record.Amount
-
-					amount := record.Amount
-					if s, exist := stat[record.Category]; exist {
-						amount = s.Amount + record.Amount
-					}
-					stat[record.Category] = Stat{Category: record.Category, Amount: amount}
-
-					continue
-				}
+				totalCreditPay += recordAmount
 
-				totalCreditPay += record.Amount
-
-				amount := record.Amount
+				amount := recordAmount
 				if s, exist := statCredit[record.Category]; exist {
-					amount = s.Amount + record.Amount
+					amount = s.Amount + recordAmount
 				}
 				statCredit[record.Category] = Stat{Category: record.Category, Amount: amount}
 			}
 		case "record_type_income":
-			totalIncome += record.Amount
+			totalIncome += recordAmount
+
+			amount := recordAmount
+			if s, exist := statIncome[record.Category]; exist {
+				amount = s.Amount + recordAmount
+			}
+			statIncome[record.Category] = Stat{Category: record.Category, Amount: amount}
+		}
+	}
+
+	return results, stat, statCredit, statIncome, totalPay, totalCreditPay, totalIncome, nil
+}
+
+// isCreditRepaid tells whether a credit record is assumed repaid by endDate, by the use period and repay day of account.
+// An account without the days keeps its records not repaid.
+func isCreditRepaid(record Record, account Account, endDate time.Time) bool {
+	repayDay, useDayFrom, useDayTo, ok := getCreditDays(account)
+	if !ok {
+		return false
+	}
+
+	repayDate, useDateFrom, useDateTo := getCreditDates(repayDay, useDayFrom, useDayTo, endDate)
+	recordDate, _ := time.Parse("2006-01-02 15:04", record.Date+" "+record.Time)
+
+	// Assume already paid: the day before "useDateFrom"
+	if recordDate.Before(useDateFrom) {
+		return true
+	}
+
+	// Assume already paid: the day which meet all of the following conditions
+	// * "recordDate" is Between "useDateFrom" and "useDateTo" - "useDateFrom" is already filtered by the above condition
+	// * "endDate" is later than "repayDate"
+	return (recordDate.Before(useDateTo) || recordDate.Equal(useDateTo)) && (endDate.After(repayDate) || endDate.Equal(repayDate))
+}
+
+func searchRecordIDs(queries []string, queryType string, startDate, endDate time.Time) ([]string, error) {
+	boolQuery := bleve.NewBooleanQuery()
+
+	// 기존 쿼리 조건 추가
+	for _, query := range queries {
+		matchQuery := bleve.NewMatchQuery(query)
+		if queryType == "AND" {
+			boolQuery.AddMust(matchQuery)
+		} else {
+			boolQuery.AddShould(matchQuery)
 		}
 	}
 
-	return results, stat, statCredit, totalPay, totalCreditPay, totalIncome, nil
+	// With the date range as a must, should queries are optional unless one is required
+	if queryType != "AND" && len(queries) > 0 {
+		boolQuery.SetMinShould(1)
+	}
+
+	dateRangeQuery := bleve.NewDateRangeQuery(startDate, endDate)
+	dateRangeQuery.SetField("date") // 'date' 필드에 대해 날짜 범위 검색
+	boolQuery.AddMust(dateRangeQuery)
+
+	// All hits are needed for the stats of the period, loaded by pages of searchPageSize.
+	// Paged after the last _id - a From offset collects all hits before the page again.
+	// Sorting by other fields loads doc values and is slow, records are sorted after loading.
+	results := []string{}
+	search := bleve.NewSearchRequest(boolQuery)
+	search.Size = searchPageSize
+	search.SortBy([]string{"_id"})
+	for {
+		searchResults, err := bleveIndex.Search(search)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, hit := range searchResults.Hits {
+			results = append(results, hit.ID)
+		}
+		if len(searchResults.Hits) < searchPageSize {
+			return results, nil
+		}
+		search.SetSearchAfter([]string{searchResults.Hits[len(searchResults.Hits)-1].ID})
+	}
 }
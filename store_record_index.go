@@ -0,0 +1,158 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Secondary index keys of records. Values are empty, the record ID is the last part of the key.
+// * idx:date:<YYYY-MM-DD>T<HH:MM>:<record id>
+// * idx:account:<account id>|<YYYY-MM-DD>T<HH:MM>:<record id>
+const (
+	recordDateIndexPrefix    = "idx:date:"
+	recordAccountIndexPrefix = "idx:account:"
+
+	recordIndexVersion = "1"
+)
+
+var recordIndexVersionKey = []byte("meta:record-index-version")
+
+func recordDateIndexKey(record Record) []byte {
+	return []byte(recordDateIndexPrefix + record.Date + "T" + record.Time + ":" + record.ID)
+}
+
+func recordAccountIndexKey(record Record) []byte {
+	return []byte(recordAccountIndexPrefix + record.AccountID + "|" + record.Date + "T" + record.Time + ":" + record.ID)
+}
+
+func setRecordIndexes(txn *badger.Txn, record Record) error {
+	if err := txn.Set(recordDateIndexKey(record), nil); err != nil {
+		return err
+	}
+	if record.AccountID != "" {
+		return txn.Set(recordAccountIndexKey(record), nil)
+	}
+	return nil
+}
+
+func deleteRecordIndexes(txn *badger.Txn, record Record) error {
+	if err := txn.Delete(recordDateIndexKey(record)); err != nil {
+		return err
+	}
+	if record.AccountID != "" {
+		return txn.Delete(recordAccountIndexKey(record))
+	}
+	return nil
+}
+
+// getRecordInTxn reads a record. Not found is badger.ErrKeyNotFound.
+func getRecordInTxn(txn *badger.Txn, id string) (Record, error) {
+	var record Record
+
+	item, err := txn.Get([]byte(id))
+	if err != nil {
+		return Record{}, err
+	}
+	err = item.Value(func(v []byte) error {
+		return json.Unmarshal(v, (*storedRecord)(&record))
+	})
+
+	return record, err
+}
+
+// listRecordIDsByDate returns IDs of records between from and to(YYYY-MM-DD, inclusive), sorted by date and time.
+// With accountID, only the records of the account.
+func listRecordIDsByDate(txn *badger.Txn, accountID, from, to string) []string {
+	results := []string{}
+
+	prefix := recordDateIndexPrefix
+	if accountID != "" {
+		prefix = recordAccountIndexPrefix + accountID + "|"
+	}
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = []byte(prefix)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	for it.Seek([]byte(prefix + from)); it.ValidForPrefix([]byte(prefix)); it.Next() {
+		key := string(it.Item().Key())[len(prefix):]
+		if len(key) < len("2006-01-02") || key[:len("2006-01-02")] > to {
+			break
+		}
+
+		// <date>T<time>:<record id>
+		timeEnd := strings.Index(key, ":record:")
+		if timeEnd < 0 {
+			continue
+		}
+		results = append(results, key[timeEnd+1:])
+	}
+
+	return results
+}
+
+// ensureRecordIndexes builds the secondary indexes for records stored before they existed
+func (s *badgerLedgerStore) ensureRecordIndexes() error {
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(recordIndexVersionKey)
+		if err != nil {
+			return err
+		}
+		return item.Value(func(v []byte) error {
+			if string(v) != recordIndexVersion {
+				return badger.ErrKeyNotFound
+			}
+			return nil
+		})
+	})
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, badger.ErrKeyNotFound) {
+		return err
+	}
+
+	batch := s.db.NewWriteBatch()
+	defer batch.Cancel()
+
+	err = s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek([]byte("record:")); it.ValidForPrefix([]byte("record:")); it.Next() {
+			var record Record
+			err := it.Item().Value(func(v []byte) error {
+				return json.Unmarshal(v, (*storedRecord)(&record))
+			})
+			if err != nil {
+				return err
+			}
+
+			if err := batch.Set(recordDateIndexKey(record), nil); err != nil {
+				return err
+			}
+			if record.AccountID != "" {
+				if err := batch.Set(recordAccountIndexKey(record), nil); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build record indexes: %w", err)
+	}
+
+	if err := batch.Set(recordIndexVersionKey, []byte(recordIndexVersion)); err != nil {
+		return err
+	}
+
+	return batch.Flush()
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/index/scorch"
+)
+
+const benchmarkRecordCount = 50_000
+
+var benchmarkWords = []string{"lunch", "coffee", "taxi", "groceries", "movie", "books", "rent", "phone", "gym", "snack"}
+
+// Backends of the record benchmarks. Badger is searched with the encrypted index - a lookup by ID per hit.
+var benchmarkBackends = []string{storageBackendMemory, storageBackendBadger}
+
+// seedBenchmarkLedger fills a store of backend and its index with records spread over 2025.
+// Returns the count of the records with "coffee" or "taxi".
+func seedBenchmarkLedger(b *testing.B, backend string) int {
+	b.Helper()
+	dir := b.TempDir()
+
+	oldKey, oldPath, oldIndexMode := indexEncryptionKey, bleveIndexPath, IndexMode
+	var err error
+	switch backend {
+	case storageBackendBadger:
+		ledger, err = openBadgerLedgerStore(filepath.Join(dir, "badger_data"), make([]byte, 32))
+		if err != nil {
+			b.Fatal(err)
+		}
+		indexEncryptionKey, bleveIndexPath, IndexMode = make([]byte, 32), filepath.Join(dir, "record_index.bleve"), indexModeEncrypted
+		bleveIndex, err = openEncryptedBleveIndex()
+	default:
+		// Memory index of indexModeMemory
+		ledger = newMemoryLedgerStore()
+		bleveIndex, err = bleve.NewUsing("", bleve.NewIndexMapping(), scorch.Name, scorch.Name, nil)
+	}
+	if err != nil {
+		b.Fatal(err)
+	}
+	store, index := ledger, bleveIndex
+	b.Cleanup(func() {
+		index.Close()
+		store.Close()
+		if ledger == store {
+			ledger, bleveIndex = nil, nil
+			indexEncryptionKey, bleveIndexPath, IndexMode = oldKey, oldPath, oldIndexMode
+		}
+	})
+	invalidateAccountCache()
+	invalidateHolidayCache()
+
+	accounts := []Account{
+		{ID: "account:1", AccountName: "wallet", PayType: "direct"},
+		{ID: "account:2", AccountName: "card", PayType: "credit", RepayDay: "14", UseDayFrom: "1", UseDayTo: "말일"},
+	}
+	for _, account := range accounts {
+		if err := ledger.AddAccount(account); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	matches := 0
+	start := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < benchmarkRecordCount; i++ {
+		account := accounts[i%len(accounts)]
+		record := Record{
+			ID:              fmt.Sprintf("record:%d", i),
+			TransactionType: "record_type_pay",
+			AccountID:       account.ID,
+			PayType:         account.PayType,
+			Currency:        "KRW",
+			Amount:          Money(1000 + i%50_000),
+			Category:        benchmarkWords[i%len(benchmarkWords)],
+			Description:     fmt.Sprintf("%s %d", benchmarkWords[(i/7)%len(benchmarkWords)], i),
+			Date:            start.AddDate(0, 0, i%365).Format("2006-01-02"),
+			Time:            fmt.Sprintf("%02d:%02d", i%24, i%60),
+		}
+		if err := ledger.AddRecord(record); err != nil {
+			b.Fatal(err)
+		}
+
+		word := benchmarkWords[(i/7)%len(benchmarkWords)]
+		if record.Category == "coffee" || record.Category == "taxi" || word == "coffee" || word == "taxi" {
+			matches++
+		}
+	}
+
+	if err := rebuildBleveIndex(); err != nil {
+		b.Fatal(err)
+	}
+	return matches
+}
+
+var benchmarkStartDate = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+var benchmarkEndDate = time.Date(2025, time.December, 31, 23, 59, 59, 0, time.UTC)
+
+// Benchmarks of GET /records on each backend, seeded once - a badger index takes a while to build.
+// search is the bleve search and get-records the load of its hits, the parts of list-query.
+// get-record-per-hit is the load before one GetRecords - a lookup per hit.
+func BenchmarkRecords(b *testing.B) {
+	queries := []string{"coffee", "taxi"}
+
+	for _, backend := range benchmarkBackends {
+		matches := seedBenchmarkLedger(b, backend)
+
+		b.Run(backend+"/list", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				records, _, _, _, _, _, _, err := getRecords(nil, "", "", benchmarkStartDate, benchmarkEndDate)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(records) != benchmarkRecordCount {
+					b.Fatalf("got %d records, want %d", len(records), benchmarkRecordCount)
+				}
+			}
+		})
+
+		b.Run(backend+"/list-query", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				records, _, _, _, _, _, _, err := getRecords(queries, "OR", "", benchmarkStartDate, benchmarkEndDate)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(records) != matches {
+					b.Fatalf("got %d records, want %d", len(records), matches)
+				}
+			}
+		})
+
+		b.Run(backend+"/search", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ids, err := searchRecordIDs(queries, "OR", benchmarkStartDate, benchmarkEndDate)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(ids) != matches {
+					b.Fatalf("got %d hits, want %d", len(ids), matches)
+				}
+			}
+		})
+
+		ids, err := searchRecordIDs(queries, "OR", benchmarkStartDate, benchmarkEndDate)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(backend+"/get-records", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				records, err := ledger.GetRecords(ids)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(records) != matches {
+					b.Fatalf("got %d records, want %d", len(records), matches)
+				}
+			}
+		})
+
+		b.Run(backend+"/get-record-per-hit", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, id := range ids {
+					if _, err := ledger.GetRecord(id); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
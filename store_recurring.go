@@ -0,0 +1,64 @@
+package server
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const recurringMetaKey = "recurring"
+
+// getRecurringItems returns the recurring expenses and incomes, kept as one document like the settings
+func getRecurringItems() ([]RecurringItem, error) {
+	results := []RecurringItem{}
+
+	value, err := ledger.GetMeta(recurringMetaKey)
+	if err != nil || value == "" {
+		return results, err
+	}
+
+	err = json.Unmarshal([]byte(value), &results)
+	return results, err
+}
+
+// updateRecurringItems replaces all recurring items
+func updateRecurringItems(items []RecurringItem) error {
+	for i, item := range items {
+		if err := validateRecurringItem(i, item); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+
+	return ledger.SetMeta(recurringMetaKey, string(data))
+}
+
+// getRecurringDates returns the dates of item between startDate and endDate
+func getRecurringDates(item RecurringItem, startDate, endDate time.Time) []time.Time {
+	results := []time.Time{}
+
+	for month := time.Date(startDate.Year(), startDate.Month(), 1, 0, 0, 0, 0, startDate.Location()); !month.After(endDate); month = month.AddDate(0, 1, 0) {
+		if item.Month != 0 && int(month.Month()) != item.Month {
+			continue
+		}
+
+		day := item.Day
+		if daysOfMonth := month.AddDate(0, 1, -1).Day(); day > daysOfMonth {
+			day = daysOfMonth
+		}
+		date := time.Date(month.Year(), month.Month(), day, 0, 0, 0, 0, month.Location())
+
+		if date.Before(startDate) || date.After(endDate) {
+			continue
+		}
+		if (item.StartDate != "" && date.Format("2006-01-02") < item.StartDate) || (item.EndDate != "" && date.Format("2006-01-02") > item.EndDate) {
+			continue
+		}
+		results = append(results, date)
+	}
+
+	return results
+}
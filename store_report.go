@@ -0,0 +1,459 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A report over more buckets is rejected, e.g. days of years
+const maxTrendBuckets = 1000
+
+var trendGranularities = []string{"day", "week", "month", "year"}
+var trendGroupBys = []string{"category", "account", "type"}
+
+// getTrendBucketStart returns the start of the bucket of date. Weeks and months follow the settings.
+func getTrendBucketStart(settings Settings, granularity string, date time.Time) time.Time {
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+
+	switch granularity {
+	case "week":
+		offset := int(day.Weekday())
+		if settings.WeekStart == "monday" {
+			offset = (offset + 6) % 7
+		}
+		return day.AddDate(0, 0, -offset)
+	case "month":
+		start, _ := getFiscalMonth(settings, day)
+		return start
+	case "year":
+		start := time.Date(day.Year(), time.January, settings.FiscalMonthStart, 0, 0, 0, 0, day.Location())
+		if day.Before(start) {
+			start = start.AddDate(-1, 0, 0)
+		}
+		return start
+	}
+
+	return day
+}
+
+func getNextTrendBucketStart(granularity string, start time.Time) time.Time {
+	switch granularity {
+	case "week":
+		return start.AddDate(0, 0, 7)
+	case "month":
+		return start.AddDate(0, 1, 0)
+	case "year":
+		return start.AddDate(1, 0, 0)
+	}
+
+	return start.AddDate(0, 0, 1)
+}
+
+func getTrendLabel(granularity string, start time.Time) string {
+	switch granularity {
+	case "month":
+		return start.Format("2006-01")
+	case "year":
+		return start.Format("2006")
+	}
+
+	return start.Format("2006-01-02")
+}
+
+// getTrendReport sums the records of the period by buckets of granularity in one pass.
+// Credit records are repaid or not as of the end of their bucket, like getRecords of the bucket.
+func getTrendReport(granularity, groupBy string, startDate, endDate time.Time) (TrendReport, error) {
+	settings, err := getSettings()
+	if err != nil {
+		return TrendReport{}, err
+	}
+
+	report := TrendReport{
+		Granularity: granularity,
+		GroupBy:     groupBy,
+		Currency:    settings.BaseCurrency,
+		Labels:      []string{},
+		Buckets:     []TrendBucket{},
+		Series:      []TrendSeries{},
+	}
+
+	// Buckets are cut to the period
+	starts := []time.Time{}
+	ends := []time.Time{}
+	for start := getTrendBucketStart(settings, granularity, startDate); !start.After(endDate); start = getNextTrendBucketStart(granularity, start) {
+		if len(starts) == maxTrendBuckets {
+			return TrendReport{}, newValidationError("granularity", "Too many buckets, more than %d. Use a larger granularity", maxTrendBuckets)
+		}
+
+		from := start
+		if from.Before(startDate) {
+			from = startDate
+		}
+		to := getNextTrendBucketStart(granularity, start).Add(-time.Second)
+		if to.After(endDate) {
+			to = endDate
+		}
+
+		starts = append(starts, from)
+		ends = append(ends, to)
+		report.Labels = append(report.Labels, getTrendLabel(granularity, start))
+		report.Buckets = append(report.Buckets, TrendBucket{Label: getTrendLabel(granularity, start), From: from.Format("2006-01-02"), To: to.Format("2006-01-02")})
+	}
+
+	records, err := ledger.ListRecords("", startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
+	if err != nil {
+		return TrendReport{}, err
+	}
+	accounts, _ := getAccountListMAP()
+
+	series := map[string]*TrendSeries{}
+	for _, record := range records {
+		date, err := time.ParseInLocation("2006-01-02", record.Date, startDate.Location())
+		if err != nil {
+			continue
+		}
+		index := sort.Search(len(starts), func(i int) bool { return starts[i].After(date) }) - 1
+		if index < 0 || date.After(ends[index]) {
+			continue
+		}
+
+		key, label := record.TransactionType, record.TransactionType
+		switch groupBy {
+		case "category":
+			key, label = record.Category, record.Category
+		case "account":
+			key, label = record.AccountID, accounts[record.AccountID].AccountName
+		}
+		s, exist := series[key]
+		if !exist {
+			s = &TrendSeries{Key: key, Label: label, Pay: make([]Total, len(starts)), CreditPay: make([]Total, len(starts)), Income: make([]Total, len(starts))}
+			series[key] = s
+		}
+
+		// Totals are in the base currency
+		recordAmount := toBaseTotal(record.Amount, record.Currency, settings)
+		bucket := &report.Buckets[index]
+
+		switch record.TransactionType {
+		case "record_type_pay":
+			switch {
+			case record.PayType == "direct", record.PayType == "credit" && isCreditRepaid(record, accounts[record.AccountID], ends[index]):
+				bucket.Pay += recordAmount
+				s.Pay[index] += recordAmount
+			case record.PayType == "credit":
+				bucket.CreditPay += recordAmount
+				s.CreditPay[index] += recordAmount
+			}
+		case "record_type_income":
+			bucket.Income += recordAmount
+			s.Income[index] += recordAmount
+		}
+	}
+
+	for _, s := range series {
+		report.Series = append(report.Series, *s)
+	}
+	sort.Slice(report.Series, func(i, j int) bool { return report.Series[i].Key < report.Series[j].Key })
+
+	return report, nil
+}
+
+// Records listed per change of a compare report
+const compareTopRecordCount = 3
+
+var compareAgainsts = []string{"previous", "last-year"}
+
+// parseComparePeriod parses YYYY-MM to the month, or YYYY to the year of the settings
+func parseComparePeriod(settings Settings, period string) (string, time.Time, error) {
+	if date, err := time.Parse("2006-01", period); err == nil {
+		return "month", time.Date(date.Year(), date.Month(), settings.FiscalMonthStart, 0, 0, 0, 0, time.UTC), nil
+	}
+	if date, err := time.Parse("2006", period); err == nil {
+		return "year", time.Date(date.Year(), time.January, settings.FiscalMonthStart, 0, 0, 0, 0, time.UTC), nil
+	}
+
+	return "", time.Time{}, newValidationError("period", "period must be YYYY-MM or YYYY: %s", period)
+}
+
+// Spending of a compare period - the pay records counted by getRecords
+type compareTotals struct {
+	period     ComparePeriod
+	categories map[string]Total
+	accounts   map[string]Total
+	records    []Record
+}
+
+func getCompareTotals(label string, startDate, endDate time.Time, settings Settings) (compareTotals, error) {
+	records, stats, statsCredit, _, sumPay, sumCreditPay, sumIncome, err := getRecords(nil, "", "", startDate, endDate)
+	if err != nil {
+		return compareTotals{}, err
+	}
+
+	totals := compareTotals{
+		period: ComparePeriod{
+			Label: label, From: startDate.Format("2006-01-02"), To: endDate.Format("2006-01-02"),
+			SumPay: sumPay, SumCreditPay: sumCreditPay, SumIncome: sumIncome,
+		},
+		categories: map[string]Total{},
+		accounts:   map[string]Total{},
+	}
+
+	// Paid and not yet repaid
+	for category, stat := range stats {
+		totals.categories[category] += stat.Amount
+	}
+	for category, stat := range statsCredit {
+		totals.categories[category] += stat.Amount
+	}
+
+	for _, record := range records {
+		if record.TransactionType != "record_type_pay" || (record.PayType != "direct" && record.PayType != "credit") {
+			continue
+		}
+		totals.accounts[record.AccountID] += toBaseTotal(record.Amount, record.Currency, settings)
+		totals.records = append(totals.records, record)
+	}
+
+	return totals, nil
+}
+
+// getTopRecords returns the largest records of the group by key
+func getTopRecords(records []Record, key func(Record) string, group string, settings Settings) []Record {
+	results := []Record{}
+	for _, record := range records {
+		if key(record) == group {
+			results = append(results, record)
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return toBaseTotal(results[i].Amount, results[i].Currency, settings) > toBaseTotal(results[j].Amount, results[j].Currency, settings)
+	})
+	if len(results) > compareTopRecordCount {
+		results = results[:compareTopRecordCount]
+	}
+
+	return results
+}
+
+// getCompareDeltas sorts the changes by size. Top records are from the current period for an increase, from the previous for a decrease.
+func getCompareDeltas(current, previous compareTotals, groups func(compareTotals) map[string]Total, key func(Record) string, label func(string) string, settings Settings) []CompareDelta {
+	keys := map[string]bool{}
+	for group := range groups(current) {
+		keys[group] = true
+	}
+	for group := range groups(previous) {
+		keys[group] = true
+	}
+
+	results := []CompareDelta{}
+	for group := range keys {
+		currentAmount, inCurrent := groups(current)[group]
+		previousAmount, inPrevious := groups(previous)[group]
+		delta := CompareDelta{Key: group, Label: label(group), Current: currentAmount, Previous: previousAmount, Change: currentAmount - previousAmount}
+
+		delta.Percent = getPercent(delta.Change, previousAmount)
+
+		switch {
+		case !inPrevious:
+			delta.Status = "new"
+		case !inCurrent:
+			delta.Status = "disappeared"
+		case delta.Change > 0:
+			delta.Status = "increased"
+		case delta.Change < 0:
+			delta.Status = "decreased"
+		default:
+			delta.Status = "unchanged"
+		}
+
+		if delta.Change >= 0 {
+			delta.TopRecords = getTopRecords(current.records, key, group, settings)
+		} else {
+			delta.TopRecords = getTopRecords(previous.records, key, group, settings)
+		}
+
+		results = append(results, delta)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := math.Abs(float64(results[i].Change)), math.Abs(float64(results[j].Change))
+		if a != b {
+			return a > b
+		}
+		return results[i].Key < results[j].Key
+	})
+
+	return results
+}
+
+// getPercent returns value in percent of base, rounded to 0.1. nil when base is 0.
+func getPercent(value, base Total) *float64 {
+	if base == 0 {
+		return nil
+	}
+
+	percent := math.Round(float64(value)/math.Abs(float64(base))*1000) / 10
+	return &percent
+}
+
+// getCompareReport compares the spending of period with the previous period or the same period of last year
+func getCompareReport(period, against string) (CompareReport, error) {
+	settings, err := getSettings()
+	if err != nil {
+		return CompareReport{}, err
+	}
+
+	granularity, startDate, err := parseComparePeriod(settings, period)
+	if err != nil {
+		return CompareReport{}, err
+	}
+	previousStartDate := startDate.AddDate(-1, 0, 0)
+	if against == "previous" && granularity == "month" {
+		previousStartDate = startDate.AddDate(0, -1, 0)
+	}
+
+	current, err := getCompareTotals(getTrendLabel(granularity, startDate), startDate, getNextTrendBucketStart(granularity, startDate).Add(-time.Second), settings)
+	if err != nil {
+		return CompareReport{}, err
+	}
+	previous, err := getCompareTotals(getTrendLabel(granularity, previousStartDate), previousStartDate, getNextTrendBucketStart(granularity, previousStartDate).Add(-time.Second), settings)
+	if err != nil {
+		return CompareReport{}, err
+	}
+
+	accounts, _ := getAccountListMAP()
+	report := CompareReport{
+		Against:  against,
+		Currency: settings.BaseCurrency,
+		Current:  current.period,
+		Previous: previous.period,
+		Categories: getCompareDeltas(current, previous,
+			func(t compareTotals) map[string]Total { return t.categories },
+			func(r Record) string { return r.Category },
+			func(category string) string { return category },
+			settings),
+		Accounts: getCompareDeltas(current, previous,
+			func(t compareTotals) map[string]Total { return t.accounts },
+			func(r Record) string { return r.AccountID },
+			func(id string) string { return accounts[id].AccountName },
+			settings),
+		NewCategories:         []string{},
+		DisappearedCategories: []string{},
+	}
+
+	for _, delta := range report.Categories {
+		switch delta.Status {
+		case "new":
+			report.NewCategories = append(report.NewCategories, delta.Key)
+		case "disappeared":
+			report.DisappearedCategories = append(report.DisappearedCategories, delta.Key)
+		}
+	}
+	sort.Strings(report.NewCategories)
+	sort.Strings(report.DisappearedCategories)
+
+	return report, nil
+}
+
+// getCalendarReport sums the records of the year by day, weekday and hour in one scan.
+// Spending is of the pay records counted by getRecords, repaid or not.
+func getCalendarReport(year int) (CalendarReport, error) {
+	settings, err := getSettings()
+	if err != nil {
+		return CalendarReport{}, err
+	}
+
+	startDate := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	endDate := time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+	report := CalendarReport{Year: year, Currency: settings.BaseCurrency, Days: []CalendarDay{}, Weekdays: []CalendarBucket{}, Hours: []CalendarBucket{}, NoTime: CalendarBucket{Key: "no-time"}}
+	for date := startDate; !date.After(endDate); date = date.AddDate(0, 0, 1) {
+		report.Days = append(report.Days, CalendarDay{Date: date.Format("2006-01-02")})
+	}
+	weekdays := make([]CalendarBucket, 7)
+	for i := range weekdays {
+		weekdays[i].Key = strings.ToLower(time.Weekday(i).String())
+	}
+	for hour := 0; hour < 24; hour++ {
+		report.Hours = append(report.Hours, CalendarBucket{Key: fmt.Sprintf("%02d", hour)})
+	}
+
+	records, err := ledger.ListRecords("", startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
+	if err != nil {
+		return CalendarReport{}, err
+	}
+
+	for _, record := range records {
+		date, err := time.Parse("2006-01-02", record.Date)
+		if err != nil || date.Year() != year {
+			continue
+		}
+		day := &report.Days[date.YearDay()-1]
+		day.Count++
+
+		// Totals are in the base currency
+		recordAmount := toBaseTotal(record.Amount, record.Currency, settings)
+
+		switch {
+		case record.TransactionType == "record_type_income":
+			day.Income += recordAmount
+			report.Income += recordAmount
+		case record.TransactionType == "record_type_pay" && (record.PayType == "direct" || record.PayType == "credit"):
+			day.Spending += recordAmount
+			report.Spending += recordAmount
+
+			weekdays[date.Weekday()].Spending += recordAmount
+			weekdays[date.Weekday()].Count++
+
+			bucket := &report.NoTime
+			if recordTime, err := time.Parse("15:04", record.Time); err == nil {
+				bucket = &report.Hours[recordTime.Hour()]
+			}
+			bucket.Spending += recordAmount
+			bucket.Count++
+		}
+	}
+
+	for _, day := range report.Days {
+		if day.Spending > report.MaxDailySpending {
+			report.MaxDailySpending = day.Spending
+		}
+	}
+
+	// Week from the week start of the settings
+	first := int(time.Sunday)
+	if settings.WeekStart == "monday" {
+		first = int(time.Monday)
+	}
+	for i := 0; i < 7; i++ {
+		report.Weekdays = append(report.Weekdays, weekdays[(first+i)%7])
+	}
+
+	return report, nil
+}
+
+// getSavingsReport returns income, spending(paid and not yet repaid) and net savings by month of the settings
+func getSavingsReport(startDate, endDate time.Time) (SavingsReport, error) {
+	trend, err := getTrendReport("month", "type", startDate, endDate)
+	if err != nil {
+		return SavingsReport{}, err
+	}
+
+	report := SavingsReport{Currency: trend.Currency, Months: []SavingsMonth{}}
+	for _, bucket := range trend.Buckets {
+		month := SavingsMonth{Label: bucket.Label, From: bucket.From, To: bucket.To, Income: bucket.Income, Spending: bucket.Pay + bucket.CreditPay}
+		month.Net = month.Income - month.Spending
+		month.Rate = getPercent(month.Net, month.Income)
+		report.Months = append(report.Months, month)
+
+		report.Income += month.Income
+		report.Spending += month.Spending
+	}
+	report.Net = report.Income - report.Spending
+	report.Rate = getPercent(report.Net, report.Income)
+
+	return report, nil
+}
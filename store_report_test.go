@@ -0,0 +1,375 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+// addTestRecords adds pay records of KRW by default, to the ledger of useTestLedger
+func addTestRecords(t *testing.T, records ...Record) {
+	t.Helper()
+
+	for i, record := range records {
+		if record.ID == "" {
+			record.ID = "record:" + record.Date + ":" + string(rune('a'+i))
+		}
+		if record.TransactionType == "" {
+			record.TransactionType = "record_type_pay"
+		}
+		if record.PayType == "" {
+			record.PayType = "direct"
+		}
+		if record.Currency == "" {
+			record.Currency = "KRW"
+		}
+		if record.Category == "" {
+			record.Category = "food"
+		}
+		if err := ledger.AddRecord(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTrendBucketStart(t *testing.T) {
+	date := func(value string) time.Time {
+		day, _ := time.Parse("2006-01-02", value)
+		return day
+	}
+	monday, sunday := defaultSettings(), defaultSettings()
+	sunday.WeekStart = "sunday"
+	fiscal := defaultSettings()
+	fiscal.FiscalMonthStart = 25
+
+	tests := []struct {
+		name        string
+		settings    Settings
+		granularity string
+		date        string
+		want        string
+	}{
+		{"day", monday, "day", "2026-03-01", "2026-03-01"},
+		{"week of monday, sunday", monday, "week", "2026-03-01", "2026-02-23"},
+		{"week of monday, monday", monday, "week", "2026-03-02", "2026-03-02"},
+		{"week of monday, last day", monday, "week", "2026-03-08", "2026-03-02"},
+		{"week of sunday, sunday", sunday, "week", "2026-03-01", "2026-03-01"},
+		{"week of sunday, saturday", sunday, "week", "2026-03-07", "2026-03-01"},
+		{"week over the year", monday, "week", "2026-01-01", "2025-12-29"},
+		{"month, last day", monday, "month", "2026-03-31", "2026-03-01"},
+		{"month, leap day", monday, "month", "2024-02-29", "2024-02-01"},
+		{"fiscal month, day before the start", fiscal, "month", "2026-03-24", "2026-02-25"},
+		{"fiscal month, start", fiscal, "month", "2026-03-25", "2026-03-25"},
+		{"fiscal month over the year", fiscal, "month", "2026-01-10", "2025-12-25"},
+		{"year, last day", monday, "year", "2026-12-31", "2026-01-01"},
+		{"fiscal year, day before the start", fiscal, "year", "2026-01-24", "2025-01-25"},
+		{"fiscal year, start", fiscal, "year", "2026-01-25", "2026-01-25"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := getTrendBucketStart(test.settings, test.granularity, date(test.date)).Format("2006-01-02"); got != test.want {
+				t.Fatalf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+// Buckets are cut to the period, records on the first and the last day of a bucket stay in it
+func TestTrendReportBuckets(t *testing.T) {
+	useTestLedger(t, newMemoryLedgerStore())
+
+	addTestRecords(t,
+		Record{Amount: 1000, Date: "2025-12-31"},
+		Record{Amount: 2000, Date: "2026-01-01"},
+		Record{Amount: 4000, Date: "2026-03-01"},
+		Record{Amount: 8000, Date: "2026-03-02", Category: "cafe"},
+		Record{Amount: 16000, Date: "2026-03-08"},
+		Record{Amount: 32000, Date: "2026-03-09", TransactionType: "record_type_income", Category: "salary"},
+		Record{Amount: 64000, Date: "2026-03-16"},
+	)
+
+	type bucket struct {
+		label, from, to string
+		pay, income     Money
+	}
+	tests := []struct {
+		granularity string
+		from, to    string
+		want        []bucket
+	}{
+		{"week", "2026-03-01", "2026-03-15", []bucket{
+			{"2026-02-23", "2026-03-01", "2026-03-01", 4000, 0},
+			{"2026-03-02", "2026-03-02", "2026-03-08", 24000, 0},
+			{"2026-03-09", "2026-03-09", "2026-03-15", 0, 32000},
+		}},
+		{"month", "2026-01-01", "2026-03-31", []bucket{
+			{"2026-01", "2026-01-01", "2026-01-31", 2000, 0},
+			{"2026-02", "2026-02-01", "2026-02-28", 0, 0},
+			{"2026-03", "2026-03-01", "2026-03-31", 92000, 32000},
+		}},
+		{"year", "2025-12-15", "2026-06-30", []bucket{
+			{"2025", "2025-12-15", "2025-12-31", 1000, 0},
+			{"2026", "2026-01-01", "2026-06-30", 94000, 32000},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.granularity, func(t *testing.T) {
+			startDate, _ := time.Parse("2006-01-02", test.from)
+			endDate, _ := time.Parse("2006-01-02 15:04:05", test.to+" 23:59:59")
+			report, err := getTrendReport(test.granularity, "category", startDate, endDate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(report.Buckets) != len(test.want) || len(report.Labels) != len(test.want) {
+				t.Fatalf("got buckets %+v", report.Buckets)
+			}
+			for i, want := range test.want {
+				got := report.Buckets[i]
+				if got.Label != want.label || got.From != want.from || got.To != want.to || got.Pay != toTotal(want.pay, "KRW") || got.Income != toTotal(want.income, "KRW") {
+					t.Fatalf("bucket %d: got %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+
+	// Series by category, summing to the buckets
+	startDate := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	report, err := getTrendReport("week", "category", startDate, startDate.AddDate(0, 0, 22).Add(-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Series) != 3 || report.Series[0].Key != "cafe" || report.Series[1].Key != "food" || report.Series[2].Key != "salary" {
+		t.Fatalf("got series %+v", report.Series)
+	}
+	if food := report.Series[1].Pay; len(food) != 4 || food[0] != toTotal(4000, "KRW") || food[1] != toTotal(16000, "KRW") || food[2] != 0 || food[3] != toTotal(64000, "KRW") {
+		t.Fatalf("got food %v", food)
+	}
+}
+
+func TestTrendReportTooManyBuckets(t *testing.T) {
+	useTestLedger(t, newMemoryLedgerStore())
+
+	startDate := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err := getTrendReport("day", "type", startDate, startDate.AddDate(3, 0, 0))
+	assertErrorCode(t, err, errorCodeValidation)
+}
+
+func TestCompareReportDeltas(t *testing.T) {
+	useTestLedger(t, newMemoryLedgerStore())
+
+	if err := ledger.AddAccount(Account{ID: "account:bank", AccountName: "bank", PayType: "direct"}); err != nil {
+		t.Fatal(err)
+	}
+	addTestRecords(t,
+		Record{Amount: 10000, Date: "2026-02-03", Category: "food", AccountID: "account:bank"},
+		Record{Amount: 5000, Date: "2026-02-04", Category: "cafe", AccountID: "account:bank"},
+		Record{Amount: 1000, Date: "2026-02-05", Category: "taxi", AccountID: "account:bank"},
+		Record{Amount: 2000, Date: "2026-02-28", Category: "taxi", AccountID: "account:bank"},
+		Record{Amount: 15000, Date: "2026-03-01", Category: "food", AccountID: "account:bank"},
+		Record{Amount: 5000, Date: "2026-03-02", Category: "cafe", AccountID: "account:bank"},
+		Record{Amount: 7000, Date: "2026-03-31", Category: "books", AccountID: "account:bank"},
+		Record{Amount: 50000, Date: "2026-03-25", Category: "salary", TransactionType: "record_type_income"},
+	)
+
+	report, err := getCompareReport("2026-03", "previous")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Current.From != "2026-03-01" || report.Current.To != "2026-03-31" || report.Previous.From != "2026-02-01" || report.Previous.To != "2026-02-28" {
+		t.Fatalf("got periods %+v, %+v", report.Current, report.Previous)
+	}
+	if report.Current.SumPay != toTotal(27000, "KRW") || report.Previous.SumPay != toTotal(18000, "KRW") || report.Current.SumIncome != toTotal(50000, "KRW") {
+		t.Fatalf("got sums %+v, %+v", report.Current, report.Previous)
+	}
+
+	// By the size of the change
+	percent := func(value float64) *float64 { return &value }
+	want := []struct {
+		key     string
+		change  Money
+		percent *float64
+		status  string
+		top     int
+	}{
+		{"books", 7000, nil, "new", 1},
+		{"food", 5000, percent(50), "increased", 1},
+		{"taxi", -3000, percent(-100), "disappeared", 2},
+		{"cafe", 0, percent(0), "unchanged", 1},
+	}
+	if len(report.Categories) != len(want) {
+		t.Fatalf("got categories %+v", report.Categories)
+	}
+	for i, want := range want {
+		got := report.Categories[i]
+		if got.Key != want.key || got.Change != toTotal(want.change, "KRW") || got.Status != want.status || len(got.TopRecords) != want.top {
+			t.Fatalf("category %d: got %+v, want %+v", i, got, want)
+		}
+		if (got.Percent == nil) != (want.percent == nil) || (got.Percent != nil && *got.Percent != *want.percent) {
+			t.Fatalf("category %s: got percent %v, want %v", got.Key, got.Percent, want.percent)
+		}
+	}
+
+	// A decrease lists the records of the previous period, the largest first
+	if taxi := report.Categories[2].TopRecords; taxi[0].Amount != 2000 || taxi[1].Amount != 1000 {
+		t.Fatalf("got taxi records %+v", taxi)
+	}
+	if len(report.NewCategories) != 1 || report.NewCategories[0] != "books" || len(report.DisappearedCategories) != 1 || report.DisappearedCategories[0] != "taxi" {
+		t.Fatalf("got new %v, disappeared %v", report.NewCategories, report.DisappearedCategories)
+	}
+	if len(report.Accounts) != 1 || report.Accounts[0].Label != "bank" || report.Accounts[0].Change != toTotal(9000, "KRW") || *report.Accounts[0].Percent != 50 {
+		t.Fatalf("got accounts %+v", report.Accounts)
+	}
+
+	// The same month of last year is empty
+	report, err = getCompareReport("2026-03", "last-year")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Previous.From != "2025-03-01" || len(report.Categories) != 3 || len(report.NewCategories) != 3 || report.Categories[0].Percent != nil {
+		t.Fatalf("got %+v", report)
+	}
+}
+
+func TestCompareReportPeriod(t *testing.T) {
+	useTestLedger(t, newMemoryLedgerStore())
+
+	report, err := getCompareReport("2026", "previous")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Current.From != "2026-01-01" || report.Current.To != "2026-12-31" || report.Previous.From != "2025-01-01" || report.Previous.To != "2025-12-31" {
+		t.Fatalf("got periods %+v, %+v", report.Current, report.Previous)
+	}
+
+	_, err = getCompareReport("2026-3", "previous")
+	assertErrorCode(t, err, errorCodeValidation)
+}
+
+func TestCalendarReport(t *testing.T) {
+	useTestLedger(t, newMemoryLedgerStore())
+
+	addTestRecords(t,
+		Record{Amount: 9000, Date: "2023-12-31"},
+		Record{Amount: 50000, Date: "2024-01-01", Category: "salary", TransactionType: "record_type_income"},
+		Record{Amount: 3000, Date: "2024-02-29", Time: "10:30"},
+		Record{Amount: 2000, Date: "2024-02-29"},
+		Record{Amount: 1000, Date: "2024-03-03", Time: "23:59"},
+		Record{Amount: 4000, Date: "2024-12-31", Time: "00:00"},
+	)
+
+	report, err := getCalendarReport(2024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Days) != 366 || report.Days[0].Date != "2024-01-01" || report.Days[365].Date != "2024-12-31" {
+		t.Fatalf("got %d days", len(report.Days))
+	}
+	if leapDay := report.Days[59]; leapDay.Date != "2024-02-29" || leapDay.Spending != toTotal(5000, "KRW") || leapDay.Count != 2 {
+		t.Fatalf("got leap day %+v", leapDay)
+	}
+	if report.Spending != toTotal(10000, "KRW") || report.Income != toTotal(50000, "KRW") || report.MaxDailySpending != toTotal(5000, "KRW") {
+		t.Fatalf("got spending %v, income %v, max %v", report.Spending, report.Income, report.MaxDailySpending)
+	}
+
+	// From monday of the default settings. The leap day is a thursday.
+	if report.Weekdays[0].Key != "monday" || report.Weekdays[6].Key != "sunday" {
+		t.Fatalf("got weekdays %+v", report.Weekdays)
+	}
+	if report.Weekdays[3].Spending != toTotal(5000, "KRW") || report.Weekdays[3].Count != 2 || report.Weekdays[6].Spending != toTotal(1000, "KRW") || report.Weekdays[1].Spending != toTotal(4000, "KRW") {
+		t.Fatalf("got weekdays %+v", report.Weekdays)
+	}
+	if report.Hours[0].Spending != toTotal(4000, "KRW") || report.Hours[10].Spending != toTotal(3000, "KRW") || report.Hours[23].Spending != toTotal(1000, "KRW") {
+		t.Fatalf("got hours %+v", report.Hours)
+	}
+	if report.NoTime.Spending != toTotal(2000, "KRW") || report.NoTime.Count != 1 {
+		t.Fatalf("got no time %+v", report.NoTime)
+	}
+
+	settings := defaultSettings()
+	settings.WeekStart = "sunday"
+	if err := updateSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	report, err = getCalendarReport(2024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Weekdays[0].Key != "sunday" || report.Weekdays[0].Spending != toTotal(1000, "KRW") || report.Weekdays[6].Key != "saturday" {
+		t.Fatalf("got weekdays of sunday %+v", report.Weekdays)
+	}
+}
+
+// The savings rate is null without income, never a division by zero
+func TestSavingsReportRate(t *testing.T) {
+	useTestLedger(t, newMemoryLedgerStore())
+
+	addTestRecords(t,
+		Record{Amount: 100000, Date: "2026-01-25", Category: "salary", TransactionType: "record_type_income"},
+		Record{Amount: 60000, Date: "2026-01-31"},
+		Record{Amount: 8000, Date: "2026-02-01"},
+		Record{Amount: 12000, Date: "2026-02-10", PayType: "credit"},
+	)
+
+	startDate := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	report, err := getSavingsReport(startDate, startDate.AddDate(0, 3, 0).Add(-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	percent := func(value float64) *float64 { return &value }
+	want := []struct {
+		label                 string
+		income, spending, net Money
+		rate                  *float64
+	}{
+		{"2026-01", 100000, 60000, 40000, percent(40)},
+		{"2026-02", 0, 20000, -20000, nil},
+		{"2026-03", 0, 0, 0, nil},
+	}
+	if len(report.Months) != len(want) {
+		t.Fatalf("got months %+v", report.Months)
+	}
+	for i, want := range want {
+		got := report.Months[i]
+		if got.Label != want.label || got.Income != toTotal(want.income, "KRW") || got.Spending != toTotal(want.spending, "KRW") || got.Net != toTotal(want.net, "KRW") {
+			t.Fatalf("month %d: got %+v, want %+v", i, got, want)
+		}
+		if (got.Rate == nil) != (want.rate == nil) || (got.Rate != nil && *got.Rate != *want.rate) {
+			t.Fatalf("month %s: got rate %v, want %v", got.Label, got.Rate, want.rate)
+		}
+	}
+	if report.Net != toTotal(20000, "KRW") || report.Rate == nil || *report.Rate != 20 {
+		t.Fatalf("got net %v, rate %v", report.Net, report.Rate)
+	}
+
+	// Spending without any income
+	report, err = getSavingsReport(startDate.AddDate(0, 1, 0), startDate.AddDate(0, 2, 0).Add(-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Income != 0 || report.Net != toTotal(-20000, "KRW") || report.Rate != nil {
+		t.Fatalf("got income %v, net %v, rate %v", report.Income, report.Net, report.Rate)
+	}
+}
+
+func TestIncomeStatsByCategory(t *testing.T) {
+	useTestLedger(t, newMemoryLedgerStore())
+
+	addTestRecords(t,
+		Record{Amount: 100000, Date: "2026-01-25", Category: "salary", TransactionType: "record_type_income"},
+		Record{Amount: 20000, Date: "2026-01-26", Category: "salary", TransactionType: "record_type_income"},
+		Record{Amount: 3000, Date: "2026-01-27", Category: "interest", TransactionType: "record_type_income"},
+		Record{Amount: 5000, Date: "2026-01-28", Category: "salary"},
+	)
+
+	startDate := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, stats, _, statsIncome, _, _, sumIncome, err := getRecords(nil, "", "", startDate, startDate.AddDate(0, 1, 0).Add(-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statsIncome) != 2 || statsIncome["salary"].Amount != toTotal(120000, "KRW") || statsIncome["interest"].Amount != toTotal(3000, "KRW") {
+		t.Fatalf("got income stats %+v", statsIncome)
+	}
+	if sumIncome != toTotal(123000, "KRW") || stats["salary"].Amount != toTotal(5000, "KRW") {
+		t.Fatalf("got income %v, pay stats %+v", sumIncome, stats)
+	}
+}
@@ -0,0 +1,231 @@
+package server
+
+import (
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const ruleMetaKey = "rules"
+
+// Rule with its description regex compiled
+type compiledRule struct {
+	RecordRule
+	regex    *regexp.Regexp
+	currency string // Of the amounts
+	payType  string // Of the records moved to SetAccountID
+}
+
+// getRecordRules returns the categorization rules in the order of applying, kept as one document like the settings
+func getRecordRules() ([]RecordRule, error) {
+	results := []RecordRule{}
+
+	value, err := ledger.GetMeta(ruleMetaKey)
+	if err != nil || value == "" {
+		return results, err
+	}
+
+	err = json.Unmarshal([]byte(value), &results)
+	return results, err
+}
+
+// updateRecordRules replaces all rules. Amounts without a currency are in the base currency of the settings.
+func updateRecordRules(rules []RecordRule) error {
+	settings, err := getSettings()
+	if err != nil {
+		return err
+	}
+
+	rules = slices.Clone(rules)
+	for i, rule := range rules {
+		if (rule.AmountMin != "" || rule.AmountMax != "") && rule.Currency == "" {
+			rules[i].Currency = settings.BaseCurrency
+		}
+		if err := validateRecordRule(i, rules[i]); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return err
+	}
+
+	return ledger.SetMeta(ruleMetaKey, string(data))
+}
+
+func loadRecordRules() ([]compiledRule, error) {
+	rules, err := getRecordRules()
+	if err != nil {
+		return nil, err
+	}
+	settings, err := getSettings()
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := getAccountListMAP()
+	if err != nil {
+		return nil, err
+	}
+
+	results := []compiledRule{}
+	for _, rule := range rules {
+		compiled := compiledRule{RecordRule: rule, currency: rule.Currency}
+		if compiled.currency == "" {
+			compiled.currency = settings.BaseCurrency
+		}
+		if account, exist := accounts[rule.SetAccountID]; exist {
+			compiled.payType = getRecordPayType(account)
+		}
+		if rule.DescriptionRegex != "" {
+			compiled.regex, err = regexp.Compile(rule.DescriptionRegex)
+			if err != nil {
+				return nil, err
+			}
+		}
+		results = append(results, compiled)
+	}
+
+	return results, nil
+}
+
+// matchRecordRule tells whether record meets all conditions of rule
+func matchRecordRule(rule compiledRule, record Record) bool {
+	if rule.AccountID != "" && rule.AccountID != record.AccountID {
+		return false
+	}
+	if rule.DescriptionContains != "" && !strings.Contains(strings.ToLower(record.Description), strings.ToLower(rule.DescriptionContains)) {
+		return false
+	}
+	if rule.regex != nil && !rule.regex.MatchString(record.Description) {
+		return false
+	}
+
+	// Amounts are in the currency of the rule, records of other currencies do not match them
+	if (rule.AmountMin != "" || rule.AmountMax != "") && record.Currency != rule.currency {
+		return false
+	}
+	if rule.AmountMin != "" {
+		amount, err := parseMoney(rule.AmountMin, rule.currency)
+		if err != nil || record.Amount < amount {
+			return false
+		}
+	}
+	if rule.AmountMax != "" {
+		amount, err := parseMoney(rule.AmountMax, rule.currency)
+		if err != nil || record.Amount > amount {
+			return false
+		}
+	}
+
+	if rule.TimeFrom != "" || rule.TimeTo != "" {
+		from, to := rule.TimeFrom, rule.TimeTo
+		if from == "" {
+			from = "00:00"
+		}
+		if to == "" {
+			to = "23:59"
+		}
+
+		// HH:MM compares as text. Over midnight, e.g. 22:00 to 02:00
+		if record.Time == "" {
+			return false
+		}
+		if from <= to && (record.Time < from || record.Time > to) {
+			return false
+		}
+		if from > to && record.Time < from && record.Time > to {
+			return false
+		}
+	}
+
+	return true
+}
+
+// applyRecordRules fills the category and account of record by the first matching rule, and adds the tags of all matching rules.
+// The pay type follows the account. With overwrite, the category and account are replaced too. Returns the names of the matched rules.
+func applyRecordRules(record *Record, rules []compiledRule, overwrite bool) []string {
+	matched := []string{}
+	categorySet, accountSet := false, false
+
+	for _, rule := range rules {
+		if !matchRecordRule(rule, *record) {
+			continue
+		}
+		matched = append(matched, rule.Name)
+
+		if rule.Category != "" && !categorySet && (overwrite || record.Category == "") {
+			record.Category = rule.Category
+			categorySet = true
+		}
+		if rule.SetAccountID != "" && !accountSet && (overwrite || record.AccountID == "") {
+			record.AccountID = rule.SetAccountID
+			if rule.payType != "" {
+				record.PayType = rule.payType
+			}
+			accountSet = true
+		}
+		for _, tag := range rule.Tags {
+			if !slices.Contains(record.Tags, tag) {
+				record.Tags = append(record.Tags, tag)
+			}
+		}
+	}
+
+	return matched
+}
+
+// applyRulesToRecords recategorizes the records between startDate and endDate. With dryRun, only the changes are reported.
+// Without overwrite, only blank categories and accounts are filled - like new records - and tags are added.
+func applyRulesToRecords(startDate, endDate time.Time, dryRun, overwrite bool) (RuleApplyResult, error) {
+	rules, err := loadRecordRules()
+	if err != nil {
+		return RuleApplyResult{}, err
+	}
+
+	records, err := ledger.ListRecords("", startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
+	if err != nil {
+		return RuleApplyResult{}, err
+	}
+
+	result := RuleApplyResult{
+		From:      startDate.Format("2006-01-02"),
+		To:        endDate.Format("2006-01-02"),
+		DryRun:    dryRun,
+		Overwrite: overwrite,
+		Checked:   len(records),
+		Changes:   []RuleChange{},
+	}
+	for _, record := range records {
+		updated := record
+		updated.Tags = slices.Clone(record.Tags)
+
+		names := applyRecordRules(&updated, rules, overwrite)
+		if updated.Category == record.Category && updated.AccountID == record.AccountID && updated.PayType == record.PayType && len(updated.Tags) == len(record.Tags) {
+			continue
+		}
+
+		result.Changes = append(result.Changes, RuleChange{
+			RecordID:     record.ID,
+			Date:         record.Date,
+			Description:  record.Description,
+			Rules:        names,
+			Category:     record.Category,
+			NewCategory:  updated.Category,
+			AccountID:    record.AccountID,
+			NewAccountID: updated.AccountID,
+			Tags:         record.Tags,
+			NewTags:      updated.Tags,
+		})
+
+		if !dryRun {
+			if err := updateRecord(record.ID, updated); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// Without overwrite, rules only fill blank categories, like new records
+func TestApplyRulesToRecordsOverwrite(t *testing.T) {
+	useTestLedger(t, newMemoryLedgerStore())
+
+	for _, record := range []Record{
+		{ID: "record:1", Category: "dining", Description: "lunch with team"},
+		{ID: "record:2", Category: "", Description: "lunch alone"},
+	} {
+		record.TransactionType, record.PayType, record.Currency, record.Amount, record.Date = "record_type_pay", "direct", "KRW", 9000, "2026-04-01"
+		if err := ledger.AddRecord(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := updateRecordRules([]RecordRule{{Name: "lunch", DescriptionContains: "lunch", Category: "meal"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	startDate, endDate := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 12, 31, 23, 59, 59, 0, time.UTC)
+	categories := func() (string, string) {
+		t.Helper()
+		records, err := ledger.GetRecords([]string{"record:1", "record:2"})
+		if err != nil || len(records) != 2 {
+			t.Fatalf("got %+v, %v", records, err)
+		}
+		return records[0].Category, records[1].Category
+	}
+
+	result, err := applyRulesToRecords(startDate, endDate, false, false)
+	if err != nil || result.Overwrite || len(result.Changes) != 1 || result.Changes[0].RecordID != "record:2" {
+		t.Fatalf("got %+v, %v", result, err)
+	}
+	if first, second := categories(); first != "dining" || second != "meal" {
+		t.Fatalf("without overwrite: got %s, %s", first, second)
+	}
+
+	result, err = applyRulesToRecords(startDate, endDate, false, true)
+	if err != nil || !result.Overwrite || len(result.Changes) != 1 || result.Changes[0].RecordID != "record:1" {
+		t.Fatalf("got %+v, %v", result, err)
+	}
+	if first, second := categories(); first != "meal" || second != "meal" {
+		t.Fatalf("with overwrite: got %s, %s", first, second)
+	}
+}
+
+func TestApplyRulesHandlerFlags(t *testing.T) {
+	server := newTestServer(t)
+	if status, _ := doTestRequest(t, server, "GET", apiPrefix+"/setup/db?password=test", nil); status != http.StatusOK {
+		t.Fatalf("unlock: got %d", status)
+	}
+
+	status, body := doTestRequest(t, server, "POST", apiPrefix+"/rules/apply?overwrite=maybe", nil)
+	if status != http.StatusBadRequest || body.(map[string]interface{})["field"] != "overwrite" {
+		t.Fatalf("got %d %v", status, body)
+	}
+	status, body = doTestRequest(t, server, "POST", apiPrefix+"/rules/apply?dry-run=true", nil)
+	if status != http.StatusOK || body.(map[string]interface{})["overwrite"] != false {
+		t.Fatalf("got %d %v", status, body)
+	}
+}
+
+// The pay type of a record follows the account set by a rule
+func TestApplyRulesSetAccountPayType(t *testing.T) {
+	useTestLedger(t, newMemoryLedgerStore())
+
+	accounts := []Account{
+		{ID: "account:wallet", AccountName: "wallet", PayType: "direct"},
+		{ID: "account:card", AccountName: "card", PayType: "credit", RepayDay: "14", UseDayFrom: "1", UseDayTo: "말일"},
+		{ID: "account:revolving", AccountName: "revolving", PayType: "hybrid"},
+	}
+	for _, account := range accounts {
+		if err := ledger.AddAccount(account); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := updateRecordRules([]RecordRule{
+		{Name: "taxi on the card", DescriptionContains: "taxi", SetAccountID: "account:card"},
+		{Name: "rent from the wallet", DescriptionContains: "rent", SetAccountID: "account:wallet"},
+		{Name: "revolving", DescriptionContains: "loan", SetAccountID: "account:revolving"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := loadRecordRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		record  Record
+		account string
+		payType string
+	}{
+		{Record{Description: "taxi", PayType: "direct"}, "account:card", "credit"},
+		{Record{Description: "rent", AccountID: "account:card", PayType: "credit"}, "account:wallet", "direct"},
+		{Record{Description: "loan", PayType: "credit"}, "account:revolving", "direct"},
+		{Record{Description: "lunch", PayType: "credit"}, "", "credit"},
+	}
+	for _, test := range tests {
+		record := test.record
+		applyRecordRules(&record, rules, true)
+		if record.AccountID != test.account || record.PayType != test.payType {
+			t.Fatalf("%s: got %s %s, want %s %s", record.Description, record.AccountID, record.PayType, test.account, test.payType)
+		}
+	}
+
+	// A record moved to the card by the apply is charged on it
+	addTestRecords(t, Record{ID: "record:taxi", Amount: 12000, Date: "2026-04-01", Description: "taxi home"})
+	startDate := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := applyRulesToRecords(startDate, startDate.AddDate(0, 1, 0), false, false); err != nil {
+		t.Fatal(err)
+	}
+	record, err := ledger.GetRecord("record:taxi")
+	if err != nil || record.AccountID != "account:card" || record.PayType != "credit" {
+		t.Fatalf("got %+v, %v", record, err)
+	}
+}
+
+// Amounts of a rule are in its currency, the base currency by default
+func TestRecordRuleAmountCurrency(t *testing.T) {
+	useTestLedger(t, newMemoryLedgerStore())
+
+	assertErrorCode(t, updateRecordRules([]RecordRule{{Name: "cents of KRW", AmountMin: "10.5", Category: "snack"}}), errorCodeValidation)
+	assertErrorCode(t, updateRecordRules([]RecordRule{{Name: "cents of JPY", AmountMax: "100.5", Currency: "JPY", Category: "snack"}}), errorCodeValidation)
+	assertErrorCode(t, updateRecordRules([]RecordRule{{Name: "unknown", AmountMax: "100", Currency: "XYZ", Category: "snack"}}), errorCodeValidation)
+
+	if err := updateRecordRules([]RecordRule{
+		{Name: "small KRW", AmountMax: "5000", Category: "snack"},
+		{Name: "small USD", AmountMin: "0.5", AmountMax: "10.5", Currency: "USD", Category: "app"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := getRecordRules()
+	if err != nil || stored[0].Currency != "KRW" || stored[1].Currency != "USD" {
+		t.Fatalf("got %+v, %v", stored, err)
+	}
+	rules, err := loadRecordRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		currency string
+		amount   Money
+		want     string
+	}{
+		{"KRW", 5000, "snack"},
+		{"KRW", 5001, ""},
+		{"USD", 1050, "app"},
+		{"USD", 49, ""},
+		{"USD", 1051, ""},
+		{"JPY", 100, ""}, // Amounts of another currency
+	}
+	for _, test := range tests {
+		record := Record{Currency: test.currency, Amount: test.amount}
+		applyRecordRules(&record, rules, false)
+		if record.Category != test.want {
+			t.Fatalf("%d %s: got %q, want %q", test.amount, test.currency, record.Category, test.want)
+		}
+	}
+}
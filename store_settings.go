@@ -0,0 +1,92 @@
+package server
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+)
+
+const settingsMetaKey = "settings"
+
+func defaultSettings() Settings {
+	return Settings{
+		BaseCurrency:     "KRW",
+		FiscalMonthStart: 1,
+		WeekStart:        "monday",
+		ExchangeRates:    map[string]float64{"USD": ExchangeRate},
+	}
+}
+
+// getSettings returns the stored settings, or the defaults before the first save
+func getSettings() (Settings, error) {
+	value, err := ledger.GetMeta(settingsMetaKey)
+	if err != nil || value == "" {
+		return defaultSettings(), err
+	}
+
+	settings := defaultSettings()
+	settings.ExchangeRates = nil
+	if err := json.Unmarshal([]byte(value), &settings); err != nil {
+		return defaultSettings(), err
+	}
+
+	return settings, nil
+}
+
+func updateSettings(settings Settings) error {
+	err := validateSettings(settings)
+	if err != nil {
+		return err
+	}
+
+	settings.RegDTTM = time.Now().Format("20060102150405")
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+
+	err = ledger.SetMeta(settingsMetaKey, string(data))
+	invalidateHolidayCache()
+	return err
+}
+
+// applyRecordDefaults fills currency of a new record from the settings, then account and category by the rules or the settings
+func applyRecordDefaults(record *Record, settings Settings, rules []compiledRule) {
+	if record.Currency == "" {
+		record.Currency = settings.BaseCurrency
+
+		// The decimal was read before the currency is known
+		if record.amountText != "" {
+			record.Amount, record.amountErr = parseMoney(record.amountText, record.Currency)
+		}
+	}
+	applyRecordRules(record, rules, false)
+	if record.AccountID == "" {
+		record.AccountID = settings.DefaultAccountID
+	}
+	if record.Category == "" {
+		record.Category = settings.DefaultCategory
+	}
+}
+
+// toBaseTotal converts amount of currency to the base currency of the settings.
+// A currency without an exchange rate is summed by face value.
+func toBaseTotal(amount Money, currency string, settings Settings) Total {
+	rate, exist := settings.ExchangeRates[currency]
+	if currency == settings.BaseCurrency || !exist {
+		return toTotal(amount, currency)
+	}
+
+	return Total(math.Round(float64(toTotal(amount, currency)) * rate))
+}
+
+// getFiscalMonth returns the report month of date, starting on the FiscalMonthStart day
+func getFiscalMonth(settings Settings, date time.Time) (time.Time, time.Time) {
+	start := time.Date(date.Year(), date.Month(), settings.FiscalMonthStart, 0, 0, 0, 0, date.Location())
+	if date.Day() < settings.FiscalMonthStart {
+		start = start.AddDate(0, -1, 0)
+	}
+	end := start.AddDate(0, 1, 0).Add(-time.Second)
+
+	return start, end
+}
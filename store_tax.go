@@ -0,0 +1,183 @@
+package server
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// Classes of 연말정산 card deduction, in the order filling the threshold - lowest rate first
+var deductionClasses = []string{"credit", "debit", "cash-receipt", "culture", "market", "transport"}
+
+// Deduction rates of the classes
+var deductionRates = map[string]float64{
+	"credit":       0.15,
+	"debit":        0.30, // Check and debit cards
+	"cash-receipt": 0.30,
+	"culture":      0.30, // Books, performances, museums. Salary up to 70M only
+	"market":       0.40, // Traditional markets
+	"transport":    0.40, // Public transport
+}
+
+// Classes of the additional limit over the basic limit
+var additionalDeductionClasses = []string{"culture", "market", "transport"}
+
+// Salary over this has lower limits and no culture class
+const taxHighSalary Total = 70_000_000 * 100
+
+// isDeductionClass tells whether class is a deduction class, none or empty
+func isDeductionClass(class string) bool {
+	return class == "" || class == "none" || slices.Contains(deductionClasses, class)
+}
+
+// getDeductionClass returns the class of a pay record: the class of the record, of its account, or by the pay type.
+// Only KRW spending is deducted. Cash is deducted only with a cash receipt, so a direct account is debit
+// only when it is linked to a bank - the repay account of a card. Other direct accounts need their class set.
+func getDeductionClass(record Record, accounts map[string]Account) string {
+	if record.Currency != "KRW" {
+		return "none"
+	}
+	if record.DeductionClass != "" {
+		return record.DeductionClass
+	}
+
+	account, exist := accounts[record.AccountID]
+	if account.DeductionClass != "" {
+		return account.DeductionClass
+	}
+
+	payType := record.PayType
+	if exist {
+		payType = account.PayType
+	}
+	switch payType {
+	case "credit", "hybrid":
+		return "credit"
+	case "direct":
+		if exist && isRepayAccount(account.ID, accounts) {
+			return "debit"
+		}
+	}
+	return "none"
+}
+
+// isRepayAccount tells whether a credit account is repaid from the account of accountID
+func isRepayAccount(accountID string, accounts map[string]Account) bool {
+	for _, account := range accounts {
+		if account.PayType != "direct" && account.RepayAccountID == accountID {
+			return true
+		}
+	}
+	return false
+}
+
+// getTaxDeductionLimits returns the basic and the additional limit of salary
+func getTaxDeductionLimits(salary Total) (Total, Total) {
+	if salary > taxHighSalary {
+		return 2_500_000 * 100, 2_000_000 * 100
+	}
+	return 3_000_000 * 100, 3_000_000 * 100
+}
+
+// calculateTaxDeduction fills the threshold from the lowest rate, and limits the deduction.
+// Returns the deduction, the part over the basic limit and the classes.
+func calculateTaxDeduction(spending map[string]Total, salary Total) (Total, Total, []TaxDeductionClass) {
+	basicLimit, additionalLimit := getTaxDeductionLimits(salary)
+	threshold := Total(math.Round(float64(salary) * 0.25))
+
+	var total, additional Total
+	classes := []TaxDeductionClass{}
+	for _, class := range deductionClasses {
+		item := TaxDeductionClass{Class: class, Spending: spending[class], Rate: deductionRates[class]}
+
+		item.Threshold = min(item.Spending, threshold)
+		threshold -= item.Threshold
+		item.Deduction = Total(math.Round(float64(item.Spending-item.Threshold) * item.Rate))
+
+		total += item.Deduction
+		if slices.Contains(additionalDeductionClasses, class) {
+			additional += item.Deduction
+		}
+		classes = append(classes, item)
+	}
+
+	if total <= basicLimit {
+		return total, 0, classes
+	}
+	over := min(total-basicLimit, additional, additionalLimit)
+	return basicLimit + over, over, classes
+}
+
+// getTaxDeductionReport estimates the card deduction of the pay records of year
+func getTaxDeductionReport(year int, salary Total) (TaxDeductionReport, error) {
+	accounts, err := getAccountListMAP()
+	if err != nil {
+		return TaxDeductionReport{}, err
+	}
+
+	startDate := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	records, err := ledger.ListRecords("", startDate.Format("2006-01-02"), startDate.AddDate(1, 0, -1).Format("2006-01-02"))
+	if err != nil {
+		return TaxDeductionReport{}, err
+	}
+
+	report := TaxDeductionReport{Year: year, Salary: salary, Threshold: Total(math.Round(float64(salary) * 0.25)), Foreign: map[string]Total{}, Suggestions: []TaxDeductionSuggestion{}}
+	report.BasicLimit, report.AdditionalLimit = getTaxDeductionLimits(salary)
+
+	spending := map[string]Total{}
+	for _, record := range records {
+		if record.TransactionType != "record_type_pay" || (record.PayType != "direct" && record.PayType != "credit") {
+			continue
+		}
+
+		// Only KRW spending is deducted. Foreign spending is kept by currency, not added to the KRW totals.
+		amount := toTotal(record.Amount, record.Currency)
+		if record.Currency != "KRW" {
+			report.Foreign[record.Currency] += amount
+			continue
+		}
+		class := getDeductionClass(record, accounts)
+
+		// Culture is a card or cash spending over the salary of 70M
+		if class == "culture" && salary > taxHighSalary {
+			class = "credit"
+		}
+		if class == "none" {
+			report.Ineligible += amount
+			continue
+		}
+		spending[class] += amount
+		report.Spending += amount
+	}
+
+	deduction, over, classes := calculateTaxDeduction(spending, salary)
+	report.Deduction = deduction
+	report.Classes = classes
+
+	// Under the threshold nothing is deducted - any payment type fills it, credit cards often with more benefits
+	if report.Spending < report.Threshold {
+		report.Suggestions = append(report.Suggestions, TaxDeductionSuggestion{
+			Class: "credit", Spending: report.Threshold - report.Spending,
+			Reason: "Spending under the threshold(25% of the salary) is not deducted",
+		})
+	}
+
+	// Debit cards and cash receipts up to the basic limit
+	basicRoom := report.BasicLimit - min(deduction, report.BasicLimit)
+	if basicRoom > 0 {
+		report.Suggestions = append(report.Suggestions, TaxDeductionSuggestion{
+			Class: "debit", Spending: Total(math.Ceil(float64(basicRoom) / deductionRates["debit"])), Deduction: basicRoom,
+			Reason: "Debit cards and cash receipts are deducted at 30% until the basic limit",
+		})
+	}
+
+	// Or traditional markets and public transport, up to the basic and the additional limit
+	if room := basicRoom + report.AdditionalLimit - over; room > 0 {
+		report.Suggestions = append(report.Suggestions, TaxDeductionSuggestion{
+			Class: "market", Spending: Total(math.Ceil(float64(room) / deductionRates["market"])), Deduction: room,
+			Reason: "Traditional markets and public transport are deducted at 40%, with an additional limit over the basic limit",
+		})
+	}
+
+	return report, nil
+}
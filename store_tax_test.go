@@ -0,0 +1,74 @@
+package server
+
+import (
+	"testing"
+)
+
+// Foreign spending is reported by currency at face value, not as KRW
+func TestTaxDeductionReportForeignSpending(t *testing.T) {
+	useTestLedger(t, newMemoryLedgerStore())
+
+	records := []Record{
+		{ID: "record:1", Currency: "KRW", Amount: 50000, PayType: "credit"},
+		{ID: "record:2", Currency: "KRW", Amount: 20000, PayType: "credit", DeductionClass: "none"},
+		{ID: "record:3", Currency: "USD", Amount: 1250, PayType: "credit"},
+		{ID: "record:4", Currency: "USD", Amount: 750, PayType: "direct"},
+		{ID: "record:5", Currency: "JPY", Amount: 3000, PayType: "credit"},
+	}
+	for _, record := range records {
+		record.TransactionType, record.Category, record.Date = "record_type_pay", "food", "2026-05-01"
+		if err := ledger.AddRecord(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := getTaxDeductionReport(2026, toTotal(40_000_000, "KRW"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Spending != toTotal(50000, "KRW") {
+		t.Fatalf("spending: got %v", report.Spending)
+	}
+	if report.Ineligible != toTotal(20000, "KRW") {
+		t.Fatalf("ineligible: got %v", report.Ineligible)
+	}
+	if len(report.Foreign) != 2 || report.Foreign["USD"] != toTotal(2000, "USD") || report.Foreign["JPY"] != toTotal(3000, "JPY") {
+		t.Fatalf("foreign: got %v", report.Foreign)
+	}
+}
+
+// Cash is deducted only with a cash receipt. A direct account is debit when a card is repaid from it.
+func TestDeductionClassOfCash(t *testing.T) {
+	accounts := map[string]Account{
+		"account:wallet":  {ID: "account:wallet", PayType: "direct"},
+		"account:receipt": {ID: "account:receipt", PayType: "direct", DeductionClass: "cash-receipt"},
+		"account:bank":    {ID: "account:bank", PayType: "direct"},
+		"account:check":   {ID: "account:check", PayType: "direct", DeductionClass: "debit"},
+		"account:card":    {ID: "account:card", PayType: "credit", RepayAccountID: "account:bank"},
+	}
+
+	tests := []struct {
+		name   string
+		record Record
+		want   string
+	}{
+		{"cash", Record{AccountID: "account:wallet", PayType: "direct"}, "none"},
+		{"cash with a receipt", Record{AccountID: "account:wallet", PayType: "direct", DeductionClass: "cash-receipt"}, "cash-receipt"},
+		{"cash account of receipts", Record{AccountID: "account:receipt", PayType: "direct"}, "cash-receipt"},
+		{"bank repaying a card", Record{AccountID: "account:bank", PayType: "direct"}, "debit"},
+		{"check card", Record{AccountID: "account:check", PayType: "direct"}, "debit"},
+		{"credit card", Record{AccountID: "account:card", PayType: "credit"}, "credit"},
+		{"without an account", Record{PayType: "direct"}, "none"},
+		{"foreign cash with a receipt", Record{AccountID: "account:wallet", PayType: "direct", Currency: "USD", DeductionClass: "cash-receipt"}, "none"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.record.Currency == "" {
+				test.record.Currency = "KRW"
+			}
+			if got := getDeductionClass(test.record, accounts); got != test.want {
+				t.Fatalf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
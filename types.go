@@ -9,7 +9,25 @@ type Account struct {
 	UseDayFrom  string `json:"use-day-from,omitempty"`
 	UseDayTo    string `json:"use-day-to,omitempty"`
 	Description string `json:"description,omitempty"`
-	RegDTTM     string
+
+	OpeningBalance string `json:"opening-balance,omitempty"`  // Direct account - decimal in the base currency, before all records
+	RepayAccountID string `json:"repay-account-id,omitempty"` // Credit account - the direct account paying the bills
+	DeductionClass string `json:"deduction-class,omitempty"`  // 연말정산 class of the spending, by PayType and the repay link when empty
+
+	// Credit account - benefits given by the spending of the previous use period(전월실적)
+	RequiredSpending   string        `json:"required-spending,omitempty"`   // Decimal in the base currency
+	ExcludedCategories []string      `json:"excluded-categories,omitempty"` // Not counted in the spending and without benefits
+	Benefits           []CardBenefit `json:"benefits,omitempty"`
+	RegDTTM            string
+}
+
+// Discount or cashback of a card for a category
+type CardBenefit struct {
+	Name       string  `json:"name,omitempty"`
+	Category   string  `json:"category,omitempty"`    // All categories when empty
+	Kind       string  `json:"kind"`                  // discount, cashback
+	Rate       float64 `json:"rate"`                  // Percent of the spending
+	MonthlyCap string  `json:"monthly-cap,omitempty"` // Decimal in the base currency, no cap when empty
 }
 
 // Payment category - meals, snack, bus/tube/taxi, etc.
@@ -21,21 +39,341 @@ type Category struct {
 
 // Paymenr record
 type Record struct {
-	ID              string  `json:"id"`
-	TransactionType string  `json:"transaction-type"` // payment(record_type_pay), income(record_type_income)
-	AccountID       string  `json:"account-id"`
-	PayType         string  `json:"pay-type"` // direct, credit
-	Currency        string  `json:"currency"`
-	Amount          float64 `json:"amount"`
-	Category        string  `json:"category"`
-	Description     string  `json:"description,omitempty"`
-	Date            string  `json:"date"`
-	Time            string  `json:"time"`
+	ID              string   `json:"id"`
+	TransactionType string   `json:"transaction-type"` // payment(record_type_pay), income(record_type_income)
+	AccountID       string   `json:"account-id"`
+	PayType         string   `json:"pay-type"`     // direct, credit
+	Currency        string   `json:"currency"`     // ISO 4217 code - KRW, USD
+	Amount          Money    `json:"amount-minor"` // In the minor unit of Currency. "amount" decimal in the API.
+	Category        string   `json:"category"`
+	Description     string   `json:"description,omitempty"`
+	Date            string   `json:"date"`
+	Time            string   `json:"time"`
+	DeductionClass  string   `json:"deduction-class,omitempty"` // Overrides the class of the account for 연말정산
+	Tags            []string `json:"tags,omitempty"`
 	RegDTTM         string
+
+	amountText string // Decimal of the JSON, parsed again when Currency is set by the settings
+	amountErr  error  // Set by UnmarshalJSON when the amount does not fit Currency
 }
 
 // Stat of records
 type Stat struct {
-	Category string  `json:"category"`
-	Amount   float64 `json:"amount"`
+	Category string `json:"category"`
+	Amount   Total  `json:"amount"`
+}
+
+// User settings - defaults of new records and reports
+type Settings struct {
+	BaseCurrency     string             `json:"base-currency"`                // Totals of reports are in this currency
+	DefaultAccountID string             `json:"default-account-id,omitempty"` // Account of a new record without one
+	DefaultCategory  string             `json:"default-category,omitempty"`   // Category of a new record without one
+	FiscalMonthStart int                `json:"fiscal-month-start"`           // 1-28, the day a report month starts
+	WeekStart        string             `json:"week-start"`                   // monday, sunday
+	ExchangeRates    map[string]float64 `json:"exchange-rates"`               // BaseCurrency per 1 unit of the currency
+	Holidays         []string           `json:"holidays,omitempty"`           // YYYY-MM-DD, more days without card repayment
+	RegDTTM          string
+}
+
+// Totals of a period in a trend report
+type TrendBucket struct {
+	Label     string `json:"label"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Pay       Total  `json:"pay"`
+	CreditPay Total  `json:"credit-pay"`
+	Income    Total  `json:"income"`
+}
+
+// Totals of a group by the buckets of a trend report - one dataset of a chart
+type TrendSeries struct {
+	Key       string  `json:"key"`
+	Label     string  `json:"label"`
+	Pay       []Total `json:"pay"`
+	CreditPay []Total `json:"credit-pay"`
+	Income    []Total `json:"income"`
+}
+
+type TrendReport struct {
+	Granularity string        `json:"granularity"` // day, week, month, year
+	GroupBy     string        `json:"group-by"`    // category, account, type
+	Currency    string        `json:"currency"`    // Base currency of the totals
+	Labels      []string      `json:"labels"`
+	Buckets     []TrendBucket `json:"buckets"`
+	Series      []TrendSeries `json:"series"`
+}
+
+// Change of a category or account between the periods of a compare report
+type CompareDelta struct {
+	Key        string   `json:"key"`
+	Label      string   `json:"label"`
+	Current    Total    `json:"current"`
+	Previous   Total    `json:"previous"`
+	Change     Total    `json:"change"`
+	Percent    *float64 `json:"percent"` // null when Previous is 0
+	Status     string   `json:"status"`  // new, disappeared, increased, decreased, unchanged
+	TopRecords []Record `json:"top-records"`
+}
+
+type ComparePeriod struct {
+	Label        string `json:"label"`
+	From         string `json:"from"`
+	To           string `json:"to"`
+	SumPay       Total  `json:"sum-pay"`
+	SumCreditPay Total  `json:"sum-credit-pay"`
+	SumIncome    Total  `json:"sum-income"`
+}
+
+type CompareReport struct {
+	Against               string         `json:"against"`  // previous, last-year
+	Currency              string         `json:"currency"` // Base currency of the totals
+	Current               ComparePeriod  `json:"current"`
+	Previous              ComparePeriod  `json:"previous"`
+	Categories            []CompareDelta `json:"categories"`
+	Accounts              []CompareDelta `json:"accounts"`
+	NewCategories         []string       `json:"new-categories"`
+	DisappearedCategories []string       `json:"disappeared-categories"`
+}
+
+// Next repayment of a credit account, of the records in its use period
+type DashboardRepayment struct {
+	AccountID   string `json:"account-id"`
+	AccountName string `json:"account-name"`
+	RepayDate   string `json:"repay-date"`
+	UseDateFrom string `json:"use-date-from"`
+	UseDateTo   string `json:"use-date-to"`
+	Amount      Total  `json:"amount"`
+	Count       int    `json:"count"`
+}
+
+// Total of a category or a merchant(description) in a dashboard
+type DashboardItem struct {
+	Key    string `json:"key"`
+	Amount Total  `json:"amount"`
+	Count  int    `json:"count"`
+}
+
+type Dashboard struct {
+	Currency         string               `json:"currency"` // Base currency of the totals
+	Current          ComparePeriod        `json:"current"`  // This month of the settings
+	Previous         ComparePeriod        `json:"previous"` // Last month
+	Spending         Total                `json:"spending"` // Paid and not yet repaid of this month
+	PreviousSpending Total                `json:"previous-spending"`
+	SpendingChange   Total                `json:"spending-change"`
+	SpendingPercent  *float64             `json:"spending-percent"` // null when last month is 0
+	Income           Total                `json:"income"`
+	Net              Total                `json:"net"` // Income - spending
+	Repayments       []DashboardRepayment `json:"repayments"`
+	TopCategories    []DashboardItem      `json:"top-categories"`
+	TopMerchants     []DashboardItem      `json:"top-merchants"`
+}
+
+// Totals of a day in a calendar report. Count is of all records of the day.
+type CalendarDay struct {
+	Date     string `json:"date"`
+	Spending Total  `json:"spending"`
+	Income   Total  `json:"income"`
+	Count    int    `json:"count"`
+}
+
+// Spending by a weekday or an hour of day in a calendar report
+type CalendarBucket struct {
+	Key      string `json:"key"` // monday..sunday, 00..23
+	Spending Total  `json:"spending"`
+	Count    int    `json:"count"`
+}
+
+type CalendarReport struct {
+	Year             int              `json:"year"`
+	Currency         string           `json:"currency"` // Base currency of the totals
+	Spending         Total            `json:"spending"`
+	Income           Total            `json:"income"`
+	MaxDailySpending Total            `json:"max-daily-spending"` // Scale of a heatmap
+	Days             []CalendarDay    `json:"days"`               // Every day of the year
+	Weekdays         []CalendarBucket `json:"weekdays"`           // From the week start of the settings
+	Hours            []CalendarBucket `json:"hours"`
+	NoTime           CalendarBucket   `json:"no-time"` // Spending of the records without a time
+}
+
+// Savings of a month. Rate is net in percent of income, null without income.
+type SavingsMonth struct {
+	Label    string   `json:"label"`
+	From     string   `json:"from"`
+	To       string   `json:"to"`
+	Income   Total    `json:"income"`
+	Spending Total    `json:"spending"`
+	Net      Total    `json:"net"`
+	Rate     *float64 `json:"rate"`
+}
+
+type SavingsReport struct {
+	Currency string         `json:"currency"` // Base currency of the totals
+	Months   []SavingsMonth `json:"months"`
+	Income   Total          `json:"income"`
+	Spending Total          `json:"spending"`
+	Net      Total          `json:"net"`
+	Rate     *float64       `json:"rate"`
+}
+
+// Expense or income repeating every month, or every year in Month
+type RecurringItem struct {
+	Name            string `json:"name"`
+	TransactionType string `json:"transaction-type"` // record_type_pay, record_type_income
+	AccountID       string `json:"account-id"`
+	Category        string `json:"category"`
+	Currency        string `json:"currency"`
+	Amount          string `json:"amount"` // Decimal in Currency
+	Day             int    `json:"day"`    // 1-31, the last day for shorter months
+	Month           int    `json:"month"`  // 0 for every month, 1-12 for every year
+	StartDate       string `json:"start-date,omitempty"`
+	EndDate         string `json:"end-date,omitempty"`
+}
+
+// Known cash movement of a forecast. Amount is negative out of the account.
+type ForecastEvent struct {
+	Date        string `json:"date"`
+	AccountID   string `json:"account-id"` // Direct account of the cash, empty for records without one
+	Kind        string `json:"kind"`       // credit-repay, recurring, scheduled
+	Description string `json:"description"`
+	Amount      Total  `json:"amount"`
+}
+
+type ForecastDay struct {
+	Date    string `json:"date"`
+	Change  Total  `json:"change"`
+	Balance Total  `json:"balance"`
+}
+
+// Projected balance of a direct account. The balance of today is the opening balance with all records until today.
+type ForecastAccount struct {
+	AccountID     string        `json:"account-id"`
+	AccountName   string        `json:"account-name"`
+	Balance       Total         `json:"balance"`
+	MinBalance    Total         `json:"min-balance"`
+	MinDate       string        `json:"min-date"`
+	NegativeDates []string      `json:"negative-dates"`
+	Days          []ForecastDay `json:"days"`
+}
+
+// Average spending of an account and a category, without the recurring items
+type ForecastVariable struct {
+	AccountID string `json:"account-id"`
+	Category  string `json:"category"`
+	Monthly   Total  `json:"monthly"`
+}
+
+type Forecast struct {
+	Currency      string             `json:"currency"` // Base currency of the totals
+	From          string             `json:"from"`
+	To            string             `json:"to"`
+	Accounts      []ForecastAccount  `json:"accounts"`
+	Events        []ForecastEvent    `json:"events"`
+	Variable      []ForecastVariable `json:"variable"`
+	NegativeDates []string           `json:"negative-dates"` // Dates with any account below 0
+	UnrepaidCards []string           `json:"unrepaid-cards"` // Credit accounts without a direct repay account, their charges are not in the balances
+}
+
+// Spending and deduction of a class in a tax deduction report
+type TaxDeductionClass struct {
+	Class     string  `json:"class"`
+	Spending  Total   `json:"spending"`
+	Rate      float64 `json:"rate"`
+	Threshold Total   `json:"threshold"` // Part of the threshold filled by the class, lowest rate first
+	Deduction Total   `json:"deduction"` // Before the limits
+}
+
+// More spending of a class to use up the threshold or a limit
+type TaxDeductionSuggestion struct {
+	Class     string `json:"class"`
+	Spending  Total  `json:"spending"`
+	Deduction Total  `json:"deduction"` // Estimated more deduction
+	Reason    string `json:"reason"`
+}
+
+// Estimated card deduction of 연말정산. Totals are in KRW.
+type TaxDeductionReport struct {
+	Year            int                      `json:"year"`
+	Salary          Total                    `json:"salary"`
+	Spending        Total                    `json:"spending"`  // Eligible spending of all classes
+	Threshold       Total                    `json:"threshold"` // 25% of the salary
+	BasicLimit      Total                    `json:"basic-limit"`
+	AdditionalLimit Total                    `json:"additional-limit"` // For traditional markets, public transport(and culture)
+	Deduction       Total                    `json:"deduction"`
+	Classes         []TaxDeductionClass      `json:"classes"`
+	Ineligible      Total                    `json:"ineligible"` // KRW spending of the class none
+	Foreign         map[string]Total         `json:"foreign"`    // Spending not in KRW by currency, never deducted
+	Suggestions     []TaxDeductionSuggestion `json:"suggestions"`
+}
+
+// Spending and benefit earned of a benefit rule in a use period
+type CardBenefitUsage struct {
+	Name      string  `json:"name,omitempty"`
+	Category  string  `json:"category,omitempty"`
+	Kind      string  `json:"kind"`
+	Rate      float64 `json:"rate"`
+	Spending  Total   `json:"spending"`
+	Earned    Total   `json:"earned"`
+	Cap       *Total  `json:"cap"`       // nil without a cap
+	Remaining *Total  `json:"remaining"` // Benefit left under the cap
+}
+
+// Benefits of a credit account for the bill of a month
+type CardBenefitReport struct {
+	Currency          string             `json:"currency"` // Base currency of the totals
+	AccountID         string             `json:"account-id"`
+	AccountName       string             `json:"account-name"`
+	Month             string             `json:"month"` // YYYY-MM of the repay date
+	RepayDate         string             `json:"repay-date"`
+	UseDateFrom       string             `json:"use-date-from"`
+	UseDateTo         string             `json:"use-date-to"`
+	PreviousDateFrom  string             `json:"previous-date-from"`
+	PreviousDateTo    string             `json:"previous-date-to"`
+	PreviousSpending  Total              `json:"previous-spending"`
+	RequiredSpending  Total              `json:"required-spending"`
+	Qualified         bool               `json:"qualified"` // PreviousSpending reached RequiredSpending - benefits of this period are given
+	Spending          Total              `json:"spending"`  // Counted spending of this period, toward the next month
+	RemainingSpending Total              `json:"remaining-spending"`
+	Benefits          []CardBenefitUsage `json:"benefits"`
+	Earned            Total              `json:"earned"`
+	ExcludedSpending  Total              `json:"excluded-spending"`
+}
+
+// Rule filling the category, account and tags of a record. Empty conditions match any record.
+type RecordRule struct {
+	Name                string `json:"name"`
+	DescriptionContains string `json:"description-contains,omitempty"` // Case insensitive
+	DescriptionRegex    string `json:"description-regex,omitempty"`
+	AmountMin           string `json:"amount-min,omitempty"` // Decimal in Currency
+	AmountMax           string `json:"amount-max,omitempty"`
+	Currency            string `json:"currency,omitempty"` // Of the amounts, only its records match them. The base currency when empty.
+	AccountID           string `json:"account-id,omitempty"`
+	TimeFrom            string `json:"time-from,omitempty"` // HH:MM, over midnight when after TimeTo
+	TimeTo              string `json:"time-to,omitempty"`
+
+	Category     string   `json:"category,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	SetAccountID string   `json:"set-account-id,omitempty"`
+}
+
+// Record changed by the rules
+type RuleChange struct {
+	RecordID     string   `json:"record-id"`
+	Date         string   `json:"date"`
+	Description  string   `json:"description"`
+	Rules        []string `json:"rules"` // Names of the matched rules
+	Category     string   `json:"category"`
+	NewCategory  string   `json:"new-category"`
+	AccountID    string   `json:"account-id"`
+	NewAccountID string   `json:"new-account-id"`
+	Tags         []string `json:"tags"`
+	NewTags      []string `json:"new-tags"`
+}
+
+type RuleApplyResult struct {
+	From      string       `json:"from"`
+	To        string       `json:"to"`
+	DryRun    bool         `json:"dry-run"`   // Records are not updated
+	Overwrite bool         `json:"overwrite"` // Categories and accounts set before are replaced
+	Checked   int          `json:"checked"`
+	Changes   []RuleChange `json:"changes"`
 }
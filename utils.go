@@ -4,43 +4,73 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"fmt"
-	"os"
+	"math"
+	"regexp"
+	"slices"
 	"time"
 
 	"golang.org/x/crypto/pbkdf2"
 )
 
+// generateKey is the legacy PBKDF2 derivation. New keys use argon2id - see KDFHeader
 func generateKey(password string, salt []byte) []byte {
 	key := pbkdf2.Key([]byte(password), salt, 10000, 32, sha256.New)
 	return key
 }
 
-func getSalt(filename string) ([]byte, error) {
-	_, err := os.Stat(filename)
-	if os.IsNotExist(err) {
-		salt := make([]byte, 16)
-		if _, err := rand.Read(salt); err != nil {
-			return nil, err
-		}
-		if err := os.WriteFile(filename, salt, 0600); err != nil {
-			return nil, err
-		}
-		return salt, nil
-	}
+// newEntityID returns an ID of kind unique even in the same nanosecond - "record:<unix nano>-<random hex>"
+func newEntityID(kind string) string {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
 
-	salt, err := os.ReadFile(filename)
-	if err != nil {
-		return nil, err
-	}
-	return salt, nil
+	return fmt.Sprintf("%s:%d-%x", kind, time.Now().UnixNano(), suffix)
 }
 
 func validateAccount(account Account) error {
 	if account.AccountName == "" {
-		return fmt.Errorf("account name is required")
+		return newValidationError("account-name", "account name is required")
 	}
 	if account.PayType == "" {
-		return fmt.Errorf("pay-type is required")
+		return newValidationError("pay-type", "pay-type is required")
+	}
+	if _, err := parseDecimal(account.OpeningBalance, totalDecimals); err != nil {
+		return newValidationError("opening-balance", "invalid opening-balance: %s", account.OpeningBalance)
+	}
+	for field, day := range map[string]string{"repay-day": account.RepayDay, "use-day-from": account.UseDayFrom, "use-day-to": account.UseDayTo} {
+		if _, err := parseCardDay(day); day != "" && err != nil {
+			return newValidationError(field, "%s must be between 1 and 31, or 말일: %s", field, day)
+		}
+	}
+	if !isDeductionClass(account.DeductionClass) {
+		return newValidationError("deduction-class", "unknown deduction-class: %s", account.DeductionClass)
+	}
+	if account.RepayAccountID != "" && account.RepayAccountID == account.ID {
+		return newValidationError("repay-account-id", "an account can not repay itself")
+	}
+	if value, err := parseDecimal(account.RequiredSpending, totalDecimals); err != nil || value < 0 {
+		return newValidationError("required-spending", "invalid required-spending: %s", account.RequiredSpending)
+	}
+	if account.PayType != "credit" && (account.RequiredSpending != "" || len(account.Benefits) > 0) {
+		return newValidationError("benefits", "benefits are for credit accounts only")
+	}
+	for i, benefit := range account.Benefits {
+		if err := validateCardBenefit(i, benefit); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateCardBenefit(index int, benefit CardBenefit) error {
+	if !slices.Contains(cardBenefitKinds, benefit.Kind) {
+		return newValidationError("kind", "benefit %d: kind must be discount or cashback", index)
+	}
+	if benefit.Rate <= 0 || benefit.Rate > 100 {
+		return newValidationError("rate", "benefit %d: rate must be a percent over 0, up to 100", index)
+	}
+	if value, err := parseDecimal(benefit.MonthlyCap, totalDecimals); err != nil || value < 0 {
+		return newValidationError("monthly-cap", "benefit %d: invalid monthly-cap: %s", index, benefit.MonthlyCap)
 	}
 
 	return nil
@@ -48,7 +78,7 @@ func validateAccount(account Account) error {
 
 func validateCategory(category Category) error {
 	if category.CategoryName == "" {
-		return fmt.Errorf("category name is required")
+		return newValidationError("category-name", "category name is required")
 	}
 
 	return nil
@@ -56,29 +86,132 @@ func validateCategory(category Category) error {
 
 func validateRecord(record Record) error {
 	if record.TransactionType == "" {
-		return fmt.Errorf("transaction-type is required")
+		return newValidationError("transaction-type", "transaction-type is required")
 	}
 	if record.Currency == "" {
-		return fmt.Errorf("currency is required")
+		return newValidationError("currency", "currency is required")
+	}
+	if !isSupportedCurrency(record.Currency) {
+		return newValidationError("currency", "unsupported currency: %s", record.Currency)
 	}
 	if record.PayType == "" {
-		return fmt.Errorf("pay-type is required")
+		return newValidationError("pay-type", "pay-type is required")
+	}
+	if record.amountErr != nil {
+		return newValidationError("amount", "%s", record.amountErr)
 	}
 	if record.Amount == 0 {
-		return fmt.Errorf("amount is required and must be non-zero")
+		return newValidationError("amount", "amount is required and must be non-zero")
 	}
 	if record.Category == "" {
-		return fmt.Errorf("category is required")
+		return newValidationError("category", "category is required")
 	}
 	if record.Date == "" {
-		return fmt.Errorf("date is required")
+		return newValidationError("date", "date is required")
 	}
 	if _, err := time.Parse("2006-01-02", record.Date); err != nil {
-		return fmt.Errorf("invalid date format: use YYYY-MM-DD")
+		return newValidationError("date", "invalid date format: use YYYY-MM-DD")
 	}
 	if record.Time != "" {
 		if _, err := time.Parse("15:04", record.Time); err != nil {
-			return fmt.Errorf("invalid time format: use HH:MM")
+			return newValidationError("time", "invalid time format: use HH:MM")
+		}
+	}
+	if !isDeductionClass(record.DeductionClass) {
+		return newValidationError("deduction-class", "unknown deduction-class: %s", record.DeductionClass)
+	}
+
+	return nil
+}
+
+// validateRecurringItem reports the errors of the index-th item
+func validateRecurringItem(index int, item RecurringItem) error {
+	if item.TransactionType != "record_type_pay" && item.TransactionType != "record_type_income" {
+		return newValidationError("transaction-type", "item %d: transaction-type must be record_type_pay or record_type_income", index)
+	}
+	if !isSupportedCurrency(item.Currency) {
+		return newValidationError("currency", "item %d: unsupported currency: %s", index, item.Currency)
+	}
+	if amount, err := parseMoney(item.Amount, item.Currency); err != nil || amount <= 0 {
+		return newValidationError("amount", "item %d: amount must be a positive decimal of %s: %s", index, item.Currency, item.Amount)
+	}
+	if item.Day < 1 || item.Day > 31 {
+		return newValidationError("day", "item %d: day must be between 1 and 31", index)
+	}
+	if item.Month < 0 || item.Month > 12 {
+		return newValidationError("month", "item %d: month must be 0(every month) or between 1 and 12", index)
+	}
+	for field, date := range map[string]string{"start-date": item.StartDate, "end-date": item.EndDate} {
+		if _, err := time.Parse("2006-01-02", date); date != "" && err != nil {
+			return newValidationError(field, "item %d: invalid %s: use YYYY-MM-DD", index, field)
+		}
+	}
+
+	return nil
+}
+
+// validateRecordRule reports the errors of the index-th rule
+func validateRecordRule(index int, rule RecordRule) error {
+	if rule.Name == "" {
+		return newValidationError("name", "rule %d: name is required", index)
+	}
+	if _, err := regexp.Compile(rule.DescriptionRegex); err != nil {
+		return newValidationError("description-regex", "rule %d: invalid description-regex: %s", index, err)
+	}
+	if rule.Currency != "" && !isSupportedCurrency(rule.Currency) {
+		return newValidationError("currency", "rule %d: unsupported currency: %s", index, rule.Currency)
+	}
+	for field, amount := range map[string]string{"amount-min": rule.AmountMin, "amount-max": rule.AmountMax} {
+		if amount == "" {
+			continue
+		}
+		if _, err := parseMoney(amount, rule.Currency); err != nil {
+			return newValidationError(field, "rule %d: invalid %s of %s: %s", index, field, rule.Currency, amount)
+		}
+	}
+	for field, value := range map[string]string{"time-from": rule.TimeFrom, "time-to": rule.TimeTo} {
+		if _, err := time.Parse("15:04", value); value != "" && err != nil {
+			return newValidationError(field, "rule %d: invalid %s: use HH:MM", index, field)
+		}
+	}
+	if rule.Category == "" && rule.SetAccountID == "" && len(rule.Tags) == 0 {
+		return newValidationError("category", "rule %d: category, tags or set-account-id is required", index)
+	}
+	if rule.SetAccountID != "" {
+		if _, err := ledger.GetAccount(rule.SetAccountID); err != nil {
+			return newValidationError("set-account-id", "rule %d: set-account-id does not exist: %s", index, rule.SetAccountID)
+		}
+	}
+
+	return nil
+}
+
+func validateSettings(settings Settings) error {
+	if !isSupportedCurrency(settings.BaseCurrency) {
+		return newValidationError("base-currency", "unsupported base-currency: %s", settings.BaseCurrency)
+	}
+	if settings.FiscalMonthStart < 1 || settings.FiscalMonthStart > 28 {
+		return newValidationError("fiscal-month-start", "fiscal-month-start must be between 1 and 28")
+	}
+	if settings.WeekStart != "monday" && settings.WeekStart != "sunday" {
+		return newValidationError("week-start", "week-start must be monday or sunday")
+	}
+	for currency, rate := range settings.ExchangeRates {
+		if !isSupportedCurrency(currency) || currency == settings.BaseCurrency {
+			return newValidationError("exchange-rates", "invalid currency of exchange rate: %s", currency)
+		}
+		if rate <= 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+			return newValidationError("exchange-rates", "exchange rate of %s must be positive", currency)
+		}
+	}
+	for _, holiday := range settings.Holidays {
+		if _, err := time.Parse("2006-01-02", holiday); err != nil {
+			return newValidationError("holidays", "invalid holiday: %s, use YYYY-MM-DD", holiday)
+		}
+	}
+	if settings.DefaultAccountID != "" {
+		if _, err := ledger.GetAccount(settings.DefaultAccountID); err != nil {
+			return newValidationError("default-account-id", "default-account-id does not exist: %s", settings.DefaultAccountID)
 		}
 	}
 
@@ -114,23 +247,22 @@ func getCreditPastMonthCount(repayDay, useDayFrom, useDayTo int) (int, int) {
 	return pointOfMonthNum[monthFromIDX], pointOfMonthNum[monthToIDX]
 }
 
+// getCreditDates returns the repay date in the month of refernceDate and its use period.
+// Days after the end of a month are the last day(말일), and the repay date moves to the next business day.
 func getCreditDates(repayDay, useDayFrom, useDayTo int, refernceDate time.Time) (repayDate, useDateFrom, useDateTo time.Time) {
 	year, month, _ := refernceDate.Date()
 	location := refernceDate.Location()
 
 	useMonthFrom, useMonthTo := getCreditPastMonthCount(repayDay, useDayFrom, useDayTo)
 
-	repayDate = time.Date(year, month, int(repayDay), 0, 0, 0, 0, location)
+	// Months are counted from the first day, not to roll over a short month
+	repayMonth := time.Date(year, month, 1, 0, 0, 0, 0, location)
+	repayDate = getBusinessDay(getDayOfMonth(repayMonth, repayDay))
 
-	useDateFrom = repayDate.AddDate(0, useMonthFrom, 0)
-	useDateFrom = time.Date(useDateFrom.Year(), useDateFrom.Month(), int(useDayFrom), 0, 0, 0, 0, location)
+	useDateFrom = getDayOfMonth(repayMonth.AddDate(0, useMonthFrom, 0), useDayFrom)
 
-	useDateTo = repayDate.AddDate(0, useMonthTo, 0)
-	daysOfMonth := time.Date(useDateTo.Year(), useDateTo.Month()+1, 0, 0, 0, 0, 0, location).Day()
-	if useDayTo > daysOfMonth {
-		useDayTo = daysOfMonth
-	}
-	useDateTo = time.Date(useDateTo.Year(), useDateTo.Month(), int(useDayTo), 23, 59, 59, 0, location)
+	useDateTo = getDayOfMonth(repayMonth.AddDate(0, useMonthTo, 0), useDayTo)
+	useDateTo = useDateTo.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
 
 	return repayDate, useDateFrom, useDateTo
 }
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetCreditDates(t *testing.T) {
+	tests := []struct {
+		name                         string
+		repayDay, useDayFrom, useDay int
+		month                        string
+		repay, from, to              string
+	}{
+		{"repay day on a saturday", 14, 1, 31, "2026-03", "2026-03-16", "2026-02-01", "2026-02-28"},
+		{"repay day on a sunday", 15, 2, 1, "2026-03", "2026-03-16", "2026-02-02", "2026-03-01"},
+		{"chuseok and its substitute holiday", 5, 22, 21, "2025-10", "2025-10-10", "2025-08-22", "2025-09-21"},
+		{"seollal and its substitute holiday", 10, 27, 26, "2024-02", "2024-02-13", "2023-12-27", "2024-01-26"},
+		{"substitute holiday of a fixed holiday", 28, 15, 14, "2026-02", "2026-03-03", "2026-01-15", "2026-02-14"},
+		{"말일 of february in a leap year", 31, 1, 31, "2024-02", "2024-02-29", "2024-01-01", "2024-01-31"},
+		{"말일 of february", 31, 1, 31, "2026-02", "2026-03-03", "2026-01-01", "2026-01-31"},
+		{"use until 말일 of february in a leap year", 14, 1, 31, "2024-03", "2024-03-14", "2024-02-01", "2024-02-29"},
+		{"말일 of a 30 day month", 31, 1, 31, "2026-04", "2026-04-30", "2026-03-01", "2026-03-31"},
+		{"use day 31 of a 30 day month", 12, 31, 31, "2026-06", "2026-06-12", "2026-04-30", "2026-05-31"},
+		{"use period in december", 14, 1, 31, "2026-01", "2026-01-14", "2025-12-01", "2025-12-31"},
+		{"use period over the year", 5, 22, 21, "2026-01", "2026-01-05", "2025-11-22", "2025-12-21"},
+		{"christmas and the weekend", 25, 12, 11, "2026-12", "2026-12-28", "2026-11-12", "2026-12-11"},
+		{"repay date moves to the next year", 31, 1, 31, "2028-12", "2029-01-02", "2028-11-01", "2028-11-30"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			month, _ := time.Parse("2006-01", test.month)
+			repayDate, useDateFrom, useDateTo := getCreditDates(test.repayDay, test.useDayFrom, test.useDay, month.AddDate(0, 0, 9))
+
+			got := [3]string{repayDate.Format("2006-01-02"), useDateFrom.Format("2006-01-02"), useDateTo.Format("2006-01-02")}
+			if got != [3]string{test.repay, test.from, test.to} {
+				t.Fatalf("got %v, want %s %s %s", got, test.repay, test.from, test.to)
+			}
+			if useDateTo.Format("15:04:05") != "23:59:59" {
+				t.Fatalf("use period ends at %s", useDateTo.Format("15:04:05"))
+			}
+		})
+	}
+}
+
+func TestParseCardDay(t *testing.T) {
+	for value, want := range map[string]int{"1": 1, "31": 31, "말일": 31, "last": 31} {
+		if day, err := parseCardDay(value); err != nil || day != want {
+			t.Fatalf("%s: got %d, %v", value, day, err)
+		}
+	}
+	for _, value := range []string{"0", "32", "", "first"} {
+		if _, err := parseCardDay(value); err == nil {
+			t.Fatalf("%s: got no error", value)
+		}
+	}
+}
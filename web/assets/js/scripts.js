@@ -26,6 +26,17 @@ const numericPointStep = { "USD": "0.01", "KRW": "1" }
 
 let exchangeRate = 1300
 
+// Message of the error envelope {code, message, field}
+async function errorMessage(r, fallback) {
+    try {
+        const e = await r.json()
+        if (e.message) {
+            return `${fallback}: ${e.message}`
+        }
+    } catch { }
+    return fallback
+}
+
 function checkFormValidation(form, event) {
     event.preventDefault()
     if (event.key == "Enter" && (event.ctrlKey || event.altKey)) {
@@ -55,6 +66,8 @@ const allData = () => {
 
         chart: null,
         chartCredit: null,
+        chartTrend: null,
+        dashboard: {},
         showHomeScreen: false,
         showAccountList: false,
         showCategoryList: false,
@@ -128,11 +141,47 @@ const allData = () => {
                 })
             }
         },
+        async setupTrendChart() {
+            if (this.chartTrend) { this.chartTrend.destroy() }
+
+            // Bucket size by the length of the period
+            const days = (new Date(this.summaryDateTo) - new Date(this.summaryDateFrom)) / 86400000
+            let granularity = "year"
+            if (days <= 31) { granularity = "day" }
+            else if (days <= 180) { granularity = "week" }
+            else if (days <= 366 * 3) { granularity = "month" }
+
+            const uri = `${addr}/report/trend?from=${this.summaryDateFrom}&to=${this.summaryDateTo}&granularity=${granularity}`
+            const r = await fetch(uri)
+            if (!r.ok) { return }
+            const report = await r.json()
+
+            const chartTrendCTX = document.querySelector("#home-trend-chart")
+            if (!chartTrendCTX) { return }
+            this.chartTrend = new Chart(chartTrendCTX, {
+                type: "bar",
+                data: {
+                    labels: report.labels,
+                    datasets: [
+                        { label: "지출", data: report.buckets.map(b => b.pay), stack: "pay" },
+                        { label: "결제 전 신용", data: report.buckets.map(b => b["credit-pay"]), stack: "pay" },
+                        { label: "수입", data: report.buckets.map(b => b.income), stack: "income" },
+                    ],
+                },
+                options: { scales: { x: { stacked: true }, y: { stacked: true } } }
+            })
+        },
+        async getDashboard() {
+            const r = await fetch(`${addr}/report/dashboard`)
+            if (r.ok) { this.dashboard = await r.json() }
+        },
         async showHome() {
             this.clearListViewSelection()
             this.showHomeScreen = true
+            this.getDashboard()
             await this.$nextTick()
             this.setupChart()
+            this.setupTrendChart()
 
             return
         },
@@ -199,7 +248,7 @@ const allData = () => {
                 }
             }
 
-            alert("Fail to set account")
+            alert(await errorMessage(r, "Fail to set account"))
             return false
         },
         async setAccount(event) {
@@ -230,7 +279,7 @@ const allData = () => {
                 }
             }
 
-            alert("Fail to delete account")
+            alert(await errorMessage(r, "Fail to delete account"))
             return false
         },
 
@@ -288,7 +337,7 @@ const allData = () => {
                 }
             }
 
-            alert("Fail to set category")
+            alert(await errorMessage(r, "Fail to set category"))
             return false
         },
         async setCategory(event) {
@@ -319,7 +368,7 @@ const allData = () => {
                 }
             }
 
-            alert("Fail to delete category")
+            alert(await errorMessage(r, "Fail to delete category"))
             return false
         },
 
@@ -331,7 +380,7 @@ const allData = () => {
             }
 
             // const uri = `${addr}/record?q=record:&pageSize=1000`
-            const uri = `${addr}/record?q=record:&from=${this.summaryDateFrom}&to=${this.summaryDateTo}`
+            const uri = `${addr}/record?from=${this.summaryDateFrom}&to=${this.summaryDateTo}`
             const r = await fetch(uri)
             if (r.ok) {
                 this.recordsResponse = await r.json()
@@ -411,7 +460,7 @@ const allData = () => {
                 }
             }
 
-            alert("Fail to set record")
+            alert(await errorMessage(r, "Fail to set record"))
             return false
         },
         async setRecord(event) {
@@ -481,7 +530,7 @@ const allData = () => {
                 }
             }
 
-            alert("Fail to delete record")
+            alert(await errorMessage(r, "Fail to delete record"))
             return false
         },
         async changeDateRange() {
@@ -554,12 +603,11 @@ const allData = () => {
                 if (response.status == "success") {
                     alert("Password is changed")
                     this.preferenceData.open = false
-                    openPasswordGate()
                     return
                 }
             }
 
-            alert("Fail to change password")
+            alert(await errorMessage(r, "Fail to change password"))
             return false
         },
         openPreference() {
@@ -594,6 +642,10 @@ async function enterPassword(event) {
     if (r.ok) {
         const response = await r.json()
         if (response.status == "success") {
+            if (response["recovery-key"]) {
+                alert(`Recovery key - write it down. It is shown only once.\n\n${response["recovery-key"]}`)
+            }
+
             passwordGate.open = false
 
             const body = Alpine.$data(document.querySelector("body"))